@@ -0,0 +1,55 @@
+package epoch
+
+import (
+	"fmt"
+	"slices"
+)
+
+const MAX_AFFINITY_CPU = 1023 // Highest CPU number that can be set with SetCPUAffinity
+
+// SetCPUAffinity restricts the hashing of AttemptHashes workers to cpus, such as the CPUs of one NUMA node from NUMANodeCPUs,
+// so hashing on multi-socket machines does not cross NUMA boundaries. Affinity is best-effort: each worker locks its OS thread
+// and pins it to cpus while hashing, restoring the thread's affinity after, if pinning fails the hash is run unpinned.
+// Affinity is supported on Linux, on other platforms cpus are stored but no affinity is set. Setting nil removes the restriction (default)
+func (e *EPOCH) SetCPUAffinity(cpus []int) (err error) {
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu > MAX_AFFINITY_CPU {
+			err = fmt.Errorf("invalid cpu %d, must be between 0 and %d", cpu, MAX_AFFINITY_CPU)
+			return
+		}
+	}
+
+	if len(cpus) > 0 {
+		cpus = slices.Clone(cpus)
+		slices.Sort(cpus)
+		cpus = slices.Compact(cpus)
+		if err = checkAffinity(cpus); err != nil {
+			err = fmt.Errorf("could not set cpu affinity %v: %s", cpus, err)
+			return
+		}
+	} else {
+		cpus = nil
+	}
+
+	e.Lock()
+	e.affinity = cpus
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH cpu affinity
+func (e *EPOCH) GetCPUAffinity() []int {
+	e.RLock()
+	defer e.RUnlock()
+
+	return slices.Clone(e.affinity)
+}
+
+// Get the cpu affinity used by workers, the slice is replaced and not modified by SetCPUAffinity
+func (e *EPOCH) getCPUAffinity() []int {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.affinity
+}
@@ -0,0 +1,138 @@
+package epoch
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Linux cpu_set_t
+type cpuSet [MAX_AFFINITY_CPU/64 + 1]uint64
+
+// Get the cpuSet of cpus
+func newCPUSet(cpus []int) (set cpuSet) {
+	for _, cpu := range cpus {
+		set[cpu/64] |= 1 << (cpu % 64)
+	}
+
+	return
+}
+
+// Get the cpus in set
+func (set *cpuSet) cpus() (cpus []int) {
+	for cpu := 0; cpu <= MAX_AFFINITY_CPU; cpu++ {
+		if set[cpu/64]&(1<<(cpu%64)) != 0 {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return
+}
+
+// Get or set the affinity of the calling thread with the SYS_SCHED_GETAFFINITY or SYS_SCHED_SETAFFINITY trap
+func schedAffinity(trap uintptr, set *cpuSet) (err error) {
+	_, _, errno := syscall.RawSyscall(trap, 0, unsafe.Sizeof(*set), uintptr(unsafe.Pointer(set)))
+	if errno != 0 {
+		err = errno
+	}
+
+	return
+}
+
+// Check cpus can be pinned to by pinning and restoring a locked thread
+func checkAffinity(cpus []int) (err error) {
+	runtime.LockOSThread()
+
+	var old cpuSet
+	if err = schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &old); err != nil {
+		runtime.UnlockOSThread()
+		return
+	}
+
+	set := newCPUSet(cpus)
+	if err = schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &set); err != nil {
+		runtime.UnlockOSThread()
+		return
+	}
+
+	if schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &old) != nil {
+		return // the thread stays locked so its affinity can not leak to other goroutines, it exits with the goroutine
+	}
+
+	runtime.UnlockOSThread()
+
+	return
+}
+
+// Run fn on an OS thread pinned to cpus, fn is run unpinned when cpus is nil or the thread could not be pinned.
+// If the thread's affinity can not be restored it stays locked to the calling goroutine and exits with it
+func pinned(cpus []int, fn func()) {
+	if len(cpus) == 0 {
+		fn()
+		return
+	}
+
+	runtime.LockOSThread()
+
+	var old cpuSet
+	set := newCPUSet(cpus)
+	if schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &old) != nil || schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &set) != nil {
+		runtime.UnlockOSThread()
+		fn()
+		return
+	}
+
+	fn()
+
+	if schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &old) != nil {
+		return
+	}
+
+	runtime.UnlockOSThread()
+}
+
+// NUMANodeCPUs returns the CPUs of NUMA node as listed by sysfs, to be used with SetCPUAffinity
+func NUMANodeCPUs(node int) (cpus []int, err error) {
+	b, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		err = fmt.Errorf("could not read NUMA node %d: %s", node, err)
+		return
+	}
+
+	return parseCPUList(strings.TrimSpace(string(b)))
+}
+
+// Parse a sysfs cpulist such as "0-3,8,10-11"
+func parseCPUList(list string) (cpus []int, err error) {
+	if list == "" {
+		return
+	}
+
+	for _, r := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(r, "-")
+
+		var start, end int
+		if start, err = strconv.Atoi(first); err != nil {
+			err = fmt.Errorf("invalid cpulist %q", list)
+			return
+		}
+
+		end = start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				err = fmt.Errorf("invalid cpulist %q", list)
+				return
+			}
+		}
+
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return
+}
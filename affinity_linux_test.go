@@ -0,0 +1,61 @@
+package epoch
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test workers are pinned to the cpus set by SetCPUAffinity and their thread affinity is restored
+func TestCPUAffinity(t *testing.T) {
+	t.Cleanup(func() { SetCPUAffinity(nil) })
+
+	runtime.LockOSThread()
+	var allowed cpuSet
+	err := schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &allowed)
+	runtime.UnlockOSThread()
+	if err != nil {
+		t.Skipf("sched_getaffinity is not available: %s", err)
+	}
+
+	cpus := allowed.cpus()
+	if len(cpus) == 0 {
+		t.Skip("No cpus in the thread's affinity")
+	}
+	cpu := cpus[len(cpus)-1]
+
+	assert.Error(t, SetCPUAffinity([]int{-1}), "SetCPUAffinity should error with a negative cpu")
+	assert.Error(t, SetCPUAffinity([]int{MAX_AFFINITY_CPU + 1}), "SetCPUAffinity should error above MAX_AFFINITY_CPU")
+
+	err = SetCPUAffinity([]int{cpu, cpu})
+	if err != nil {
+		t.Skipf("Affinity could not be set: %s", err)
+	}
+	assert.Equal(t, []int{cpu}, GetCPUAffinity(), "CPU affinity should be equal")
+
+	var during, after cpuSet
+	pinned(epoch.getCPUAffinity(), func() {
+		assert.NoError(t, schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &during), "sched_getaffinity should not error")
+		runtime.LockOSThread() // still on the pinned thread to check its restored affinity
+	})
+	assert.NoError(t, schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &after), "sched_getaffinity should not error")
+	runtime.UnlockOSThread()
+
+	assert.Equal(t, []int{cpu}, during.cpus(), "Thread should be pinned to the cpu while hashing")
+	assert.Equal(t, cpus, after.cpus(), "Thread affinity should be restored after hashing")
+
+	assert.NoError(t, SetCPUAffinity(nil), "SetCPUAffinity should not error with nil")
+	assert.Nil(t, GetCPUAffinity(), "CPU affinity should be removed")
+
+	parsed, err := parseCPUList("0-3,8,10-11")
+	assert.NoError(t, err, "parseCPUList should not error: %s", err)
+	assert.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, parsed, "Parsed cpus should be equal")
+	_, err = parseCPUList("3-1")
+	assert.Error(t, err, "parseCPUList should error with an invalid range")
+
+	if _, err := NUMANodeCPUs(0); err != nil {
+		t.Logf("NUMA node 0 is not available: %s", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package epoch
+
+import "fmt"
+
+// CPU affinity is not supported, cpus are accepted so SetCPUAffinity is a no-op
+func checkAffinity(cpus []int) (err error) {
+	return
+}
+
+// Run fn, threads are not pinned on this platform
+func pinned(cpus []int, fn func()) {
+	fn()
+}
+
+// NUMANodeCPUs returns the CPUs of NUMA node, it is only supported on Linux
+func NUMANodeCPUs(node int) (cpus []int, err error) {
+	err = fmt.Errorf("NUMA nodes are not supported on this platform")
+	return
+}
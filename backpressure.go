@@ -0,0 +1,126 @@
+package epoch
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	DEFAULT_BACKPRESSURE_HIGH = 8 // Default submit queue depth at which OnBackpressure is called
+	DEFAULT_BACKPRESSURE_LOW  = 2 // Default submit queue depth at which OnBackpressureRelieved is called
+)
+
+// Submit queue depth and sync, the queue is the valid hashes waiting for a submit slot and the hashes buffered in the SubmitChannel
+type backpressure struct {
+	waiting   int  // waiting is the count of submissions waiting for a submit slot
+	high      int  // high is the depth at which the queue is saturated
+	low       int  // low is the depth at which a saturated queue is relieved
+	saturated bool // saturated is set from when depth reaches high until it drains to low
+	sync.Mutex
+}
+
+// Add n to the submissions waiting for a slot, returning the queue depth and if it became saturated or relieved
+func (b *backpressure) add(n, buffered int) (depth int, saturated, relieved bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.waiting += n
+	depth = b.waiting + buffered
+	switch {
+	case !b.saturated && depth >= b.high:
+		b.saturated = true
+		saturated = true
+	case b.saturated && depth <= b.low:
+		b.saturated = false
+		relieved = true
+	}
+
+	return
+}
+
+// Get the submissions waiting for a slot
+func (b *backpressure) depth() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.waiting
+}
+
+// Mark a submission as waiting for a submit slot
+func (e *EPOCH) enterSubmitQueue() {
+	e.changeSubmitQueue(1)
+}
+
+// Mark a waiting submission as having its submit slot
+func (e *EPOCH) leaveSubmitQueue() {
+	e.changeSubmitQueue(-1)
+}
+
+// Change the submissions waiting for a slot by n, calling the backpressure callbacks when the queue becomes saturated or is relieved
+func (e *EPOCH) changeSubmitQueue(n int) {
+	// The SubmitChannel buffer is read before pressure is locked so e is never locked while holding pressure
+	depth, saturated, relieved := e.pressure.add(n, len(e.SubmitChannel()))
+	switch {
+	case saturated:
+		e.backpressureChanged(true, depth)
+	case relieved:
+		e.backpressureChanged(false, depth)
+	}
+}
+
+// Set the submit queue depths at which OnBackpressure and OnBackpressureRelieved are called, the queue is the
+// valid hashes waiting for a submit slot and the hashes buffered in the SubmitChannel. A growing queue means the
+// node is slow to accept submissions. High must be greater than low, defaults are DEFAULT_BACKPRESSURE_HIGH and DEFAULT_BACKPRESSURE_LOW
+func (e *EPOCH) SetBackpressureMarks(high, low int) (err error) {
+	if low < 0 || high <= low {
+		err = fmt.Errorf("backpressure high mark %d must be greater than low mark %d", high, low)
+		return
+	}
+
+	e.pressure.Lock()
+	e.pressure.high = high
+	e.pressure.low = low
+	e.pressure.Unlock()
+
+	return
+}
+
+// Get the EPOCH backpressure high and low marks
+func (e *EPOCH) GetBackpressureMarks() (high, low int) {
+	e.pressure.Lock()
+	defer e.pressure.Unlock()
+
+	return e.pressure.high, e.pressure.low
+}
+
+// OnBackpressure sets the callback that is called with the submit queue depth when it reaches the high mark, it is not
+// called again until the queue has been relieved. It is called from the submitting goroutine so it should not block.
+// Setting nil will remove the callback
+func (e *EPOCH) OnBackpressure(fn func(depth int)) {
+	e.events.Lock()
+	e.events.pressure = fn
+	e.events.Unlock()
+}
+
+// OnBackpressureRelieved sets the callback that is called with the submit queue depth when a queue that reached the
+// high mark drains to the low mark. It is called from the submitting goroutine so it should not block. Setting nil will remove the callback
+func (e *EPOCH) OnBackpressureRelieved(fn func(depth int)) {
+	e.events.Lock()
+	e.events.relieved = fn
+	e.events.Unlock()
+}
+
+// Call the OnBackpressure callback if saturated, otherwise the OnBackpressureRelieved callback if set
+func (e *EPOCH) backpressureChanged(saturated bool, depth int) {
+	e.events.RLock()
+	fn := e.events.relieved
+	if saturated {
+		fn = e.events.pressure
+	}
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	fn(depth)
+}
@@ -0,0 +1,110 @@
+package epoch
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/civilware/tela/logger"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// Submission waiting for the node to report it as accepted or rejected
+type confirmation struct {
+	outcome chan bool // outcome receives true when accepted and false when rejected
+	e       *EPOCH    // e is the EPOCH the submission is waiting on
+}
+
+// Set how long each submission waits for the node to confirm it, which enables confirm mode. GetWork does not acknowledge
+// submissions, so they are correlated in order with the accepted and rejected counts the node reports in its next jobs.
+// A submission that is not confirmed before d is counted in the session as Unknown and the worker proceeds. A timeout of 0
+// disables confirm mode so submissions are not waited on (default)
+func (e *EPOCH) SetConfirmTimeout(d time.Duration) (err error) {
+	if d < 0 {
+		err = fmt.Errorf("confirm timeout must be 0 or greater")
+		return
+	}
+
+	e.Lock()
+	e.ackTimeout = d
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH confirm timeout
+func (e *EPOCH) GetConfirmTimeout() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.ackTimeout
+}
+
+// Start waiting for a submission to be confirmed prior to writing it, c is nil when confirm mode is disabled
+func (e *EPOCH) newConfirmation() (c *confirmation, timeout time.Duration) {
+	e.Lock()
+	defer e.Unlock()
+
+	timeout = e.ackTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	c = &confirmation{outcome: make(chan bool, 1), e: e}
+	e.confirming = append(e.confirming, c)
+
+	return
+}
+
+// Stop waiting for the confirmation, returns false if it has already been confirmed
+func (c *confirmation) cancel() bool {
+	c.e.Lock()
+	defer c.e.Unlock()
+
+	i := slices.Index(c.e.confirming, c)
+	if i < 0 {
+		return false
+	}
+
+	c.e.confirming = slices.Delete(c.e.confirming, i, i+1)
+
+	return true
+}
+
+// Wait up to timeout for the submission of job to be confirmed, if it is not it is counted as Unknown
+func (c *confirmation) wait(batch uint64, job rpc.GetBlockTemplate_Result, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var accepted bool
+	select {
+	case accepted = <-c.outcome:
+	case <-timer.C:
+		if c.cancel() {
+			c.e.Lock()
+			c.e.session.Unknown++
+			c.e.Unlock()
+			logger.Warnf(batchLog(batch)+"Miniblock for height %d was not confirmed after %s\n", job.Height, timeout)
+			return
+		}
+
+		accepted = <-c.outcome // confirmed as it timed out
+	}
+
+	if !accepted {
+		logger.Warnf(batchLog(batch)+"Miniblock for height %d was rejected\n", job.Height)
+	}
+}
+
+// Confirm the oldest waiting submissions with the blocks the node has newly reported, e must be locked by the caller
+func (e *EPOCH) confirm(accepted, rejected uint64) {
+	for ; accepted > 0 && len(e.confirming) > 0; accepted-- {
+		e.confirming[0].outcome <- true
+		e.confirming = e.confirming[1:]
+	}
+
+	for ; rejected > 0 && len(e.confirming) > 0; rejected-- {
+		e.confirming[0].outcome <- false
+		e.confirming = e.confirming[1:]
+	}
+}
@@ -0,0 +1,675 @@
+package epoch
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/creachadair/jrpc2/handler"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// IsActive calls EPOCH.IsActive on the default instance
+func IsActive() bool {
+	return epoch.IsActive()
+}
+
+// JobStatus calls EPOCH.JobStatus on the default instance
+func JobStatus() (status JobStatus_Result) {
+	return epoch.JobStatus()
+}
+
+// JobIsReady calls EPOCH.JobIsReady on the default instance
+func JobIsReady(timeout time.Duration) (err error) {
+	return epoch.JobIsReady(timeout)
+}
+
+// JobIsReadyContext calls EPOCH.JobIsReadyContext on the default instance
+func JobIsReadyContext(ctx context.Context) (err error) {
+	return epoch.JobIsReadyContext(ctx)
+}
+
+// IsProcessing calls EPOCH.IsProcessing on the default instance
+func IsProcessing() bool {
+	return epoch.IsProcessing()
+}
+
+// SetNetwork calls EPOCH.SetNetwork on the default instance
+func SetNetwork(name string) (err error) {
+	return epoch.SetNetwork(name)
+}
+
+// GetNetwork calls EPOCH.GetNetwork on the default instance
+func GetNetwork() string {
+	return epoch.GetNetwork()
+}
+
+// SetAddress calls EPOCH.SetAddress on the default instance
+func SetAddress(address string) (err error) {
+	return epoch.SetAddress(address)
+}
+
+// GetAddress calls EPOCH.GetAddress on the default instance
+func GetAddress() string {
+	return epoch.GetAddress()
+}
+
+// SwitchAddress calls EPOCH.SwitchAddress on the default instance
+func SwitchAddress(address string, resetSession bool) (err error) {
+	return epoch.SwitchAddress(address, resetSession)
+}
+
+// SetPort calls EPOCH.SetPort on the default instance
+func SetPort(port int) (err error) {
+	return epoch.SetPort(port)
+}
+
+// GetPort calls EPOCH.GetPort on the default instance
+func GetPort() string {
+	return epoch.GetPort()
+}
+
+// SetMaxHashes calls EPOCH.SetMaxHashes on the default instance
+func SetMaxHashes(i int) (err error) {
+	return epoch.SetMaxHashes(i)
+}
+
+// GetMaxHashes calls EPOCH.GetMaxHashes on the default instance
+func GetMaxHashes() int {
+	return epoch.GetMaxHashes()
+}
+
+// SetRewardPerBlock calls EPOCH.SetRewardPerBlock on the default instance
+func SetRewardPerBlock(amount uint64) {
+	epoch.SetRewardPerBlock(amount)
+}
+
+// GetRewardPerBlock calls EPOCH.GetRewardPerBlock on the default instance
+func GetRewardPerBlock() uint64 {
+	return epoch.GetRewardPerBlock()
+}
+
+// SetMaxJobAge calls EPOCH.SetMaxJobAge on the default instance
+func SetMaxJobAge(d time.Duration) (err error) {
+	return epoch.SetMaxJobAge(d)
+}
+
+// GetMaxJobAge calls EPOCH.GetMaxJobAge on the default instance
+func GetMaxJobAge() time.Duration {
+	return epoch.GetMaxJobAge()
+}
+
+// SetSubmitRate calls EPOCH.SetSubmitRate on the default instance
+func SetSubmitRate(perSecond int) (err error) {
+	return epoch.SetSubmitRate(perSecond)
+}
+
+// GetSubmitRate calls EPOCH.GetSubmitRate on the default instance
+func GetSubmitRate() int {
+	return epoch.GetSubmitRate()
+}
+
+// SetMaxSubmitConcurrency calls EPOCH.SetMaxSubmitConcurrency on the default instance
+func SetMaxSubmitConcurrency(n int) (err error) {
+	return epoch.SetMaxSubmitConcurrency(n)
+}
+
+// GetMaxSubmitConcurrency calls EPOCH.GetMaxSubmitConcurrency on the default instance
+func GetMaxSubmitConcurrency() int {
+	return epoch.GetMaxSubmitConcurrency()
+}
+
+// SetStrictPort calls EPOCH.SetStrictPort on the default instance
+func SetStrictPort(b bool) {
+	epoch.SetStrictPort(b)
+}
+
+// GetStrictPort calls EPOCH.GetStrictPort on the default instance
+func GetStrictPort() bool {
+	return epoch.GetStrictPort()
+}
+
+// SetStrictMaxHashes calls EPOCH.SetStrictMaxHashes on the default instance
+func SetStrictMaxHashes(b bool) {
+	epoch.SetStrictMaxHashes(b)
+}
+
+// GetStrictMaxHashes calls EPOCH.GetStrictMaxHashes on the default instance
+func GetStrictMaxHashes() bool {
+	return epoch.GetStrictMaxHashes()
+}
+
+// SetMaxThreads calls EPOCH.SetMaxThreads on the default instance
+func SetMaxThreads(i int) {
+	epoch.SetMaxThreads(i)
+}
+
+// GetMaxThreads calls EPOCH.GetMaxThreads on the default instance
+func GetMaxThreads() int {
+	return epoch.GetMaxThreads()
+}
+
+// SetNonceRegion calls EPOCH.SetNonceRegion on the default instance
+func SetNonceRegion(offset, length int) (err error) {
+	return epoch.SetNonceRegion(offset, length)
+}
+
+// GetNonceRegion calls EPOCH.GetNonceRegion on the default instance
+func GetNonceRegion() (offset, length int) {
+	return epoch.GetNonceRegion()
+}
+
+// SetNonceDedupe calls EPOCH.SetNonceDedupe on the default instance
+func SetNonceDedupe(b bool) {
+	epoch.SetNonceDedupe(b)
+}
+
+// GetNonceDedupe calls EPOCH.GetNonceDedupe on the default instance
+func GetNonceDedupe() bool {
+	return epoch.GetNonceDedupe()
+}
+
+// SetSynchronous calls EPOCH.SetSynchronous on the default instance
+func SetSynchronous(b bool) {
+	epoch.SetSynchronous(b)
+}
+
+// GetSynchronous calls EPOCH.GetSynchronous on the default instance
+func GetSynchronous() bool {
+	return epoch.GetSynchronous()
+}
+
+// SetMinSubmitDifficulty calls EPOCH.SetMinSubmitDifficulty on the default instance
+func SetMinSubmitDifficulty(d uint64) {
+	epoch.SetMinSubmitDifficulty(d)
+}
+
+// GetMinSubmitDifficulty calls EPOCH.GetMinSubmitDifficulty on the default instance
+func GetMinSubmitDifficulty() uint64 {
+	return epoch.GetMinSubmitDifficulty()
+}
+
+// SetWorkPath calls EPOCH.SetWorkPath on the default instance
+func SetWorkPath(template string) (err error) {
+	return epoch.SetWorkPath(template)
+}
+
+// GetWorkPath calls EPOCH.GetWorkPath on the default instance
+func GetWorkPath() string {
+	return epoch.GetWorkPath()
+}
+
+// SetTLSEnabled calls EPOCH.SetTLSEnabled on the default instance
+func SetTLSEnabled(b bool) {
+	epoch.SetTLSEnabled(b)
+}
+
+// GetTLSEnabled calls EPOCH.GetTLSEnabled on the default instance
+func GetTLSEnabled() bool {
+	return epoch.GetTLSEnabled()
+}
+
+// SetScheme calls EPOCH.SetScheme on the default instance
+func SetScheme(scheme string) (err error) {
+	return epoch.SetScheme(scheme)
+}
+
+// GetScheme calls EPOCH.GetScheme on the default instance
+func GetScheme() string {
+	return epoch.GetScheme()
+}
+
+// SetTLSConfig calls EPOCH.SetTLSConfig on the default instance
+func SetTLSConfig(config *tls.Config) {
+	epoch.SetTLSConfig(config)
+}
+
+// GetTLSConfig calls EPOCH.GetTLSConfig on the default instance
+func GetTLSConfig() *tls.Config {
+	return epoch.GetTLSConfig()
+}
+
+// SetCompression calls EPOCH.SetCompression on the default instance
+func SetCompression(b bool) {
+	epoch.SetCompression(b)
+}
+
+// GetCompression calls EPOCH.GetCompression on the default instance
+func GetCompression() bool {
+	return epoch.GetCompression()
+}
+
+// StopGetWork calls EPOCH.StopGetWork on the default instance
+func StopGetWork() {
+	epoch.StopGetWork()
+}
+
+// Close calls EPOCH.Close on the default instance
+func Close() (err error) {
+	return epoch.Close()
+}
+
+// StartGetWork calls EPOCH.StartGetWork on the default instance
+func StartGetWork(address, endpoint string) (err error) {
+	return epoch.StartGetWork(address, endpoint)
+}
+
+// StartGetWorkOnPort calls EPOCH.StartGetWorkOnPort on the default instance
+func StartGetWorkOnPort(address, endpoint string, port int) (err error) {
+	return epoch.StartGetWorkOnPort(address, endpoint, port)
+}
+
+// GetSession calls EPOCH.GetSession on the default instance
+func GetSession(timeout time.Duration) (session GetSessionEPOCH_Result, err error) {
+	return epoch.GetSession(timeout)
+}
+
+// SubmitSuccessRatio calls EPOCH.SubmitSuccessRatio on the default instance
+func SubmitSuccessRatio() float64 {
+	return epoch.SubmitSuccessRatio()
+}
+
+// Warmup calls EPOCH.Warmup on the default instance
+func Warmup() {
+	epoch.Warmup()
+}
+
+// AttemptHashes calls EPOCH.AttemptHashes on the default instance
+func AttemptHashes(hashes int) (result EPOCH_Result, err error) {
+	return epoch.AttemptHashes(hashes)
+}
+
+// AttemptHashesContext calls EPOCH.AttemptHashesContext on the default instance
+func AttemptHashesContext(ctx context.Context, hashes int) (result EPOCH_Result, err error) {
+	return epoch.AttemptHashesContext(ctx, hashes)
+}
+
+// SubmitRefs calls EPOCH.SubmitRefs on the default instance
+func SubmitRefs(refs []SubmitRef) (result EPOCH_Result, err error) {
+	return epoch.SubmitRefs(refs)
+}
+
+// SubmitHashes calls EPOCH.SubmitHashes on the default instance
+func SubmitHashes(params []Submit_Params) (result EPOCH_Result, err error) {
+	return epoch.SubmitHashes(params)
+}
+
+// SetCPUAffinity calls EPOCH.SetCPUAffinity on the default instance
+func SetCPUAffinity(cpus []int) (err error) {
+	return epoch.SetCPUAffinity(cpus)
+}
+
+// GetCPUAffinity calls EPOCH.GetCPUAffinity on the default instance
+func GetCPUAffinity() []int {
+	return epoch.GetCPUAffinity()
+}
+
+// SetBackpressureMarks calls EPOCH.SetBackpressureMarks on the default instance
+func SetBackpressureMarks(high, low int) (err error) {
+	return epoch.SetBackpressureMarks(high, low)
+}
+
+// GetBackpressureMarks calls EPOCH.GetBackpressureMarks on the default instance
+func GetBackpressureMarks() (high, low int) {
+	return epoch.GetBackpressureMarks()
+}
+
+// OnBackpressure calls EPOCH.OnBackpressure on the default instance
+func OnBackpressure(fn func(depth int)) {
+	epoch.OnBackpressure(fn)
+}
+
+// OnBackpressureRelieved calls EPOCH.OnBackpressureRelieved on the default instance
+func OnBackpressureRelieved(fn func(depth int)) {
+	epoch.OnBackpressureRelieved(fn)
+}
+
+// SetConfirmTimeout calls EPOCH.SetConfirmTimeout on the default instance
+func SetConfirmTimeout(d time.Duration) (err error) {
+	return epoch.SetConfirmTimeout(d)
+}
+
+// GetConfirmTimeout calls EPOCH.GetConfirmTimeout on the default instance
+func GetConfirmTimeout() time.Duration {
+	return epoch.GetConfirmTimeout()
+}
+
+// StartStatsEmitter calls EPOCH.StartStatsEmitter on the default instance
+func StartStatsEmitter(interval time.Duration, fn func(Dashboard_Result)) (err error) {
+	return epoch.StartStatsEmitter(interval, fn)
+}
+
+// GetEndpointStats calls EPOCH.GetEndpointStats on the default instance
+func GetEndpointStats() map[string]EndpointStat {
+	return epoch.GetEndpointStats()
+}
+
+// OnBlockFound calls EPOCH.OnBlockFound on the default instance
+func OnBlockFound(fn func(BlockFoundEvent)) {
+	epoch.OnBlockFound(fn)
+}
+
+// OnConfigChange calls EPOCH.OnConfigChange on the default instance
+func OnConfigChange(fn func(ConfigChange)) {
+	epoch.OnConfigChange(fn)
+}
+
+// Subscribe calls EPOCH.Subscribe on the default instance
+func Subscribe() <-chan ConnectionState {
+	return epoch.Subscribe()
+}
+
+// JobUpdates calls EPOCH.JobUpdates on the default instance
+func JobUpdates() <-chan rpc.GetBlockTemplate_Result {
+	return epoch.JobUpdates()
+}
+
+// OnSessionLimit calls EPOCH.OnSessionLimit on the default instance
+func OnSessionLimit(fn func(SessionLimitEvent)) {
+	epoch.OnSessionLimit(fn)
+}
+
+// SetRawMessageHook calls EPOCH.SetRawMessageHook on the default instance
+func SetRawMessageHook(fn func([]byte)) {
+	epoch.SetRawMessageHook(fn)
+}
+
+// SetFixedJob calls EPOCH.SetFixedJob on the default instance
+func SetFixedJob(job rpc.GetBlockTemplate_Result) (err error) {
+	return epoch.SetFixedJob(job)
+}
+
+// ClearFixedJob calls EPOCH.ClearFixedJob on the default instance
+func ClearFixedJob() {
+	epoch.ClearFixedJob()
+}
+
+// GetFixedJob calls EPOCH.GetFixedJob on the default instance
+func GetFixedJob() (job rpc.GetBlockTemplate_Result, ok bool) {
+	return epoch.GetFixedJob()
+}
+
+// SetHashrateWindow calls EPOCH.SetHashrateWindow on the default instance
+func SetHashrateWindow(d time.Duration) (err error) {
+	return epoch.SetHashrateWindow(d)
+}
+
+// GetHashrateWindow calls EPOCH.GetHashrateWindow on the default instance
+func GetHashrateWindow() time.Duration {
+	return epoch.GetHashrateWindow()
+}
+
+// Health calls EPOCH.Health on the default instance
+func Health() (result Health_Result) {
+	return epoch.Health()
+}
+
+// HealthEPOCH calls EPOCH.HealthEPOCH on the default instance
+func HealthEPOCH(ctx context.Context) (result Health_Result, err error) {
+	return epoch.HealthEPOCH(ctx)
+}
+
+// SetKeepalive calls EPOCH.SetKeepalive on the default instance
+func SetKeepalive(interval time.Duration) (err error) {
+	return epoch.SetKeepalive(interval)
+}
+
+// GetKeepalive calls EPOCH.GetKeepalive on the default instance
+func GetKeepalive() time.Duration {
+	return epoch.GetKeepalive()
+}
+
+// SetSessionHashLimit calls EPOCH.SetSessionHashLimit on the default instance
+func SetSessionHashLimit(n uint64) {
+	epoch.SetSessionHashLimit(n)
+}
+
+// GetSessionHashLimit calls EPOCH.GetSessionHashLimit on the default instance
+func GetSessionHashLimit() uint64 {
+	return epoch.GetSessionHashLimit()
+}
+
+// SetMemoryProfiling calls EPOCH.SetMemoryProfiling on the default instance
+func SetMemoryProfiling(b bool) {
+	epoch.SetMemoryProfiling(b)
+}
+
+// GetMemoryProfiling calls EPOCH.GetMemoryProfiling on the default instance
+func GetMemoryProfiling() bool {
+	return epoch.GetMemoryProfiling()
+}
+
+// GetHandler calls EPOCH.GetHandler on the default instance
+func GetHandler() map[string]handler.Func {
+	return epoch.GetHandler()
+}
+
+// AttemptEPOCH calls EPOCH.AttemptEPOCH on the default instance
+func AttemptEPOCH(ctx context.Context, p Attempt_Params) (result EPOCH_Result, err error) {
+	return epoch.AttemptEPOCH(ctx, p)
+}
+
+// AttemptAndStatsEPOCH calls EPOCH.AttemptAndStatsEPOCH on the default instance
+func AttemptAndStatsEPOCH(ctx context.Context, p Attempt_Params) (result AttemptAndStats_Result, err error) {
+	return epoch.AttemptAndStatsEPOCH(ctx, p)
+}
+
+// SubmitEPOCH calls EPOCH.SubmitEPOCH on the default instance
+func SubmitEPOCH(ctx context.Context, params []Submit_Params) (result EPOCH_Result, err error) {
+	return epoch.SubmitEPOCH(ctx, params)
+}
+
+// SubmitRefEPOCH calls EPOCH.SubmitRefEPOCH on the default instance
+func SubmitRefEPOCH(ctx context.Context, refs []SubmitRef) (result EPOCH_Result, err error) {
+	return epoch.SubmitRefEPOCH(ctx, refs)
+}
+
+// GetMaxHashesEPOCH calls EPOCH.GetMaxHashesEPOCH on the default instance
+func GetMaxHashesEPOCH(ctx context.Context) (result GetMaxHashes_Result, err error) {
+	return epoch.GetMaxHashesEPOCH(ctx)
+}
+
+// GetAddressEPOCH calls EPOCH.GetAddressEPOCH on the default instance
+func GetAddressEPOCH(ctx context.Context) (result GetAddressEPOCH_Result, err error) {
+	return epoch.GetAddressEPOCH(ctx)
+}
+
+// GetSessionEPOCH calls EPOCH.GetSessionEPOCH on the default instance
+func GetSessionEPOCH(ctx context.Context) (result GetSessionEPOCH_Result, err error) {
+	return epoch.GetSessionEPOCH(ctx)
+}
+
+// Dashboard calls EPOCH.Dashboard on the default instance
+func Dashboard(ctx context.Context) (result Dashboard_Result, err error) {
+	return epoch.Dashboard(ctx)
+}
+
+// SetOrphanAlert calls EPOCH.SetOrphanAlert on the default instance
+func SetOrphanAlert(threshold float64, fn func(OrphanAlertEvent)) (err error) {
+	return epoch.SetOrphanAlert(threshold, fn)
+}
+
+// SetPoolStrategy calls EPOCH.SetPoolStrategy on the default instance
+func SetPoolStrategy(strategy PoolStrategy) (err error) {
+	return epoch.SetPoolStrategy(strategy)
+}
+
+// GetPoolStrategy calls EPOCH.GetPoolStrategy on the default instance
+func GetPoolStrategy() PoolStrategy {
+	return epoch.GetPoolStrategy()
+}
+
+// StartGetWorkPool calls EPOCH.StartGetWorkPool on the default instance
+func StartGetWorkPool(address string, endpoints []string) (err error) {
+	return epoch.StartGetWorkPool(address, endpoints)
+}
+
+// SetProfiling calls EPOCH.SetProfiling on the default instance
+func SetProfiling(b bool) {
+	epoch.SetProfiling(b)
+}
+
+// GetProfiling calls EPOCH.GetProfiling on the default instance
+func GetProfiling() bool {
+	return epoch.GetProfiling()
+}
+
+// SetReconnectPolicy calls EPOCH.SetReconnectPolicy on the default instance
+func SetReconnectPolicy(policy ReconnectPolicy) (err error) {
+	return epoch.SetReconnectPolicy(policy)
+}
+
+// GetReconnectPolicy calls EPOCH.GetReconnectPolicy on the default instance
+func GetReconnectPolicy() ReconnectPolicy {
+	return epoch.GetReconnectPolicy()
+}
+
+// SetReconnect calls EPOCH.SetReconnect on the default instance
+func SetReconnect(enabled bool, maxAttempts int) (err error) {
+	return epoch.SetReconnect(enabled, maxAttempts)
+}
+
+// GetReconnect calls EPOCH.GetReconnect on the default instance
+func GetReconnect() (enabled bool, maxAttempts int) {
+	return epoch.GetReconnect()
+}
+
+// SetReconnectBackoff calls EPOCH.SetReconnectBackoff on the default instance
+func SetReconnectBackoff(base, max time.Duration) (err error) {
+	return epoch.SetReconnectBackoff(base, max)
+}
+
+// GetReconnectBackoff calls EPOCH.GetReconnectBackoff on the default instance
+func GetReconnectBackoff() (base, max time.Duration) {
+	return epoch.GetReconnectBackoff()
+}
+
+// SetReconnectJitter calls EPOCH.SetReconnectJitter on the default instance
+func SetReconnectJitter(fraction float64) (err error) {
+	return epoch.SetReconnectJitter(fraction)
+}
+
+// GetReconnectJitter calls EPOCH.GetReconnectJitter on the default instance
+func GetReconnectJitter() float64 {
+	return epoch.GetReconnectJitter()
+}
+
+// SetConnectRetries calls EPOCH.SetConnectRetries on the default instance
+func SetConnectRetries(n int, backoff time.Duration) (err error) {
+	return epoch.SetConnectRetries(n, backoff)
+}
+
+// GetConnectRetries calls EPOCH.GetConnectRetries on the default instance
+func GetConnectRetries() (n int, backoff time.Duration) {
+	return epoch.GetConnectRetries()
+}
+
+// StartAndRun calls EPOCH.StartAndRun on the default instance
+func StartAndRun(ctx context.Context, address, endpoint string, hashesPerBatch int) (result EPOCH_Result, err error) {
+	return epoch.StartAndRun(ctx, address, endpoint, hashesPerBatch)
+}
+
+// HashCurrentJob calls EPOCH.HashCurrentJob on the default instance
+func HashCurrentJob(ctx context.Context, hashes int) (result EPOCH_Result, height uint64, difficulty string, err error) {
+	return epoch.HashCurrentJob(ctx, hashes)
+}
+
+// ExportSession calls EPOCH.ExportSession on the default instance
+func ExportSession() (data []byte, err error) {
+	return epoch.ExportSession()
+}
+
+// ImportSession calls EPOCH.ImportSession on the default instance
+func ImportSession(data []byte) (err error) {
+	return epoch.ImportSession(data)
+}
+
+// SetBlockTime calls EPOCH.SetBlockTime on the default instance
+func SetBlockTime(d time.Duration) (err error) {
+	return epoch.SetBlockTime(d)
+}
+
+// GetBlockTime calls EPOCH.GetBlockTime on the default instance
+func GetBlockTime() time.Duration {
+	return epoch.GetBlockTime()
+}
+
+// NetworkShareEstimate calls EPOCH.NetworkShareEstimate on the default instance
+func NetworkShareEstimate() (result NetworkShare_Result, err error) {
+	return epoch.NetworkShareEstimate()
+}
+
+// Shutdown calls EPOCH.Shutdown on the default instance
+func Shutdown(ctx context.Context) (err error) {
+	return epoch.Shutdown(ctx)
+}
+
+// StopHashing calls EPOCH.StopHashing on the default instance
+func StopHashing(timeout time.Duration) (err error) {
+	return epoch.StopHashing(timeout)
+}
+
+// SetWorkerKey calls EPOCH.SetWorkerKey on the default instance
+func SetWorkerKey(key []byte) {
+	epoch.SetWorkerKey(key)
+}
+
+// SubmitChannel calls EPOCH.SubmitChannel on the default instance
+func SubmitChannel() chan<- Submit_Params {
+	return epoch.SubmitChannel()
+}
+
+// RunLoop calls EPOCH.RunLoop on the default instance
+func RunLoop(ctx context.Context, batchSize int) (err error) {
+	return epoch.RunLoop(ctx, batchSize)
+}
+
+// ResultsChannel calls EPOCH.ResultsChannel on the default instance
+func ResultsChannel() <-chan EPOCH_Result {
+	return epoch.ResultsChannel()
+}
+
+// SetSubmissionLogFile calls EPOCH.SetSubmissionLogFile on the default instance
+func SetSubmissionLogFile(path string) (err error) {
+	return epoch.SetSubmissionLogFile(path)
+}
+
+// GetSubmissionLogFile calls EPOCH.GetSubmissionLogFile on the default instance
+func GetSubmissionLogFile() string {
+	return epoch.GetSubmissionLogFile()
+}
+
+// SetSubmissionLogMaxSize calls EPOCH.SetSubmissionLogMaxSize on the default instance
+func SetSubmissionLogMaxSize(bytes int64) (err error) {
+	return epoch.SetSubmissionLogMaxSize(bytes)
+}
+
+// GetSubmissionLogMaxSize calls EPOCH.GetSubmissionLogMaxSize on the default instance
+func GetSubmissionLogMaxSize() int64 {
+	return epoch.GetSubmissionLogMaxSize()
+}
+
+// SetTransport calls EPOCH.SetTransport on the default instance
+func SetTransport(transport Transport) (err error) {
+	return epoch.SetTransport(transport)
+}
+
+// GetTransport calls EPOCH.GetTransport on the default instance
+func GetTransport() Transport {
+	return epoch.GetTransport()
+}
+
+// Autotune calls EPOCH.Autotune on the default instance
+func Autotune(ctx context.Context) (threads int, err error) {
+	return epoch.Autotune(ctx)
+}
+
+// SetAcceptedVersions calls EPOCH.SetAcceptedVersions on the default instance
+func SetAcceptedVersions(versions []byte) (err error) {
+	return epoch.SetAcceptedVersions(versions)
+}
+
+// GetAcceptedVersions calls EPOCH.GetAcceptedVersions on the default instance
+func GetAcceptedVersions() []byte {
+	return epoch.GetAcceptedVersions()
+}
@@ -0,0 +1,70 @@
+package epoch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const MIN_STATS_INTERVAL = time.Millisecond * 100 // Minimum interval of StartStatsEmitter
+
+// StartStatsEmitter calls fn with a Dashboard snapshot every interval while EPOCH is connected, so UIs can be pushed
+// updates instead of polling GetSessionEPOCH. It is called from the emitter's goroutine so a slow fn delays the next
+// snapshot. Snapshots are skipped while reconnecting and the emitter stops with the connection, so fn is not called
+// once StopGetWork or Shutdown has begun. Interval must be at least MIN_STATS_INTERVAL
+func (e *EPOCH) StartStatsEmitter(interval time.Duration, fn func(Dashboard_Result)) (err error) {
+	if interval < MIN_STATS_INTERVAL {
+		err = fmt.Errorf("stats interval %s is less than %s", interval, MIN_STATS_INTERVAL)
+		return
+	}
+
+	if fn == nil {
+		err = fmt.Errorf("stats emitter requires a callback")
+		return
+	}
+
+	if !e.IsActive() {
+		err = ErrNotActive
+		return
+	}
+
+	e.conn.Lock()
+	done := e.conn.done
+	e.conn.Unlock()
+	if done == nil {
+		err = ErrNotActive
+		return
+	}
+
+	go e.emitStats(done, interval, fn)
+
+	return
+}
+
+// Call fn with a Dashboard snapshot each interval until done is closed
+func (e *EPOCH) emitStats(done <-chan struct{}, interval time.Duration, fn func(Dashboard_Result)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		// The connection may have begun stopping while waiting
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		stats, err := e.Dashboard(context.Background())
+		if err != nil || e.isDraining() {
+			continue
+		}
+
+		fn(stats)
+	}
+}
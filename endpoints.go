@@ -0,0 +1,141 @@
+package epoch
+
+import (
+	"sync"
+	"time"
+)
+
+// Statistics for each GetWork endpoint EPOCH has connected to and sync
+type endpointStats struct {
+	stats   map[string]*EndpointStat
+	current string      // current is the endpoint of the running connection
+	since   time.Time   // since is when the current endpoint was last connected, zero while reconnecting
+	recent  []time.Time // recent is when the running connection reconnected within HEALTH_RECONNECT_WINDOW
+	sync.Mutex
+}
+
+// EndpointStat is how a GetWork endpoint has performed, accumulated across all connections to it
+type EndpointStat struct {
+	Connects      int           `json:"connects"`      // Times StartGetWork has connected to the endpoint
+	Reconnects    int           `json:"reconnects"`    // Times the endpoint was reconnected to after a connection error
+	MiniBlocks    int           `json:"miniBlocks"`    // Valid miniblocks submitted to the endpoint
+	Uptime        time.Duration `json:"uptime"`        // Total time connected to the endpoint, not including time spent reconnecting
+	LastConnected time.Time     `json:"lastConnected"` // Last time the endpoint was connected or reconnected to
+}
+
+// GetEndpointStats returns the statistics of each endpoint StartGetWork has connected to keyed by its host:port,
+// the current endpoint's uptime includes its running connection. Stats are kept until the process exits
+func (e *EPOCH) GetEndpointStats() map[string]EndpointStat {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	stats := make(map[string]EndpointStat, len(e.endpoints.stats))
+	for endpoint, stat := range e.endpoints.stats {
+		s := *stat
+		if endpoint == e.endpoints.current && !e.endpoints.since.IsZero() {
+			s.Uptime += time.Since(e.endpoints.since)
+		}
+		stats[endpoint] = s
+	}
+
+	return stats
+}
+
+// Get the endpoint of the running connection, empty when not running
+func (e *EPOCH) currentEndpoint() string {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	return e.endpoints.current
+}
+
+// Get the stat for endpoint, endpoints must be locked by the caller
+func (e *endpointStats) get(endpoint string) *EndpointStat {
+	if e.stats == nil {
+		e.stats = map[string]*EndpointStat{}
+	}
+
+	stat, ok := e.stats[endpoint]
+	if !ok {
+		stat = &EndpointStat{}
+		e.stats[endpoint] = stat
+	}
+
+	return stat
+}
+
+// Add the current endpoint's uptime since it was connected, endpoints must be locked by the caller
+func (e *endpointStats) down() {
+	if e.current == "" || e.since.IsZero() {
+		return
+	}
+
+	e.get(e.current).Uptime += time.Since(e.since)
+	e.since = time.Time{}
+}
+
+// Record a StartGetWork connection to endpoint
+func (e *EPOCH) endpointConnected(endpoint string) {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	e.endpoints.down()
+	now := time.Now()
+	stat := e.endpoints.get(endpoint)
+	stat.Connects++
+	stat.LastConnected = now
+	e.endpoints.current = endpoint
+	e.endpoints.since = now
+	e.endpoints.recent = nil
+}
+
+// Record the running connection being reconnected to endpoint, which is a different endpoint when a pool has failed over
+func (e *EPOCH) endpointReconnected(endpoint string) {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	if e.endpoints.current == "" {
+		return
+	}
+
+	now := time.Now()
+	stat := e.endpoints.get(endpoint)
+	stat.Reconnects++
+	stat.LastConnected = now
+	e.endpoints.current = endpoint
+	e.endpoints.since = now
+	e.endpoints.recent = append(e.endpoints.recentReconnects(), now)
+}
+
+// Get the reconnects of the running connection within HEALTH_RECONNECT_WINDOW, endpoints must be locked by the caller
+func (e *endpointStats) recentReconnects() []time.Time {
+	cutoff := time.Now().Add(-HEALTH_RECONNECT_WINDOW)
+	for len(e.recent) > 0 && e.recent[0].Before(cutoff) {
+		e.recent = e.recent[1:]
+	}
+
+	return e.recent
+}
+
+// Stop the current endpoint's uptime, if stopped the connection has ended and there is no current endpoint
+func (e *EPOCH) endpointDown(stopped bool) {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	e.endpoints.down()
+	if stopped {
+		e.endpoints.current = ""
+	}
+}
+
+// Record a valid miniblock submitted to the current endpoint
+func (e *EPOCH) endpointBlock() {
+	e.endpoints.Lock()
+	defer e.endpoints.Unlock()
+
+	if e.endpoints.current == "" {
+		return
+	}
+
+	e.endpoints.get(e.endpoints.current).MiniBlocks++
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -13,12 +15,13 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/civilware/tela/logger"
 	"github.com/deroproject/derohe/astrobwt/astrobwtv3"
 	"github.com/deroproject/derohe/block"
-	"github.com/deroproject/derohe/blockchain"
+	"github.com/deroproject/derohe/config"
 	"github.com/deroproject/derohe/globals"
 	"github.com/deroproject/derohe/rpc"
 	"github.com/gorilla/websocket"
@@ -28,218 +31,1197 @@ import (
 
 // Web socket connection and sync
 type connection struct {
-	ws *websocket.Conn
+	ws       workConn
+	scheme   string             // scheme is the url scheme ws was dialed with, reconnects use the same scheme
+	cancel   context.CancelFunc // cancel is called by StopGetWork to end all goroutines scoped to the connection
+	done     <-chan struct{}    // done is closed when the connection's goroutines are cancelled
+	starting bool               // starting is set while StartGetWork is connecting
+	abort    context.CancelFunc // abort is called by StopGetWork to end StartGetWork's connect retries
+	write    sync.Mutex         // write serializes submissions to ws, it is not held with the connection lock so ws can be closed during a write
 	sync.Mutex
 }
 
-// DERO block template and sync
+// DERO block template and sync, the current jobs are read from an immutable snapshot without locking
+// so workers do not contend for them, the mutex serializes newJob writers
 type jobs struct {
-	job rpc.GetBlockTemplate_Result
-	sync.RWMutex
+	current atomic.Value  // current is the *jobSnapshot published by newJob, it is not modified once stored
+	resume  uint64        // resume is the height of last when the connection was lost, 0 if not reconnecting
+	ready   chan struct{} // ready is closed and replaced each time a job is installed
+	sync.Mutex
+}
+
+// Snapshot of the jobs published by newJob
+type jobSnapshot struct {
+	job      rpc.GetBlockTemplate_Result
+	last     rpc.GetBlockTemplate_Result  // last is the most recent job with work, used while job has none
+	received time.Time                    // received is when last was installed
+	fixed    *rpc.GetBlockTemplate_Result // fixed is the job set by SetFixedJob that is hashed on instead of job, nil when not set
+	stale    bool                         // stale is set when the connection job and last were received on is lost, until a job arrives on the new connection
+}
+
+// Get the current jobs snapshot
+func (j *jobs) load() *jobSnapshot {
+	if snapshot, ok := j.current.Load().(*jobSnapshot); ok {
+		return snapshot
+	}
+
+	return &jobSnapshot{}
+}
+
+// Publish a new jobs snapshot, j must be locked by the caller
+func (j *jobs) store(snapshot jobSnapshot) {
+	j.current.Store(&snapshot)
+}
+
+// First error from concurrent workers and sync
+type workError struct {
+	err error
+	sync.Mutex
+}
+
+// Submission token bucket and sync
+type submitLimit struct {
+	rate   int       // rate is the submissions allowed per second, 0 is unlimited
+	tokens float64   // tokens available, negative when submissions are queued
+	last   time.Time // last is when tokens were refilled
+	sync.Mutex
 }
 
-// EPOCH main structure
+// Nonces used within a batch and sync
+type nonces struct {
+	used map[string]struct{}
+	sync.Mutex
+}
+
+// EPOCH main structure, the package-level functions use a default instance and New creates others
 type EPOCH struct {
 	conn       connection             // Connection to GetWork from DERO node
 	jobs       jobs                   // DERO block template for work
 	port       string                 // GetWork port that EPOCH will connect to
 	address    string                 // EPOCH reward address
+	network    string                 // network is the globals network the address was validated on when connecting
+	hostNet    string                 // hostNet is the host's globals network as read by StartGetWork or set by SetNetwork
 	processing bool                   // When EPOCH is processing or submitting jobs
 	maxHashes  int                    // maxHashes is the maximum accepted hashes for a single request, this can be set as per the host app with EPOCH package defining a hard limit of LIMIT_MAX_HASHES
+	strict     bool                   // strict will error instead of warn when maxHashes and maxThreads would result in a long batch
+	strictPort bool                   // strictPort will error instead of warn when a well-known port that is not a GetWork port is set
 	maxThreads int                    // maxThreads is the maximum concurrent workers
+	policy     ReconnectPolicy        // policy defines which connection errors EPOCH will reconnect on
+	retries    int                    // retries is how many times StartGetWork will retry its initial connect
+	backoff    time.Duration          // backoff is the delay before the first connect retry, doubled after each failed retry
+	jitter     float64                // jitter is the fraction each reconnect delay is randomized by
+	redials    int                    // redials is the maximum reconnect attempts before EPOCH is stopped, 0 is unlimited
+	redial     [2]time.Duration       // redial is the initial and maximum delay between reconnect attempts
+	transport  Transport              // transport is how the GetWork connection receives jobs and submits work
+	keepalive  time.Duration          // keepalive is the interval the GetWork connection must receive traffic within, 0 is disabled
+	workPath   string                 // workPath is the GetWork connection path template, see SetWorkPath
+	tls        bool                   // tls is if the GetWork connection uses wss, when false ws is used without a TLS handshake
+	compress   bool                   // compress is if permessage-deflate is negotiated for the GetWork connection
+	tlsConfig  *tls.Config            // tlsConfig is used for wss connections when set, otherwise certificates are not verified
+	nonce      [2]int                 // nonce is the offset and length of the work bytes randomized for each hash
+	dedupe     bool                   // dedupe will re-roll any nonce already used within a batch before hashing
+	inline     bool                   // inline runs each AttemptHashes hash on the calling goroutine, see SetSynchronous
+	minDiff    uint64                 // minDiff is the job difficulty below which valid hashes are not submitted, 0 submits all
+	affinity   []int                  // affinity is the CPUs workers are pinned to while hashing, nil is unpinned
 	semaphore  chan struct{}          // Limit EPOCH workers to maxThreads
+	maxSubmits int                    // maxSubmits is the maximum concurrent submissions, separate from maxThreads
+	submitting chan struct{}          // Limit EPOCH submissions to maxSubmits
+	submitCh   chan Submit_Params     // submitCh receives hashes streamed from the host for submission
+	results    chan EPOCH_Result      // results receives the result of each RunLoop batch
+	looping    bool                   // looping is set while RunLoop is running
+	batches    uint64                 // batches is the last BatchID given to an AttemptHashes or SubmitHashes call
+	stopStream context.CancelFunc     // stopStream stops the SubmitChannel consumer so Shutdown can flush the channel itself
+	streamed   <-chan struct{}        // streamed is closed when the SubmitChannel consumer has stopped
+	draining   bool                   // draining is set by Shutdown to stop batches dispatching new workers
+	stopping   *hashingStop           // stopping is the running batches signaled by the next StopHashing
 	session    GetSessionEPOCH_Result // session counts the total hashes and submissions that have occurred while connection is active
+	imported   *sessionExport         // imported is the session totals from ImportSession to restore when StartGetWork connects
+	difficulty big.Int                // difficulty is the cumulative difficulty of all miniblocks submitted during the session
+	accepted   uint64                 // accepted is the count of blocks the node has last reported as accepted for the connection
+	rejected   uint64                 // rejected is the count of blocks the node has last reported as rejected for the connection
+	reward     uint64                 // reward is the configured reward per accepted block used to estimate session rewards
+	maxJobAge  time.Duration          // maxJobAge is how long the last job with work can be used while the current job has none
+	workerKey  []byte                 // workerKey is the shared key remote worker submissions are signed with
+	versions   []byte                 // versions is the job blob versions that will be hashed on
+	seen       versionSet             // seen is the job blob versions received during the session
+	orphans    orphanRate             // orphans is the rolling record of blocks the node has reported as accepted or rejected
+	blockTime  time.Duration          // blockTime is the target block time used to estimate network share
+	submits    submitLimit            // submits paces submissions to the node
+	submitLog  submissionLog          // submitLog is the durable log of submission attempts, see SetSubmissionLogFile
+	ackTimeout time.Duration          // ackTimeout is how long submissions wait to be confirmed, 0 disables confirm mode
+	confirming []*confirmation        // confirming is the submissions waiting to be confirmed, oldest first
+	hashrate   rateAverage            // hashrate is the session's moving average hash rate
+	profile    profile                // profile times each hash when profiling is enabled
+	memory     memProfile             // memory measures each batch when memory profiling is enabled
+	pressure   backpressure           // pressure tracks the submit queue depth for the backpressure callbacks
+	recent     recentSubmits          // recent is the work submitted within RECENT_SUBMIT_WINDOW by any batch
+	hashLimit  uint64                 // hashLimit is the maximum hashes for a session, 0 is unlimited
+	pending    uint64                 // pending is the hashes reserved by running attempts against hashLimit
+	limited    bool                   // limited is set once the session has reached hashLimit
+	endpoints  endpointStats          // endpoints is the statistics for each GetWork endpoint connected to
+	pool       pool                   // pool is the endpoints used by StartGetWorkPool
+	events     events                 // Host application callbacks for EPOCH events
 	sync.RWMutex
 }
 
-var epoch EPOCH
+// Default instance used by the package-level functions
+var epoch = New()
+
+var (
+	// ErrNotActive is returned when EPOCH work is requested without an active GetWork connection
+	ErrNotActive = errors.New("epoch is not active")
+	// ErrAlreadyRunning is returned when StartGetWork is called while EPOCH is already running or starting
+	ErrAlreadyRunning = errors.New("epoch is already running")
+	// ErrSessionHashLimit is returned when an attempt would exceed the session hash limit set by SetSessionHashLimit
+	ErrSessionHashLimit = errors.New("epoch session hash limit reached")
+	// ErrJobNotReady is returned when there is no job with work to hash, the last job with work is older than maxJobAge or it is from a lost connection
+	ErrJobNotReady = errors.New("epoch job is not ready")
+	// ErrNetworkChanged is returned when SetNetwork has changed the network from the network EPOCH connected on, EPOCH is stopped
+	ErrNetworkChanged = errors.New("epoch network has changed")
+	// ErrNoAddress is returned by StartGetWork when no address is passed and no address has been set
+	ErrNoAddress = errors.New("reward address not set; call SetAddress or pass address")
+)
 
 const (
-	DEFAULT_MAX_THREADS = 2     // Default max thread value for EPOCH
-	DEFAULT_WORK_PORT   = 10100 // Default DERO GetWork port
-	LIMIT_MAX_HASHES    = 10000 // Maximum value that EPOCH package will accept hashes per request at
+	DEFAULT_MAX_THREADS = 2        // Default max thread value for EPOCH
+	DEFAULT_MAX_SUBMITS = 2        // Default max concurrent submissions to the node
+	DEFAULT_WORK_PORT   = 10100    // Default DERO GetWork port
+	DEFAULT_WORK_PATH   = "/ws/%s" // Default GetWork connection path, %s is the reward address
+	LIMIT_MAX_HASHES    = 10000    // Maximum value that EPOCH package will accept hashes per request at
+	WARMUP_HASHES       = 5        // Throwaway hashes each worker will run when Warmup is called
+	DEFAULT_NONCE_BYTES = 12       // Default amount of trailing work bytes used as the nonce, the final byte is NONCE_FLAG
+	DEDUPE_RETRIES      = 100      // Maximum times a duplicate nonce will be re-rolled when nonce dedupe is enabled
+	LONG_BATCH_HASHES   = 2500     // Hashes per thread at which a single maxHashes batch is considered long
+
+	DEFAULT_MAX_JOB_AGE = time.Second * 18 // Default age that the last job with work can be hashed on while the current job has none
+	JOB_WAIT_TIMEOUT    = time.Second * 5  // Time HashCurrentJob will wait for a job with work
+	JOB_ERROR_SUMMARY   = time.Minute      // Interval a repeating job error is summarized at instead of logged for each job
+	SUBMIT_TIMEOUT      = time.Second * 10 // Maximum time writing a submission to the node can take before the connection is considered broken
+
+	NONCE_FLAG_BYTE = block.MINIBLOCK_SIZE - 1 // Index of the final work byte, it is the low byte of the miniblock's last nonce word
+	NONCE_FLAG      = byte(1)                  // Value of the final work byte, dero-miner stores its thread ID here and EPOCH marks its work with 1
 )
 
-// Initialize EPOCH package defaults
-func init() {
-	epoch.port = fmt.Sprintf(":%d", DEFAULT_WORK_PORT)
-	SetMaxThreads(DEFAULT_MAX_THREADS)
-	epoch.maxHashes = 1000
+// Check if EPOCH connection is active
+func (e *EPOCH) IsActive() bool {
+	e.conn.Lock()
+	defer e.conn.Unlock()
 
-	epoch.session.Version = "1.0.0" // EPOCH package version
+	return e.conn.ws != nil
 }
 
-// Check if EPOCH connection is active
-func IsActive() bool {
-	return epoch.conn.ws != nil
+// Check if a GetWork connection has been started and not stopped, it can be running while IsActive is false when reconnecting
+func (e *EPOCH) isRunning() bool {
+	e.conn.Lock()
+	defer e.conn.Unlock()
+
+	return e.conn.cancel != nil
 }
 
 // Set EPOCH processing when doing jobs or submissions
-func setProcessing(b bool) {
-	epoch.Lock()
-	epoch.processing = b
-	epoch.Unlock()
+func (e *EPOCH) setProcessing(b bool) {
+	e.Lock()
+	e.processing = b
+	e.Unlock()
 }
 
 // Set a new DERO block template and return lastError
 func (e *EPOCH) newJob(job rpc.GetBlockTemplate_Result) (lastError string) {
+	var missed uint64
 	e.jobs.Lock()
-	e.jobs.job = job
+	snapshot := *e.jobs.load()
+	snapshot.job = job
+	snapshot.stale = false
+	if job.Blockhashing_blob != "" {
+		if e.jobs.resume > 0 {
+			if job.Height > e.jobs.resume+1 {
+				missed = job.Height - e.jobs.resume - 1
+			}
+			e.jobs.resume = 0
+		}
+		snapshot.last = job
+		snapshot.received = time.Now()
+	}
+	e.jobs.store(snapshot)
+	if e.jobs.ready != nil {
+		close(e.jobs.ready)
+		e.jobs.ready = nil
+	}
 	e.jobs.Unlock()
 
+	if job.Blockhashing_blob != "" {
+		e.seenVersion(job.Blockhashing_blob)
+	}
+
+	e.addAccepted(job)
+	e.jobUpdated(job)
+
+	if missed > 0 {
+		logger.Warnf("[EPOCH] Missed %d heights while reconnecting\n", missed)
+		e.Lock()
+		e.session.MissedHeights += missed
+		e.Unlock()
+	}
+
 	lastError = job.LastError
 
 	return
 }
 
+// Add the blocks the node reports as newly accepted or rejected for the connection to the session totals
+func (e *EPOCH) addAccepted(job rpc.GetBlockTemplate_Result) {
+	e.Lock()
+	accepted := reportedDelta(e.accepted, job.MiniBlocks+job.Blocks)
+	e.session.Accepted += accepted
+	e.accepted = job.MiniBlocks + job.Blocks
+	rejected := reportedDelta(e.rejected, job.Rejected)
+	e.session.Rejected += rejected
+	e.rejected = job.Rejected
+	e.orphans.add(accepted, rejected)
+	e.confirm(accepted, rejected)
+	event := e.orphans.alert()
+	e.Unlock()
+
+	if event != nil {
+		e.orphanAlert(*event)
+	}
+}
+
+// Get the increase from the last count the node reported to the current reported count
+func reportedDelta(last, reported uint64) uint64 {
+	if reported >= last {
+		return reported - last
+	}
+
+	return reported // node has started a new count for the connection
+}
+
+// Mark the jobs of a lost connection as stale so they are not hashed on while reconnecting,
+// the height of the last job with work is kept to count the heights missed before the next job
+func (j *jobs) markStale() {
+	j.Lock()
+	defer j.Unlock()
+
+	snapshot := *j.load()
+	snapshot.stale = true
+	j.resume = snapshot.last.Height
+	j.store(snapshot)
+}
+
 // Get the current DERO block template
 func (e *EPOCH) getJob() (job rpc.GetBlockTemplate_Result) {
-	e.jobs.RLock()
-	job = e.jobs.job
-	e.jobs.RUnlock()
+	return e.jobs.load().job
+}
+
+// Get the DERO block template to hash on, a job set by SetFixedJob is always used. If the current job has no
+// work the last job with work is used until it is older than maxJobAge, after which ErrJobNotReady is returned.
+// Jobs from a lost connection are not used regardless of their age, ErrJobNotReady is returned until the reconnected node sends a job
+func (e *EPOCH) getWorkJob() (job rpc.GetBlockTemplate_Result, err error) {
+	maxAge := e.GetMaxJobAge()
+
+	snapshot := e.jobs.load()
+	if snapshot.fixed != nil {
+		job = *snapshot.fixed
+		return
+	}
+
+	if snapshot.stale {
+		err = ErrJobNotReady
+		return
+	}
+
+	if snapshot.job.Blockhashing_blob != "" {
+		job = snapshot.job
+		return
+	}
+
+	if snapshot.last.Blockhashing_blob == "" || time.Since(snapshot.received) > maxAge {
+		err = ErrJobNotReady
+		return
+	}
+
+	job = snapshot.last
+
+	return
+}
+
+// JobStatus_Result is the freshness of the job EPOCH is hashing on
+type JobStatus_Result struct {
+	JobID      string        `json:"jobid"`
+	Height     uint64        `json:"height"`
+	Difficulty string        `json:"difficulty"`
+	Age        time.Duration `json:"age"`   // Time since the job with work was received
+	Stale      bool          `json:"stale"` // True when there is no job with work, the job is older than maxJobAge or is from a lost connection
+}
+
+// JobStatus returns the ID, height, difficulty and age of the last job with work and if it is stale as per SetMaxJobAge
+func (e *EPOCH) JobStatus() (status JobStatus_Result) {
+	maxAge := e.GetMaxJobAge()
+
+	snapshot := e.jobs.load()
+	if snapshot.last.Blockhashing_blob == "" {
+		status.Stale = true
+		return
+	}
+
+	status.JobID = snapshot.last.JobID
+	status.Height = snapshot.last.Height
+	status.Difficulty = snapshot.last.Difficulty
+	status.Age = time.Since(snapshot.received)
+	status.Stale = snapshot.stale || status.Age > maxAge
 
 	return
 }
 
 // JobIsReady waits for a JobID to be present, it returns error if job is not found before timeout duration
-func JobIsReady(timeout time.Duration) (err error) {
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+func (e *EPOCH) JobIsReady(timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = e.JobIsReadyContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("could not get EPOCH job after %s", timeout)
+	}
 
+	return
+}
+
+// JobIsReadyContext waits for a JobID to be present, it returns ctx.Err() if ctx is done before a job is found. It is signaled
+// as each job is received so it returns as soon as a job is ready, while reconnecting it waits for a job from the new connection
+func (e *EPOCH) JobIsReadyContext(ctx context.Context) (err error) {
 	for {
+		e.jobs.Lock()
+		if snapshot := e.jobs.load(); snapshot.job.JobID != "" && !snapshot.stale {
+			e.jobs.Unlock()
+			return
+		}
+
+		if e.jobs.ready == nil {
+			e.jobs.ready = make(chan struct{})
+		}
+		ready := e.jobs.ready
+		e.jobs.Unlock()
+
 		select {
-		case <-timer.C:
-			err = fmt.Errorf("could not get EPOCH job after %s", timeout)
+		case <-ctx.Done():
+			err = ctx.Err()
 			return
-		default:
-			if epoch.jobs.job.JobID != "" {
-				return
-			}
+		case <-ready:
+		}
+	}
+}
+
+// Set the worker error if one has not already been set
+func (w *workError) set(err error) {
+	w.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.Unlock()
+}
+
+// Get the first worker error
+func (w *workError) get() error {
+	w.Lock()
+	defer w.Unlock()
 
-			time.Sleep(time.Second)
+	return w.err
+}
+
+// Reserve a submission token, returning how long to wait before submitting. If the wait would be longer
+// than maxWait no token is reserved and ok is false
+func (l *submitLimit) reserve(maxWait time.Duration) (wait time.Duration, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.rate < 1 {
+		return 0, true
+	}
+
+	now := time.Now()
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*float64(l.rate), float64(l.rate))
+	l.last = now
+	if l.tokens < 1 {
+		wait = time.Duration((1 - l.tokens) / float64(l.rate) * float64(time.Second))
+		if wait > maxWait {
+			return
 		}
 	}
+
+	l.tokens--
+	ok = true
+
+	return
+}
+
+// Add nonce to the set, returns false if nonce has already been used
+func (n *nonces) add(nonce []byte) bool {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.used == nil {
+		n.used = map[string]struct{}{}
+	}
+
+	key := string(nonce)
+	if _, ok := n.used[key]; ok {
+		return false
+	}
+
+	n.used[key] = struct{}{}
+
+	return true
+}
+
+// SetNetwork tells EPOCH the globals network the host has switched to, hosts that call globals.InitNetwork during a session
+// should then call SetNetwork(globals.Config.Name) on the same goroutine. If it is not the network EPOCH connected on, jobs
+// and submissions will error with ErrNetworkChanged and EPOCH is stopped. Globals are not read from EPOCH's own goroutines
+func (e *EPOCH) SetNetwork(name string) (err error) {
+	if name == "" {
+		err = fmt.Errorf("network name is empty")
+		return
+	}
+
+	e.Lock()
+	e.hostNet = name
+	e.Unlock()
+
+	return
+}
+
+// Get the globals network as last read by StartGetWork or set by SetNetwork
+func (e *EPOCH) GetNetwork() string {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.hostNet
+}
+
+// Check the host's network is the network EPOCH connected on, returns ErrNetworkChanged if it has been switched
+func (e *EPOCH) checkNetwork() (err error) {
+	e.RLock()
+	network := e.network
+	current := e.hostNet
+	e.RUnlock()
+
+	if network != current {
+		err = fmt.Errorf("%w from %s to %s", ErrNetworkChanged, network, current)
+	}
+
+	return
+}
+
+// Get the semaphore for the active connection, it is nil when no connection has been started
+func (e *EPOCH) getSemaphore() chan struct{} {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.semaphore
+}
+
+// Get the submission semaphore for the active connection, it is nil when no connection has been started
+func (e *EPOCH) getSubmitSemaphore() chan struct{} {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.submitting
 }
 
 // Check if EPOCH is processing jobs or submissions
-func IsProcessing() bool {
-	epoch.RLock()
-	defer epoch.RUnlock()
+func (e *EPOCH) IsProcessing() bool {
+	e.RLock()
+	defer e.RUnlock()
 
-	return epoch.processing
+	return e.processing
 }
 
 // Set the EPOCH reward address, must be a registered DERO address
-func SetAddress(address string) (err error) {
+func (e *EPOCH) SetAddress(address string) (err error) {
 	_, err = globals.ParseValidateAddress(address)
 	if err != nil {
 		return
 	}
 
-	epoch.Lock()
-	epoch.address = address
-	epoch.Unlock()
+	e.Lock()
+	e.address = address
+	e.Unlock()
+
+	e.configChanged("address", address)
 
 	return
 }
 
 // Get the EPOCH reward address
-func GetAddress() string {
-	epoch.RLock()
-	defer epoch.RUnlock()
+func (e *EPOCH) GetAddress() string {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.address
+}
+
+// SwitchAddress changes the reward address mid-mining by stopping the running connection and reconnecting to its endpoint
+// with address, the session totals are kept unless resetSession is true. If address is not valid an error is returned and
+// the running connection is not affected. Attempts and subscriptions are ended by the stop as with StopGetWork, if the
+// reconnect fails EPOCH is left stopped and the error is returned
+func (e *EPOCH) SwitchAddress(address string, resetSession bool) (err error) {
+	if _, err = globals.ParseValidateAddress(address); err != nil {
+		err = fmt.Errorf("address %q is not valid: %s", address, err)
+		return
+	}
+
+	endpoint := e.currentEndpoint()
+	if !e.isRunning() || endpoint == "" {
+		err = ErrNotActive
+		return
+	}
+
+	_, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return
+	}
+
+	e.pool.Lock()
+	poolEndpoints := e.pool.endpoints
+	e.pool.Unlock()
+
+	e.RLock()
+	session := e.session
+	var difficulty big.Int
+	difficulty.Set(&e.difficulty)
+	hashrate := e.hashrate
+	e.RUnlock()
 
-	return epoch.address
+	e.StopGetWork()
+
+	if err = e.startGetWork(address, endpoint, ":"+port, poolEndpoints); err != nil {
+		return
+	}
+
+	if resetSession {
+		return
+	}
+
+	// The new connection has reset the session, add back the totals from before the switch
+	e.Lock()
+	e.session.Hashes += session.Hashes
+	e.session.MiniBlocks += session.MiniBlocks
+	e.session.Accepted += session.Accepted
+	e.session.Rejected += session.Rejected
+	e.session.MissedHeights += session.MissedHeights
+	e.session.Started = session.Started
+	e.difficulty.Add(&e.difficulty, &difficulty)
+	e.hashrate = hashrate
+	e.Unlock()
+
+	return
 }
 
-// Set the GetWork port if port is valid
-func SetPort(port int) (err error) {
+// Set the GetWork port if port is valid. If port is a well-known port that is not a GetWork port, such as
+// HTTPS or the daemon RPC port, a warning is logged or an error is returned when SetStrictPort is true
+func (e *EPOCH) SetPort(port int) (err error) {
 	if port < 1 || port > 65535 {
 		err = fmt.Errorf("invalid EPOCH port")
 		return
 	}
 
-	epoch.Lock()
-	epoch.port = fmt.Sprintf(":%d", port)
-	epoch.Unlock()
+	if err = checkPort(port); err != nil {
+		if e.GetStrictPort() {
+			return
+		}
+
+		logger.Warnf("[EPOCH] %s\n", err)
+		err = nil
+	}
+
+	e.Lock()
+	e.port = fmt.Sprintf(":%d", port)
+	e.Unlock()
+
+	e.configChanged("port", port)
+
+	return
+}
+
+// Get the EPOCH work port
+func (e *EPOCH) GetPort() string {
+	e.RLock()
+	defer e.RUnlock()
+
+	return strings.Trim(e.port, ":")
+}
+
+// Set the max amount of hash attempts or job submissions that a single request can handle, exceeding MAX_HASHES will return error.
+// If the maxHashes per maxThreads exceeds LONG_BATCH_HASHES a warning is logged, or an error is returned when SetStrictMaxHashes is true.
+// Attempts check maxHashes when they start, so attempts already running keep the limit they started with as their result's MaxHashes
+func (e *EPOCH) SetMaxHashes(i int) (err error) {
+	if i > LIMIT_MAX_HASHES {
+		err = fmt.Errorf("cannot exceed %d hashes", LIMIT_MAX_HASHES)
+		return
+	}
+
+	if err = checkBatchSize(i, e.GetMaxThreads()); err != nil {
+		if e.GetStrictMaxHashes() {
+			return
+		}
+
+		logger.Warnf("[EPOCH] %s\n", err)
+		err = nil
+	}
+
+	e.Lock()
+	e.maxHashes = i
+	e.Unlock()
+
+	e.configChanged("maxHashes", i)
+
+	return
+}
+
+// Get the EPOCH maxHashes value
+func (e *EPOCH) GetMaxHashes() int {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.maxHashes
+}
+
+// Set the reward per accepted block in atomic units, used to estimate the session reward as accepted blocks * reward.
+// The actual reward depends on the network and height so this is only an estimate, default is 0
+func (e *EPOCH) SetRewardPerBlock(amount uint64) {
+	e.Lock()
+	e.reward = amount
+	e.Unlock()
+}
+
+// Get the EPOCH reward per block value
+func (e *EPOCH) GetRewardPerBlock() uint64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.reward
+}
+
+// Set how long the last job with work can be hashed on while the current job from the node has none, d must be above 0
+func (e *EPOCH) SetMaxJobAge(d time.Duration) (err error) {
+	if d <= 0 {
+		err = fmt.Errorf("invalid max job age %s", d)
+		return
+	}
+
+	e.Lock()
+	e.maxJobAge = d
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH maxJobAge value
+func (e *EPOCH) GetMaxJobAge() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.maxJobAge
+}
+
+// Set the maximum submissions per second EPOCH will send to the node, submissions over the rate are queued
+// until they can be sent or dropped if they would wait longer than maxJobAge. A rate of 0 is unlimited (default)
+func (e *EPOCH) SetSubmitRate(perSecond int) (err error) {
+	if perSecond < 0 {
+		err = fmt.Errorf("submit rate must be 0 or greater")
+		return
+	}
+
+	e.submits.Lock()
+	e.submits.rate = perSecond
+	e.submits.tokens = float64(perSecond)
+	e.submits.last = time.Now()
+	e.submits.Unlock()
+
+	return
+}
+
+// Get the EPOCH submit rate
+func (e *EPOCH) GetSubmitRate() int {
+	e.submits.Lock()
+	defer e.submits.Unlock()
+
+	return e.submits.rate
+}
+
+// Set the max concurrent submissions to the node, minimum of 1. Submitting is bounded separately from maxThreads so workers
+// that found a block wait for a submit slot while other workers continue hashing. Submissions are sized from maxSubmits when
+// StartGetWork connects, so a change while running takes effect on the next StartGetWork. Default is DEFAULT_MAX_SUBMITS
+func (e *EPOCH) SetMaxSubmitConcurrency(n int) (err error) {
+	if n < 1 {
+		err = fmt.Errorf("max submit concurrency must be at least 1")
+		return
+	}
+
+	e.Lock()
+	e.maxSubmits = n
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH max submit concurrency
+func (e *EPOCH) GetMaxSubmitConcurrency() int {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.maxSubmits
+}
+
+// Set if SetPort should error when port is a well-known port that is not a GetWork port, default is false which will only warn
+func (e *EPOCH) SetStrictPort(b bool) {
+	e.Lock()
+	e.strictPort = b
+	e.Unlock()
+}
+
+// Get the EPOCH strict port value
+func (e *EPOCH) GetStrictPort() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.strictPort
+}
+
+// Set if SetMaxHashes should error when maxHashes and maxThreads would result in a long batch, default is false which will only warn
+func (e *EPOCH) SetStrictMaxHashes(b bool) {
+	e.Lock()
+	e.strict = b
+	e.Unlock()
+}
+
+// Get the EPOCH strict maxHashes value
+func (e *EPOCH) GetStrictMaxHashes() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.strict
+}
+
+// Well-known ports that a GetWork server is almost certainly not on
+var wrongPorts = map[int]string{
+	22:                                     "SSH",
+	80:                                     "HTTP",
+	443:                                    "HTTPS",
+	config.Mainnet.RPC_Default_Port:        "mainnet daemon RPC",
+	config.Mainnet.Wallet_RPC_Default_Port: "mainnet wallet RPC",
+	config.Testnet.RPC_Default_Port:        "testnet daemon RPC",
+	config.Testnet.Wallet_RPC_Default_Port: "testnet wallet RPC",
+}
+
+// Check if port is a well-known port that is not a GetWork port
+func checkPort(port int) (err error) {
+	if name, ok := wrongPorts[port]; ok {
+		err = fmt.Errorf("port %d is the %s port, the GetWork port is usually %d", port, name, DEFAULT_WORK_PORT)
+	}
+
+	return
+}
+
+// Check if maxHashes spread across maxThreads will be a long single batch
+func checkBatchSize(maxHashes, maxThreads int) (err error) {
+	if maxThreads > 0 && maxHashes/maxThreads > LONG_BATCH_HASHES {
+		err = fmt.Errorf("%d maxHashes on %d threads will be a long batch, consider chunking requests", maxHashes, maxThreads)
+	}
+
+	return
+}
+
+// Parse EPOCH hashes and return as formatted string
+func HashesToString(hashes uint64) string {
+	if hashes > 10000000 {
+		return fmt.Sprintf("%.1fM", float64(hashes)/1000000)
+	} else {
+		return fmt.Sprintf("%.1fK", float64(hashes)/1000)
+	}
+}
+
+// Returns hash rate in hashes per second as a human readable string with a unit prefix
+func HashrateToString(hashPerSec float64) string {
+	switch {
+	case hashPerSec >= 1000000000:
+		return fmt.Sprintf("%.1f GH/s", hashPerSec/1000000000)
+	case hashPerSec >= 1000000:
+		return fmt.Sprintf("%.1f MH/s", hashPerSec/1000000)
+	case hashPerSec >= 1000:
+		return fmt.Sprintf("%.1f KH/s", hashPerSec/1000)
+	default:
+		return fmt.Sprintf("%.1f H/s", hashPerSec)
+	}
+}
+
+// Set the max amount of threads to be used when attempting or submitting, max is limited to total available and minimum of 1.
+// The session's workers are sized from maxThreads when StartGetWork connects, so a change while running will log that it takes
+// effect on the next StartGetWork and the session's Threads will remain the count it is using
+func (e *EPOCH) SetMaxThreads(i int) {
+	max := runtime.NumCPU()
+	if i > max {
+		i = max
+	} else if i < 1 {
+		i = 1
+	}
+
+	e.Lock()
+	e.maxThreads = i
+	threads := e.session.Threads
+	e.Unlock()
+
+	if e.isRunning() && i != threads {
+		logger.Warnf("[EPOCH] maxThreads %d will take effect on the next StartGetWork, session is using %d threads\n", i, threads)
+	}
+
+	e.configChanged("maxThreads", i)
+}
+
+// Get the EPOCH maxThreads value
+func (e *EPOCH) GetMaxThreads() int {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.maxThreads
+}
+
+// Set the region of work bytes that will be randomized for each hash, offset and length must be within MINIBLOCK_SIZE
+// and the region cannot overlap the version byte. The final work byte is always set to NONCE_FLAG and is not randomized
+// when it is inside the region, so the default region of the last DEFAULT_NONCE_BYTES has DEFAULT_NONCE_BYTES-1 random bytes.
+// A region must have at least one byte other than NONCE_FLAG_BYTE, otherwise every hash would use the same nonce
+func (e *EPOCH) SetNonceRegion(offset, length int) (err error) {
+	if offset < 1 {
+		err = fmt.Errorf("nonce region cannot overlap version byte")
+		return
+	}
+
+	if length < 1 || offset+length > block.MINIBLOCK_SIZE {
+		err = fmt.Errorf("nonce region %d:%d is outside of %d work bytes", offset, offset+length, block.MINIBLOCK_SIZE)
+		return
+	}
+
+	if offset >= NONCE_FLAG_BYTE {
+		err = fmt.Errorf("nonce region %d:%d only contains the flag byte, it has no bytes to randomize", offset, offset+length)
+		return
+	}
+
+	e.Lock()
+	e.nonce = [2]int{offset, length}
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH nonce region offset and length
+func (e *EPOCH) GetNonceRegion() (offset, length int) {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.nonce[0], e.nonce[1]
+}
+
+// Set if nonces should be deduplicated within each AttemptHashes batch, a duplicate nonce is re-rolled before
+// it is hashed. Random collisions are rare so this is only useful with a small nonce region, default is false
+func (e *EPOCH) SetNonceDedupe(b bool) {
+	e.Lock()
+	e.dedupe = b
+	e.Unlock()
+}
+
+// Get the EPOCH nonce dedupe value
+func (e *EPOCH) GetNonceDedupe() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.dedupe
+}
+
+// Set if AttemptHashes should run each hash in order on the calling goroutine rather than on worker goroutines, for debugging
+// with clear stack traces and panics and a deterministic order. Each hash still takes a semaphore slot so concurrent callers
+// remain bounded by maxThreads, and results and session totals are the same as concurrent hashing. Default is false
+func (e *EPOCH) SetSynchronous(b bool) {
+	e.Lock()
+	e.inline = b
+	e.Unlock()
+}
+
+// Get if AttemptHashes runs synchronously
+func (e *EPOCH) GetSynchronous() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.inline
+}
+
+// Set the minimum job difficulty that valid hashes are submitted for, hashing a job below d is a dry run where valid hashes
+// are not submitted. On the simulator nearly every hash is valid, a floor prevents flooding the node and keeps results closer
+// to a real network. Default is 0 which submits all valid hashes
+func (e *EPOCH) SetMinSubmitDifficulty(d uint64) {
+	e.Lock()
+	e.minDiff = d
+	e.Unlock()
+}
+
+// Get the EPOCH minimum submit difficulty
+func (e *EPOCH) GetMinSubmitDifficulty() uint64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.minDiff
+}
+
+// Check if diff is below the minimum submit difficulty
+func (e *EPOCH) belowMinDifficulty(diff *big.Int) bool {
+	floor := e.GetMinSubmitDifficulty()
+
+	return floor > 0 && diff.Cmp(new(big.Int).SetUint64(floor)) < 0
+}
+
+// Set the GetWork connection path template, for gateways and proxies that serve GetWork under a path other than
+// DEFAULT_WORK_PATH. The template must begin with / and contain exactly one %s which is replaced by the reward address,
+// a literal % is written as %%. The setting is used by the next StartGetWork
+func (e *EPOCH) SetWorkPath(template string) (err error) {
+	if _, _, err = splitWorkPath(template); err != nil {
+		return
+	}
+
+	e.Lock()
+	e.workPath = template
+	e.Unlock()
+
+	return
+}
+
+// Get the GetWork connection path template
+func (e *EPOCH) GetWorkPath() string {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.workPath
+}
+
+// Split a GetWork path template into the path before and after its %s verb
+func splitWorkPath(template string) (prefix, suffix string, err error) {
+	if !strings.HasPrefix(template, "/") {
+		err = fmt.Errorf("work path %q must begin with /", template)
+		return
+	}
+
+	var parts [2]strings.Builder
+	verbs := 0
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			parts[min(verbs, 1)].WriteByte(template[i])
+			continue
+		}
+
+		i++
+		switch {
+		case i < len(template) && template[i] == '%':
+			parts[min(verbs, 1)].WriteByte('%')
+		case i < len(template) && template[i] == 's':
+			verbs++
+		default:
+			err = fmt.Errorf("work path %q can only contain the %%s verb, use %%%% for a literal %%", template)
+			return
+		}
+	}
+
+	if verbs != 1 {
+		err = fmt.Errorf("work path %q must contain exactly one %%s for the address, found %d", template, verbs)
+		return
+	}
+
+	return parts[0].String(), parts[1].String(), nil
+}
+
+// Set if the GetWork connection uses TLS, default is true. When false StartGetWork will dial ws:// with no TLS handshake,
+// the connection is unencrypted so the reward address and all jobs and submissions can be read or altered by anyone on the
+// network path. This is only for trusted LANs, unlike the skipped certificate verification of a TLS connection which still
+// encrypts traffic. The setting is used by the next StartGetWork
+func (e *EPOCH) SetTLSEnabled(b bool) {
+	e.Lock()
+	e.tls = b
+	e.Unlock()
+}
+
+// Get if the GetWork connection uses TLS
+func (e *EPOCH) GetTLSEnabled() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.tls
+}
+
+// Set the scheme of the GetWork connection, "wss" is the default and "ws" connects without TLS for local nodes and simulators
+// that only serve plain websocket, with the same caveats as SetTLSEnabled(false). The setting is used by the next StartGetWork
+func (e *EPOCH) SetScheme(scheme string) (err error) {
+	switch scheme {
+	case "wss":
+		e.SetTLSEnabled(true)
+	case "ws":
+		e.SetTLSEnabled(false)
+	default:
+		err = fmt.Errorf("invalid scheme %q, use ws or wss", scheme)
+	}
+
+	return
+}
+
+// Get the scheme of the GetWork connection
+func (e *EPOCH) GetScheme() string {
+	if !e.GetTLSEnabled() {
+		return "ws"
+	}
+
+	return "wss"
+}
+
+// Set the TLS config of the GetWork connection, so a node with a trusted certificate can be verified. The config is cloned
+// and used for each wss connection, setting nil restores the default which does not verify the node's certificate as most
+// nodes use a self-signed certificate. The setting is used by the next StartGetWork
+func (e *EPOCH) SetTLSConfig(config *tls.Config) {
+	if config != nil {
+		config = config.Clone()
+	}
+
+	e.Lock()
+	e.tlsConfig = config
+	e.Unlock()
+}
+
+// Get a clone of the TLS config set by SetTLSConfig, nil if the default is used
+func (e *EPOCH) GetTLSConfig() *tls.Config {
+	e.RLock()
+	defer e.RUnlock()
+
+	if e.tlsConfig == nil {
+		return nil
+	}
+
+	return e.tlsConfig.Clone()
+}
 
-	return
+// Set if the GetWork connection should negotiate permessage-deflate compression, default is false. Each message is
+// compressed on its own and the job blob is random hex, so a job is only about 30% smaller for the added CPU per message.
+// This can help a high-frequency job stream over a slow link, the setting is used by the next StartGetWork
+func (e *EPOCH) SetCompression(b bool) {
+	e.Lock()
+	e.compress = b
+	e.Unlock()
 }
 
-// Get the EPOCH work port
-func GetPort() string {
-	epoch.RLock()
-	defer epoch.RUnlock()
+// Get if the GetWork connection negotiates compression
+func (e *EPOCH) GetCompression() bool {
+	e.RLock()
+	defer e.RUnlock()
 
-	return strings.Trim(epoch.port, ":")
+	return e.compress
 }
 
-// Set the max amount of hash attempts or job submissions that a single request can handle, exceeding MAX_HASHES will return error
-func SetMaxHashes(i int) (err error) {
-	if i > LIMIT_MAX_HASHES {
-		err = fmt.Errorf("cannot exceed %d hashes", LIMIT_MAX_HASHES)
-		return
-	}
-
-	epoch.Lock()
-	epoch.maxHashes = i
-	epoch.Unlock()
+// Stop listening to GetWork server
+func (e *EPOCH) StopGetWork() {
+	e.stopGetWork(false)
+}
 
-	return
+// Close gracefully stops EPOCH by sending a close message to the node before closing the GetWork connection,
+// returning any error encountered. It is safe to call when EPOCH is not active so it can be used with defer
+func (e *EPOCH) Close() (err error) {
+	return e.stopGetWork(true)
 }
 
-// Get the EPOCH maxHashes value
-func GetMaxHashes() int {
-	epoch.RLock()
-	defer epoch.RUnlock()
+// Stop listening to GetWork server, if graceful a normal close message is sent to the node before closing.
+// The connection is swapped out under lock so it is only closed once when stopGetWork is called concurrently
+func (e *EPOCH) stopGetWork(graceful bool) (err error) {
+	e.conn.Lock()
+	running := e.conn.cancel != nil
+	if running {
+		e.conn.cancel()
+		e.conn.cancel = nil
+	}
+	if e.conn.abort != nil {
+		e.conn.abort()
+	}
+	ws := e.conn.ws
+	e.conn.ws = nil
+	e.conn.Unlock()
 
-	return epoch.maxHashes
-}
+	if ws != nil {
+		if graceful {
+			err = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		}
 
-// Parse EPOCH hashes and return as formatted string
-func HashesToString(hashes uint64) string {
-	if hashes > 10000000 {
-		return fmt.Sprintf("%.1fM", float64(hashes)/1000000)
-	} else {
-		return fmt.Sprintf("%.1fK", float64(hashes)/1000)
+		if cErr := ws.Close(); err == nil {
+			err = cErr
+		}
 	}
-}
 
-// Set the max amount of threads to be used when attempting or submitting, max is limited to total available and minimum of 1
-func SetMaxThreads(i int) {
-	max := runtime.NumCPU()
-	if i > max {
-		i = max
-	} else if i < 1 {
-		i = 1
+	// Connection state is only cleared by the call that stopped the connection,
+	// a StartGetWork that is still setting up a new connection is not affected
+	if running {
+		e.Lock()
+		e.semaphore = nil
+		e.submitting = nil
+		e.submitCh = nil
+		e.stopStream = nil
+		e.streamed = nil
+		e.Unlock()
+
+		e.endpointDown(true)
+		e.disconnected()
+		e.closeJobUpdates()
 	}
 
-	epoch.Lock()
-	epoch.maxThreads = i
-	epoch.Unlock()
+	return
 }
 
-// Get the EPOCH maxThreads value
-func GetMaxThreads() int {
-	epoch.RLock()
-	defer epoch.RUnlock()
+// Start listening to GetWork server, if address is empty string the address set by SetAddress will be used or ErrNoAddress returned if it is not set,
+// endpoint is a DERO daemon address and will use the port defined by SetPort() to connect to GetWork,
+// when StartGetWork is successfully connected it will set the EPOCH session totals to zero
+func (e *EPOCH) StartGetWork(address, endpoint string) (err error) {
+	e.RLock()
+	port := e.port
+	e.RUnlock()
 
-	return epoch.maxThreads
+	return e.startGetWork(address, endpoint, port, nil)
 }
 
-// Stop listening to GetWork server
-func StopGetWork() {
-	if IsActive() {
-		epoch.conn.ws.Close()
-		epoch.conn.ws = nil
+// StartGetWorkOnPort is StartGetWork connecting to the GetWork server on port instead of the port defined by SetPort,
+// so endpoints exposing GetWork on different ports can be used without changing the EPOCH port
+func (e *EPOCH) StartGetWorkOnPort(address, endpoint string, port int) (err error) {
+	if port < 1 || port > 65535 {
+		err = fmt.Errorf("invalid EPOCH port")
+		return
 	}
+
+	return e.startGetWork(address, endpoint, fmt.Sprintf(":%d", port), nil)
 }
 
-// Start listening to GetWork server, if address is empty string epoch.address will be used,
-// endpoint is a DERO daemon address and will use the port defined by SetPort() to connect to GetWork,
-// when StartGetWork is successfully connected it will set the EPOCH session totals to zero
-func StartGetWork(address, endpoint string) (err error) {
-	if IsActive() {
-		err = fmt.Errorf("already running")
+// Start listening to GetWork server at endpoint's host on port, poolEndpoints is
+// the endpoints to select from when reconnecting if started by StartGetWorkPool
+func (e *EPOCH) startGetWork(address, endpoint, port string, poolEndpoints []string) (err error) {
+	// Only one StartGetWork can connect at a time
+	e.conn.Lock()
+	if e.conn.starting || e.conn.cancel != nil {
+		e.conn.Unlock()
+		err = ErrAlreadyRunning
+		return
+	}
+	e.conn.starting = true
+	connectCtx, abort := context.WithCancel(context.Background())
+	e.conn.abort = abort
+	e.conn.Unlock()
+
+	defer func() {
+		e.conn.Lock()
+		e.conn.starting = false
+		e.conn.abort = nil
+		e.conn.Unlock()
+		abort()
+	}()
+
+	if address == "" && e.GetAddress() == "" {
+		err = ErrNoAddress
 		return
 	}
 
@@ -250,95 +1232,337 @@ func StartGetWork(address, endpoint string) (err error) {
 	}
 
 	if address != "" {
-		err = SetAddress(address)
+		err = e.SetAddress(address)
 		if err != nil {
 			err = fmt.Errorf("could not set address: %s", err)
 			return
 		}
 	}
 
-	_, err = globals.ParseValidateAddress(epoch.address)
+	// Globals are read here on the host's goroutine, the connection's goroutines use the captured network
+	network := globals.Config.Name
+	_, err = globals.ParseValidateAddress(e.address)
+	if err != nil {
+		err = fmt.Errorf("address %q is not valid: %s", e.address, err)
+		return
+	}
+
+	// Workers would deadlock on a zero capacity semaphore
+	if e.GetMaxThreads() < 1 {
+		err = fmt.Errorf("invalid thread count %d, need at least 1", e.GetMaxThreads())
+		return
+	}
+
+	endpoint = host + port
+
+	scheme := e.transportScheme()
+
+	// Session's GetWork url for an endpoint, reconnects use the same scheme, path and address
+	addr := e.address
+	path := e.GetWorkPath()
+	target := func(endpoint string) string {
+		return workURL(scheme, endpoint, path, addr)
+	}
+
+	ws, err := e.connect(connectCtx, target(endpoint))
 	if err != nil {
-		err = fmt.Errorf("address %q is not valid: %s", epoch.address, err)
 		return
 	}
 
-	endpoint = host + epoch.port
+	logger.Printf("[EPOCH] Connected to %s\n", target(endpoint))
+
+	// The session, semaphore and submission stream are set up before the connection is marked usable and its goroutines
+	// are started, so a connection that closes straight away is stopped with consistent state.
+	// All goroutines for the connection are ended when ctx is cancelled by StopGetWork
+	ctx, cancel := context.WithCancel(context.Background())
+	streamCtx, stopStream := context.WithCancel(ctx)
+	streamed := make(chan struct{})
+	submitCh := make(chan Submit_Params, SUBMIT_CHANNEL_SIZE)
+
+	e.Lock()
+	threads := e.maxThreads
+	e.session.Threads = threads
+	e.session.Hashes = 0
+	e.session.MiniBlocks = 0
+	e.session.Accepted = 0
+	e.session.Rejected = 0
+	e.session.MissedHeights = 0
+	e.session.Unknown = 0
+	e.session.Deduped = 0
+	e.session.Started = time.Now()
+	e.restoreSession()
+	e.limited = false
+	e.draining = false
+	e.network = network
+	e.hostNet = network
+	e.seen = versionSet{}
+	e.orphans.reset()
+	e.accepted = 0
+	e.rejected = 0
+	e.difficulty.SetInt64(0)
+	e.hashrate.reset()
+	e.profile.reset()
+	e.memory.reset()
+	e.semaphore = make(chan struct{}, threads)
+	e.submitting = make(chan struct{}, e.maxSubmits)
+	e.submitCh = submitCh
+	e.stopStream = stopStream
+	e.streamed = streamed
+	e.Unlock()
+	e.openJobUpdates()
+
+	logger.Printf("[EPOCH] Will use %d threads\n", threads)
+
+	e.conn.Lock()
+	e.conn.cancel = cancel
+	e.conn.done = ctx.Done()
+	e.conn.ws = ws
+	e.conn.scheme = scheme
+	e.conn.Unlock()
+
+	e.poolConnected(poolEndpoints, endpoint)
+	e.endpointConnected(endpoint)
+	e.stateChanged(STATE_CONNECTED)
+
+	go e.superviseJobs(ctx, ws, endpoint, target)
+	go e.consumeSubmissions(streamCtx, submitCh, streamed)
 
-	u := url.URL{Scheme: "wss", Host: endpoint, Path: "/ws/" + epoch.address}
+	return
+}
 
-	dialer := websocket.DefaultDialer
-	dialer.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: true,
+// Get the GetWork url for address at endpoint using the path template, the address is escaped as a single path segment
+// so any characters that are special in a URL can not change the request or the address the node receives
+func workURL(scheme, endpoint, path, address string) string {
+	// The template was validated when set
+	prefix, suffix, _ := splitWorkPath(path)
+	escaped := func(p string) string {
+		return (&url.URL{Path: p}).EscapedPath()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	u := url.URL{Scheme: scheme, Host: endpoint, Path: prefix + address + suffix, RawPath: escaped(prefix) + url.PathEscape(address) + escaped(suffix)}
+
+	return u.String()
+}
+
+// Create the dialer for the GetWork server
+func (e *EPOCH) newDialer() (dialer websocket.Dialer) {
+	// Copied so the shared default dialer is not modified, a ws url does not use the TLS config
+	dialer = *websocket.DefaultDialer
+	dialer.TLSClientConfig = e.clientTLSConfig()
+	dialer.EnableCompression = e.GetCompression()
+
+	return
+}
+
+// Dial the GetWork server at url
+func (e *EPOCH) dial(ctx context.Context, url string) (ws *websocket.Conn, err error) {
+	dialer := e.newDialer()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	epoch.conn.ws, _, err = websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	ws, _, err = dialer.DialContext(ctx, url, nil)
 	if err != nil {
-		epoch.conn.ws = nil
 		return
 	}
 
-	logger.Printf("[EPOCH] Connected to %s\n", u.String())
-	logger.Printf("[EPOCH] Will use %d threads\n", epoch.maxThreads)
+	// Only compresses writes if the server accepted the extension
+	ws.EnableWriteCompression(dialer.EnableCompression)
 
-	epoch.session.Hashes = 0
-	epoch.session.MiniBlocks = 0
-	epoch.semaphore = make(chan struct{}, epoch.maxThreads)
+	return
+}
 
-	go func() {
-		defer StopGetWork()
-		var result rpc.GetBlockTemplate_Result
-		for {
-			if err = epoch.conn.ws.ReadJSON(&result); err != nil {
-				if !strings.Contains(err.Error(), "closed network connection") {
-					logger.Errorf("[EPOCH] connection error: %s\n", err)
-				}
-				break
+// Supervise the GetWork connection, jobs are read from ws until there is a read error. If the read error is allowed by
+// the ReconnectPolicy the connection will be re-established, otherwise the connection is stopped. It exits when ctx is cancelled
+func (e *EPOCH) superviseJobs(ctx context.Context, ws workConn, endpoint string, target func(string) string) {
+	for {
+		interval := e.GetKeepalive()
+		stop := keepalive(ws, interval)
+		err := e.readJobs(ctx, ws, interval)
+		stop()
+		// StopGetWork cancels ctx before closing ws, so a read error from an intentional close exits silently
+		if ctx.Err() != nil {
+			break
+		}
+
+		if errors.Is(err, ErrNetworkChanged) || !shouldReconnect(e.GetReconnectPolicy(), err) {
+			logger.Errorf("[EPOCH] connection error: %s\n", err)
+			e.StopGetWork()
+			break
+		}
+
+		logger.Errorf("[EPOCH] connection error: %s, reconnecting\n", err)
+		e.stateChanged(STATE_RECONNECTING)
+		e.endpointDown(false)
+		e.jobs.markStale()
+		if ws, endpoint = e.reconnect(ctx, ws, endpoint, target); ws == nil {
+			if ctx.Err() == nil {
+				logger.Errorf("[EPOCH] Reconnect attempts exhausted, stopping\n")
+				e.StopGetWork()
 			}
+			break
+		}
+		e.poolSelected(endpoint)
+		e.endpointReconnected(endpoint)
+		e.stateChanged(STATE_CONNECTED)
+	}
+
+	logger.Printf("[EPOCH] Closed\n")
+}
 
-			if lastError := epoch.newJob(result); lastError != "" {
-				logger.Errorf("[EPOCH] Job error: %s\n", lastError)
+// Read jobs from ws until there is a read error and return it, a frame that can not be decoded is skipped keeping the current job.
+// A frame read after ctx is cancelled is dropped so a stopped connection can not install a job. Each frame extends the keepalive deadline
+func (e *EPOCH) readJobs(ctx context.Context, ws workConn, interval time.Duration) (err error) {
+	var jobErr jobErrors
+	for {
+		// Frames are read whole rather than with ReadJSON, a decode error from ReadJSON can not be told apart from a
+		// connection lost mid-frame, so a corrupt frame could not be skipped without risking a dead connection being kept
+		var message []byte
+		if _, message, err = ws.ReadMessage(); err != nil {
+			if interval > 0 && isKeepaliveTimeout(err) {
+				err = fmt.Errorf("no traffic received within keepalive interval %s: %w", interval, err)
 			}
+			return
 		}
 
-		logger.Printf("[EPOCH] Closed\n")
-	}()
+		extendDeadline(ws, interval)
 
-	return
-}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
 
-// GetSession returns the current EPOCH session statistics, it will wait while EPOCH is processing and return error if result is not found before timeout duration
-func GetSession(timeout time.Duration) (session GetSessionEPOCH_Result, err error) {
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+		e.rawMessage(message)
 
-	for {
-		select {
-		case <-timer.C:
-			err = fmt.Errorf("could not get EPOCH session after %s", timeout)
+		if err = e.checkNetwork(); err != nil {
 			return
-		default:
-			if !IsProcessing() {
-				session = epoch.session
-				return
-			}
+		}
+
+		// Each frame is decoded into a new result so no fields from a previous job can remain
+		var result rpc.GetBlockTemplate_Result
+		if err := json.Unmarshal(message, &result); err != nil {
+			logger.Errorf("[EPOCH] Invalid job: %s\n", err)
+			continue
+		}
 
-			time.Sleep(time.Millisecond * 25)
+		jobErr.log(e.newJob(result), time.Now())
+	}
+}
+
+// Consecutive identical job errors of a connection, so a node that keeps sending the same error does not flood the log
+type jobErrors struct {
+	last       string    // last is the most recent job error, empty if the last job had none
+	repeats    int       // repeats is how many times last has been received since it was logged
+	summarized time.Time // summarized is when last was logged
+}
+
+// Log lastError if it is not the same as the previous job's error, an error that keeps repeating
+// is only logged as a summary each JOB_ERROR_SUMMARY
+func (j *jobErrors) log(lastError string, now time.Time) {
+	switch {
+	case lastError == "":
+		j.last = ""
+	case lastError != j.last:
+		logger.Errorf("[EPOCH] Job error: %s\n", lastError)
+		j.last, j.repeats, j.summarized = lastError, 0, now
+	default:
+		j.repeats++
+		if now.Sub(j.summarized) >= JOB_ERROR_SUMMARY {
+			logger.Errorf("[EPOCH] Job error still occurring, repeated %d times in %s: %s\n", j.repeats, now.Sub(j.summarized).Round(time.Second), lastError)
+			j.repeats, j.summarized = 0, now
 		}
 	}
 }
 
-// Compute POW hash from a job template and return variables for block submission
-func powHash() (job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.MINIBLOCK_SIZE]byte, diff big.Int, err error) {
-	var random_buf [12]byte
-	rand.Read(random_buf[:])
+// GetSession returns a consistent snapshot of the current EPOCH session statistics, batches add their totals to
+// the session under lock so it will not wait for processing to finish. The timeout is kept for compatibility and is unused
+func (e *EPOCH) GetSession(timeout time.Duration) (session GetSessionEPOCH_Result, err error) {
+	e.RLock()
+	session = e.sessionSnapshot()
+	e.RUnlock()
+
+	return
+}
+
+// Copy the current session, epoch must be locked by the caller
+func (e *EPOCH) sessionSnapshot() (session GetSessionEPOCH_Result) {
+	session = e.session
+	session.CumulativeDifficulty = e.difficulty.String()
+	session.Reward = session.Accepted * e.reward
+	session.SuccessRatio = successRatio(session.Accepted, session.Rejected)
+	session.OrphanRate = e.orphans.rate()
+	session.CurrentHashrate = math.Round(e.hashrate.current()*100) / 100
+	session.HashFuncNs, session.OverheadNs = e.profile.averages()
+	session.BatchAlloc, session.HeapInUse = e.memory.averages()
+	session.SubmitQueue = e.pressure.depth() + len(e.submitCh)
+	session.BlobVersions = e.seenVersions()
+
+	return
+}
+
+// SubmitSuccessRatio returns the ratio of accepted blocks to all blocks the node has reported as accepted or rejected
+// during the session, a low ratio indicates high stale rates. If the node has not reported any blocks it returns 0
+func (e *EPOCH) SubmitSuccessRatio() float64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	return successRatio(e.session.Accepted, e.session.Rejected)
+}
+
+// Get accepted / (accepted+rejected), returning 0 if there are none
+func successRatio(accepted, rejected uint64) float64 {
+	total := accepted + rejected
+	if total == 0 {
+		return 0
+	}
+
+	return float64(accepted) / float64(total)
+}
+
+// Add hashes performed over duration and miniblocks to the session totals and return the updated session,
+// reserved is the hashes the attempt reserved against the session hash limit which are released. Session totals
+// are only changed with e locked so concurrent attempts do not lose updates, and GetSession reads them together
+func (e *EPOCH) addSession(hashes, reserved uint64, duration time.Duration, miniBlocks int) GetSessionEPOCH_Result {
+	e.Lock()
+	defer e.Unlock()
+
+	e.session.Hashes += hashes
+	e.pending -= reserved
+	e.hashrate.add(hashes, duration, time.Now())
+	e.session.MiniBlocks += miniBlocks
+
+	return e.sessionSnapshot()
+}
+
+// Add the difficulty of a submitted miniblock to the session's cumulative difficulty
+func (e *EPOCH) addDifficulty(diff *big.Int) {
+	e.Lock()
+	e.difficulty.Add(&e.difficulty, diff)
+	e.Unlock()
+}
+
+// Warmup runs throwaway hashes on maxThreads workers so the AstroBWTv3 scratch buffers are initialized before any measured hashing,
+// it can optionally be called after StartGetWork to have the first AttemptHashes measure a steady state hash rate
+func (e *EPOCH) Warmup() {
+	threads := e.GetMaxThreads()
+	hashRate(context.Background(), threads, threads*WARMUP_HASHES)
+}
+
+// Compute POW hash from a job template and return variables for block submission,
+// if used is not nil the work nonce will be re-rolled until it has not been used within the batch
+func (e *EPOCH) powHash(used *nonces) (job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.MINIBLOCK_SIZE]byte, diff big.Int, err error) {
+	offset, length := e.GetNonceRegion()
+	random := offset + length
+	if random > NONCE_FLAG_BYTE {
+		random = NONCE_FLAG_BYTE // the flag byte is set, not randomized
+	}
 
 	// nonce_buf := work[block.MINIBLOCK_SIZE-5:] // since slices are linked, it modifies parent
 
-	job = epoch.getJob()
+	job, err = e.getWorkJob()
+	if err != nil {
+		return
+	}
 
 	n, err := hex.Decode(work[:], []byte(job.Blockhashing_blob))
 	if err != nil || n != block.MINIBLOCK_SIZE {
@@ -346,36 +1570,135 @@ func powHash() (job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.M
 		return
 	}
 
-	copy(work[block.MINIBLOCK_SIZE-12:], random_buf[:]) // add more randomization in the mix
-	work[block.MINIBLOCK_SIZE-1] = byte(1)
+	for r := 0; ; r++ {
+		rand.Read(work[offset:random]) // add more randomization in the mix
+		work[NONCE_FLAG_BYTE] = NONCE_FLAG
+
+		if used == nil || used.add(work[offset:offset+length]) {
+			break
+		}
+
+		if r >= DEDUPE_RETRIES {
+			err = fmt.Errorf("could not find an unused nonce after %d attempts", r)
+			return
+		}
+	}
 
 	diff.SetString(job.Difficulty, 10)
 
-	if work[0]&0xf != 1 { // check  version
-		err = fmt.Errorf("unknown version, please check for updates %v", work[0]&0x1f)
+	if version := work[0] & MAX_BLOB_VERSION; !e.acceptsVersion(version) { // check  version
+		err = fmt.Errorf("unknown version, please check for updates %v", version)
 		return
 	}
 
 	// binary.BigEndian.PutUint32(nonce_buf, uint32(1))
 
+	if e.profile.on() {
+		start := time.Now()
+		powhash = astrobwtv3.AstroBWTv3(work[:])
+		e.profile.addHashFunc(time.Since(start))
+		return
+	}
+
 	powhash = astrobwtv3.AstroBWTv3(work[:])
 
 	return
 }
 
-// Check if powhash is valid and submit it as a miniblock to connected daemon if so
-func submitBlock(job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.MINIBLOCK_SIZE]byte, diff big.Int) (valid bool, err error) {
-	if !IsActive() {
+// Check if powhash is valid and submit it as a miniblock to connected daemon if so. The reward address is not part of the
+// submission, the daemon embeds the connection's address key hash in each job's blob so rewards go to the connected address
+func (e *EPOCH) submitBlock(batch uint64, job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.MINIBLOCK_SIZE]byte, diff big.Int) (valid bool, err error) {
+	if !e.IsActive() {
 		err = fmt.Errorf("connection is closed")
 		return
 	}
 
-	if blockchain.CheckPowHashBig(powhash, &diff) { // note we are doing a local, NW might have moved meanwhile
-		logger.Printf("[EPOCH] Submitting valid miniblock POW hash, difficulty: %s height: %d\n", job.Difficulty, job.Height)
-		epoch.conn.Lock()
-		defer epoch.conn.Unlock()
-		if err = epoch.conn.ws.WriteJSON(rpc.SubmitBlock_Params{JobID: job.JobID, MiniBlockhashing_blob: fmt.Sprintf("%x", work[:])}); err == nil {
+	if checkPowHash(powhash, &diff) { // note we are doing a local, NW might have moved meanwhile
+		// A job below the minimum submit difficulty is a dry run
+		if e.belowMinDifficulty(&diff) {
+			return
+		}
+
+		// A fixed job is a dry run, its template is not the node's current job
+		if e.isFixedJob(job) {
+			logger.Printf(batchLog(batch)+"Valid miniblock POW hash of fixed job %s, not submitting\n", job.JobID)
+			return
+		}
+
+		// Concurrent batches can find the same work, an identical miniblock would only be rejected by the node
+		if !e.recent.add(work, time.Now()) {
+			e.addDeduped()
+			logger.Warnf(batchLog(batch)+"Duplicate miniblock for height %d was already submitted\n", job.Height)
+			return
+		}
+
+		defer func() {
+			if !valid {
+				e.recent.remove(work)
+			}
+			e.logSubmission(batch, job, work, valid, err)
+		}()
+
+		if err = e.checkNetwork(); err != nil {
+			e.StopGetWork()
+			return
+		}
+
+		// Submissions wait for a slot of their own so writes to the node are bounded by maxSubmits rather than maxThreads
+		submitting := e.getSubmitSemaphore()
+		if submitting == nil {
+			err = fmt.Errorf("connection is closed")
+			return
+		}
+		e.enterSubmitQueue()
+		submitting <- struct{}{}
+		e.leaveSubmitQueue()
+		defer func() { <-submitting }()
+
+		wait, ok := e.submits.reserve(e.GetMaxJobAge())
+		if !ok {
+			logger.Warnf(batchLog(batch)+"Submit rate exceeded, dropping miniblock for height: %d\n", job.Height)
+			return
+		}
+
+		if wait > 0 {
+			time.Sleep(wait)
+			if !e.IsActive() {
+				err = fmt.Errorf("connection is closed")
+				return
+			}
+		}
+
+		logger.Printf(batchLog(batch)+"Submitting valid miniblock POW hash, difficulty: %s height: %d\n", job.Difficulty, job.Height)
+		// The write is made outside of the connection lock so a slow node does not block StopGetWork or IsActive,
+		// closing ws ends a write in progress
+		e.conn.Lock()
+		ws := e.conn.ws
+		e.conn.Unlock()
+		if ws == nil {
+			err = fmt.Errorf("connection is closed")
+			return
+		}
+
+		e.conn.write.Lock()
+		// In confirm mode the submission is queued before it is written so the node can not report it first
+		confirm, timeout := e.newConfirmation()
+		if err = ws.SetWriteDeadline(time.Now().Add(SUBMIT_TIMEOUT)); err == nil {
+			err = ws.WriteJSON(rpc.SubmitBlock_Params{JobID: job.JobID, MiniBlockhashing_blob: fmt.Sprintf("%x", work[:])})
+		}
+		e.conn.write.Unlock()
+		if err != nil && confirm != nil {
+			confirm.cancel()
+		}
+
+		if err == nil {
 			valid = true
+			e.addDifficulty(&diff)
+			e.endpointBlock()
+			e.blockFound(job, powhash, work, &diff)
+			if confirm != nil {
+				confirm.wait(batch, job, timeout)
+			}
 		}
 	}
 
@@ -383,128 +1706,327 @@ func submitBlock(job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.
 }
 
 // AttemptHashes performs the POW for the number of hashes and submits valid hashes as miniblocks to the connected node,
-// when it is called it increases the session total for hashes and blocks as per the result
-func AttemptHashes(hashes int) (result EPOCH_Result, err error) {
-	if !IsActive() {
-		err = fmt.Errorf("epoch is not active")
+// when it is called it increases the session total for hashes and blocks as per the result. A worker goroutine is only
+// spawned after it has acquired a semaphore slot, so total workers across all concurrent callers is bounded to maxThreads
+func (e *EPOCH) AttemptHashes(hashes int) (result EPOCH_Result, err error) {
+	return e.AttemptHashesContext(context.Background(), hashes)
+}
+
+// AttemptHashesContext is AttemptHashes that stops dispatching hashes when ctx is done, hashes already running are finished
+// and the result of the hashes performed is returned with ctx.Err(). The hashes performed are added to the session
+func (e *EPOCH) AttemptHashesContext(ctx context.Context, hashes int) (result EPOCH_Result, err error) {
+	result, _, err = e.attemptHashes(ctx, hashes)
+
+	return
+}
+
+// Perform AttemptHashesContext and return the session as it was when the attempt's totals were added
+func (e *EPOCH) attemptHashes(ctx context.Context, hashes int) (result EPOCH_Result, session GetSessionEPOCH_Result, err error) {
+	result.BatchID = e.nextBatch()
+
+	if !e.IsActive() {
+		err = ErrNotActive
 		return
 	}
 
-	if hashes > GetMaxHashes() {
-		err = fmt.Errorf("hashes exceeds maxHashes %d/%d", hashes, epoch.maxHashes)
+	// The limit is taken once, lowering maxHashes does not affect a batch that is already running
+	result.MaxHashes = e.GetMaxHashes()
+	if hashes > result.MaxHashes {
+		err = fmt.Errorf("hashes exceeds maxHashes %d/%d", hashes, result.MaxHashes)
 		return
 	}
 
-	setProcessing(true)
-	defer setProcessing(false)
+	semaphore := e.getSemaphore()
+	if semaphore == nil {
+		err = ErrNotActive
+		return
+	}
 
-	var wg sync.WaitGroup
+	if err = e.reserveHashes(uint64(hashes)); err != nil {
+		return
+	}
+
+	e.setProcessing(true)
+	defer e.setProcessing(false)
+
+	batch := getBatchState()
+	defer putBatchState(batch)
+
+	wg := &batch.wg
+	workErr := &batch.workErr
+
+	var used *nonces
+	if e.GetNonceDedupe() {
+		used = &batch.used
+	}
+
+	cpus := e.getCPUAffinity()
+	inline := e.GetSynchronous()
+
+	stop := e.joinHashing()
+	defer stop.batches.Done()
+
+	mem, measuring := e.memory.start()
 
 	i := 0
 	now := time.Now()
 
+dispatch:
 	for i = 0; i < hashes; i++ {
-		if result.Error != nil {
+		if workErr.get() != nil || stop.stopped() || ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-stop.done:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		// A worker may have errored, Shutdown, StopHashing or ctx ended while waiting for a slot
+		if workErr.get() != nil || e.isDraining() || stop.stopped() || ctx.Err() != nil {
+			<-semaphore
 			break
 		}
 
-		epoch.semaphore <- struct{}{}
+		start := time.Now()
+		profiling := e.profile.on()
 
 		wg.Add(1)
-		go func() {
+		hash := func() {
 			defer func() {
-				<-epoch.semaphore
+				if profiling {
+					e.profile.addWork(time.Since(start))
+				}
+				<-semaphore
 				wg.Done()
 			}()
 
-			job, powhash, work, diff, err := powHash()
+			var job rpc.GetBlockTemplate_Result
+			var powhash [32]byte
+			var work [block.MINIBLOCK_SIZE]byte
+			var diff big.Int
+			var err error
+			pinned(cpus, func() { job, powhash, work, diff, err = e.powHash(used) })
 			if err != nil {
-				result.Error = err
+				workErr.set(err)
 				return
 			}
 
-			valid, err := submitBlock(job, powhash, work, diff)
+			valid, err := e.submitBlock(result.BatchID, job, powhash, work, diff)
 			if err != nil {
-				result.Error = err
+				workErr.set(err)
 				return
 			}
 
 			if valid {
-				result.Submitted++
+				batch.submitted.Add(1)
 			}
-		}()
+		}
+
+		if inline {
+			hash()
+			continue
+		}
+
+		go hash()
 	}
 
 	wg.Wait()
+	if measuring {
+		e.memory.add(&mem)
+	}
+
+	result.Submitted = int(batch.submitted.Load())
+	result.Error = workErr.get()
+	if result.Error != nil {
+		logger.Errorf(batchLog(result.BatchID)+"%s\n", result.Error)
+	}
 
 	duration := time.Since(now)
-	result.Duration = duration.Milliseconds()
+	result.Duration = durationMs(duration)
 
 	h := uint64(i)
 	result.Hashes = h
-	epoch.session.Hashes += h
-	epoch.session.MiniBlocks += result.Submitted
-	hashPerSecond := float64(h) / duration.Seconds()
-	result.HashPerSec = math.Round(hashPerSecond*100) / 100
+	session = e.addSession(h, uint64(hashes), duration, result.Submitted)
+	result.HashPerSec = hashesPerSecond(h, duration)
+
+	if i < hashes && ctx.Err() != nil {
+		err = ctx.Err()
+	}
 
 	return
 }
 
+// Get the next BatchID, starting at 1
+func (e *EPOCH) nextBatch() uint64 {
+	e.Lock()
+	defer e.Unlock()
+
+	e.batches++
+
+	return e.batches
+}
+
+// Log prefix for batch, a batch of 0 is a submission that was not part of a batch
+func batchLog(batch uint64) string {
+	if batch == 0 {
+		return "[EPOCH] "
+	}
+
+	return fmt.Sprintf("[EPOCH] Batch %d: ", batch)
+}
+
+// Get d in milliseconds with microsecond precision so short batches have a duration
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Get the hash rate of hashes over d rounded to two decimals, using the same microsecond precision as durationMs
+func hashesPerSecond(hashes uint64, d time.Duration) float64 {
+	us := d.Microseconds()
+	if us < 1 {
+		return 0
+	}
+
+	return math.Round(float64(hashes)/(float64(us)/1e6)*100) / 100
+}
+
+// SubmitRefs reconstructs each SubmitRef using the job EPOCH is hashing on and submits them with SubmitHashes.
+// The POW hash is recomputed from the work, if any ref's JobID is not the current job nothing is submitted
+func (e *EPOCH) SubmitRefs(refs []SubmitRef) (result EPOCH_Result, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
+		return
+	}
+
+	if len(refs) > e.GetMaxHashes() {
+		err = fmt.Errorf("requested submission exceeds maxHashes %d/%d", e.GetMaxHashes(), len(refs))
+		return
+	}
+
+	job, err := e.getWorkJob()
+	if err != nil {
+		return
+	}
+
+	params := make([]Submit_Params, len(refs))
+	for i, ref := range refs {
+		if ref.JobID != job.JobID {
+			err = fmt.Errorf("job %q is no longer current", ref.JobID)
+			return
+		}
+
+		p := &params[i]
+		p.Job = job
+
+		n, dErr := hex.Decode(p.EpochWork[:], []byte(ref.WorkHex))
+		if dErr != nil || n != block.MINIBLOCK_SIZE {
+			err = fmt.Errorf("work for job %q could not be decoded %d %v", ref.JobID, n, dErr)
+			return
+		}
+
+		if _, ok := p.Difficulty.SetString(ref.DifficultyStr, 10); !ok {
+			err = fmt.Errorf("invalid submission difficulty %q", ref.DifficultyStr)
+			return
+		}
+
+		p.PowHash = astrobwtv3.AstroBWTv3(p.EpochWork[:])
+		p.Signature = ref.Signature
+	}
+
+	return e.SubmitHashes(params)
+}
+
 // SubmitHashes checks and submits valid pre computed hashes as miniblocks to the connected node,
-// only the block session total will be increased when it is called
-func SubmitHashes(params []Submit_Params) (result EPOCH_Result, err error) {
-	if !IsActive() {
-		err = fmt.Errorf("epoch is not active")
+// only the block session total will be increased when it is called. The result Hashes is the count of params that a
+// submission was attempted for, including any that errored, params after the first error are not attempted.
+// If a worker key is set with SetWorkerKey, a submission without a valid Signature errors before its POW hash is checked
+func (e *EPOCH) SubmitHashes(params []Submit_Params) (result EPOCH_Result, err error) {
+	result.BatchID = e.nextBatch()
+
+	if !e.IsActive() {
+		err = ErrNotActive
 		return
 	}
 
 	l := len(params)
-	if l > GetMaxHashes() {
-		err = fmt.Errorf("requested submission exceeds maxHashes %d/%d", epoch.maxHashes, l)
+	result.MaxHashes = e.GetMaxHashes()
+	if l > result.MaxHashes {
+		err = fmt.Errorf("requested submission exceeds maxHashes %d/%d", l, result.MaxHashes)
 		return
 	}
 
-	setProcessing(true)
-	defer setProcessing(false)
+	semaphore := e.getSemaphore()
+	if semaphore == nil {
+		err = ErrNotActive
+		return
+	}
+
+	e.setProcessing(true)
+	defer e.setProcessing(false)
 
 	var wg sync.WaitGroup
+	var workErr workError
+
+	// Only the counter is shared, each submission is still checked and written concurrently
+	var submitted atomic.Int64
+
+	key := e.getWorkerKey()
 
 	i := 0
 	now := time.Now()
 
 	for _, p := range params {
-		if result.Error != nil {
+		if workErr.get() != nil {
 			break
 		}
 
-		epoch.semaphore <- struct{}{}
+		semaphore <- struct{}{}
+
+		if workErr.get() != nil || e.isDraining() {
+			<-semaphore
+			break
+		}
 
+		i++
 		wg.Add(1)
 		go func(p Submit_Params) {
 			defer func() {
-				<-epoch.semaphore
+				<-semaphore
 				wg.Done()
 			}()
 
-			valid, err := submitBlock(p.Job, p.PowHash, p.EpochWork, p.Difficulty)
+			if err := checkSubmission(&p, key); err != nil {
+				workErr.set(err)
+				return
+			}
+
+			valid, err := e.submitBlock(result.BatchID, p.Job, p.PowHash, p.EpochWork, p.Difficulty)
 			if err != nil {
-				result.Error = err
+				workErr.set(err)
 				return
 			}
 
-			i++
 			if valid {
-				result.Submitted++
+				submitted.Add(1)
 			}
 		}(p)
 	}
 
 	wg.Wait()
+	result.Submitted = int(submitted.Load())
+	result.Error = workErr.get()
+	if result.Error != nil {
+		logger.Errorf(batchLog(result.BatchID)+"%s\n", result.Error)
+	}
 
-	result.Duration = time.Since(now).Milliseconds() // result will likely be in µs so 0
+	result.Duration = durationMs(time.Since(now))
 	result.Hashes = uint64(i)
 
-	epoch.session.MiniBlocks += result.Submitted
+	e.addSession(0, 0, 0, result.Submitted)
 
 	return
 }
@@ -2,21 +2,37 @@ package epoch
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"math/big"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/deroproject/derohe/astrobwt/astrobwtv3"
 	"github.com/deroproject/derohe/block"
+	"github.com/deroproject/derohe/blockchain"
+	"github.com/deroproject/derohe/config"
 	"github.com/deroproject/derohe/cryptography/crypto"
 	"github.com/deroproject/derohe/globals"
 	"github.com/deroproject/derohe/rpc"
 	"github.com/deroproject/derohe/walletapi"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -114,11 +130,13 @@ func TestEPOCH(t *testing.T) {
 		assert.False(t, IsProcessing(), "Should not be processing when offline")
 		_, err = GetSessionEPOCH(context.Background())
 		assert.Error(t, err, "GetSessionEPOCH should error when offline")
-		_, err = submitBlock(rpc.GetBlockTemplate_Result{}, [32]byte{}, [block.MINIBLOCK_SIZE]byte{}, big.Int{})
+		_, err = epoch.submitBlock(0, rpc.GetBlockTemplate_Result{}, [32]byte{}, [block.MINIBLOCK_SIZE]byte{}, big.Int{})
 		assert.Error(t, err, "submitBlock should error when offline")
 		// powHash error
-		epoch.jobs.job.Blockhashing_blob = "invalid" // won't decode
-		_, _, _, _, err = powHash()
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{job: rpc.GetBlockTemplate_Result{Blockhashing_blob: "invalid"}}) // won't decode
+		epoch.jobs.Unlock()
+		_, _, _, _, err = epoch.powHash(nil)
 		assert.Error(t, err, "powHash should error with invalid Blockhashing_blob")
 		// HashesToString
 		thousandFormat := uint64(10100)
@@ -128,11 +146,11 @@ func TestEPOCH(t *testing.T) {
 		// Timeout JobIsReady
 		err = JobIsReady(time.Second)
 		assert.Error(t, err, "JobIsReady should error on timeout")
-		// Timeout GetSession
-		setProcessing(true)
+		// GetSession does not wait on processing
+		epoch.setProcessing(true)
 		_, err = GetSession(time.Second)
-		assert.Error(t, err, "GetSession should error on timeout")
-		setProcessing(false)
+		assert.NoError(t, err, "GetSession should not error while processing")
+		epoch.setProcessing(false)
 	})
 
 	// Start GetWork server and sync balance
@@ -177,6 +195,26 @@ func TestEPOCH(t *testing.T) {
 		assert.NoError(t, err, "SetMaxHashes should not error: %s", err)
 	})
 
+	// Test a worker error stops AttemptHashes from dispatching further hashes
+	t.Run("EarlyFailure", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			StopGetWork() // submissions will error once the connection is closed
+		}()
+
+		maxHashes := GetMaxHashes()
+		res, err := AttemptHashes(maxHashes)
+		assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+		assert.Error(t, res.Error, "AttemptHashes result should have worker error")
+		assert.Less(t, res.Hashes, uint64(maxHashes), "AttemptHashes should stop dispatching after worker error")
+
+		// Reconnect for remaining tests
+		err = StartGetWork("", endpoint)
+		assert.NoError(t, err, "Starting EPOCH should not error: %s", err)
+		err = JobIsReady(time.Second * 10)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+	})
+
 	hashes := []int{5, 25, 100} // Test these hash amounts
 	startBalance, _ := w.Get_Balance()
 	lastHeight := uint64(0)
@@ -202,6 +240,7 @@ func TestEPOCH(t *testing.T) {
 		lastHeight = w.Get_Daemon_Height()
 
 		var wg sync.WaitGroup
+		var mu sync.Mutex
 		for i := 0; i < 3; i++ {
 			wg.Add(1)
 			go func() {
@@ -209,11 +248,52 @@ func TestEPOCH(t *testing.T) {
 				res, err := AttemptEPOCH(context.Background(), Attempt_Params{Hashes: hashes[1]})
 				assert.NoError(t, err, "AttemptEPOCH should not error: %s", err)
 				if res.Submitted > 0 {
+					mu.Lock()
 					submitted = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	// Test total worker goroutines are bounded by maxThreads across many concurrent callers
+	t.Run("GoroutineBound", func(t *testing.T) {
+		callers := 20
+		baseline := runtime.NumGoroutine()
+		maxAllowed := baseline + callers + GetMaxThreads() + 5 // sampler and connection goroutines
+
+		done := make(chan struct{})
+		peak := make(chan int)
+		go func() {
+			max := 0
+			for {
+				select {
+				case <-done:
+					peak <- max
+					return
+				default:
+					if n := runtime.NumGoroutine(); n > max {
+						max = n
+					}
+					time.Sleep(time.Millisecond)
 				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := AttemptHashes(hashes[1])
+				assert.NoError(t, err, "AttemptHashes should not error: %s", err)
 			}()
 		}
 		wg.Wait()
+		close(done)
+
+		assert.LessOrEqual(t, <-peak, maxAllowed, "Goroutines should be bounded by callers and maxThreads")
 	})
 
 	// Test SubmitEPOCH
@@ -221,7 +301,7 @@ func TestEPOCH(t *testing.T) {
 		for _, h := range hashes {
 			params := []Submit_Params{}
 			for i := 0; i < h; i++ {
-				job, pow, work, diff, err := powHash()
+				job, pow, work, diff, err := epoch.powHash(nil)
 				assert.NoError(t, err, "powHash should not error: %s", err)
 				params = append(params,
 					Submit_Params{
@@ -246,6 +326,35 @@ func TestEPOCH(t *testing.T) {
 		}
 	})
 
+	// Test OnBlockFound
+	t.Run("OnBlockFound", func(t *testing.T) {
+		var mu sync.Mutex
+		var found []BlockFoundEvent
+		OnBlockFound(func(e BlockFoundEvent) {
+			mu.Lock()
+			found = append(found, e)
+			mu.Unlock()
+		})
+		t.Cleanup(func() { OnBlockFound(nil) })
+
+		job, pow, work, diff, err := epoch.powHash(nil)
+		assert.NoError(t, err, "powHash should not error: %s", err)
+
+		res, err := SubmitEPOCH(context.Background(), []Submit_Params{{Job: job, PowHash: pow, EpochWork: work, Difficulty: diff}})
+		assert.NoError(t, err, "SubmitEPOCH should not error: %s", err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, found, res.Submitted, "Should have a BlockFoundEvent for each submitted block")
+		for _, e := range found {
+			assert.Equal(t, job.JobID, e.Block.Job.JobID, "Event JobID should be equal")
+			assert.Equal(t, work, e.Block.EpochWork, "Event work should be equal")
+			assert.Equal(t, pow, e.Block.PowHash, "Event powhash should be equal")
+			assert.Equal(t, astrobwtv3.AstroBWTv3(e.Block.EpochWork[:]), e.Block.PowHash, "Event work should hash to event powhash")
+			assert.Zero(t, diff.Cmp(&e.Block.Difficulty), "Event difficulty should be equal")
+		}
+	})
+
 	// Test other methods
 	t.Run("Methods", func(t *testing.T) {
 		handler := GetHandler()
@@ -253,10 +362,10 @@ func TestEPOCH(t *testing.T) {
 			assert.Contains(t, n, "EPOCH", "Methods should have EPOCH suffix")
 		}
 
-		setProcessing(true)
+		epoch.setProcessing(true)
 		go func() { // delay GetSessions response from processing
 			time.Sleep(time.Millisecond * 100)
-			setProcessing(false)
+			epoch.setProcessing(false)
 		}()
 		sess, err := GetSessionEPOCH(context.Background())
 		assert.NoError(t, err, "GetSessionEPOCH should not error: %s", err)
@@ -289,7 +398,7 @@ func TestEPOCH(t *testing.T) {
 	t.Run("Errors", func(t *testing.T) {
 		// Already running try to start again
 		err := StartGetWork("", "")
-		assert.Error(t, err, "StartGetWork should error when running already")
+		assert.ErrorIs(t, err, ErrAlreadyRunning, "StartGetWork should error when running already")
 
 		// Start server with invalid endpoint string
 		StopGetWork()
@@ -312,90 +421,4725 @@ func TestEPOCH(t *testing.T) {
 	})
 }
 
-// Test EPOCH at its full capacity against a mainnet node, test must have RUN_MAINNET_TEST=true argument or this will be skipped
-func TestMainnet(t *testing.T) {
-	if os.Getenv("RUN_MAINNET_TEST") != "true" {
-		t.Skipf("Use %q to run mainnet test", "RUN_MAINNET_TEST=true go test -run TestMainnet -v")
+// Test the session cumulative difficulty from submitted miniblocks
+func TestCumulativeDifficulty(t *testing.T) {
+	epoch.difficulty.SetInt64(0)
+	t.Cleanup(func() { epoch.difficulty.SetInt64(0) })
+
+	expected := big.NewInt(0)
+	for _, d := range []int64{1, 1000, 25000, 999999} {
+		diff := big.NewInt(d)
+		epoch.addDifficulty(diff)
+		expected.Add(expected, diff)
 	}
 
-	endpoint := "89.38.99.117:10102" // DERO mainnet remote default
-	globals.Arguments["--testnet"] = false
-	globals.Arguments["--simulator"] = false
-	globals.Arguments["--daemon-address"] = endpoint
-	globals.InitNetwork()
-	address := "dero1qy0khp9s9yw2h0eu20xmy9lth3zp5cacmx3rwt6k45l568d2mmcf6qgcsevzx" // Artificer
-	t.Cleanup(StopGetWork)
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, expected.String(), session.CumulativeDifficulty, "Cumulative difficulty should be the sum of submitted difficulties")
+}
 
-	// Use less then available
-	SetMaxThreads(runtime.NumCPU() - 1)
+// Test the nonce region randomized by powHash
+func TestNonceRegion(t *testing.T) {
+	blob := "41dc0600000002062bb9d17900000000a12fda3f33403ee25f490fe665a93a3e0000000056790bb6dd9f5bdad5a18d87"
+	t.Cleanup(func() {
+		epoch.newJob(rpc.GetBlockTemplate_Result{})
+		SetNonceRegion(block.MINIBLOCK_SIZE-DEFAULT_NONCE_BYTES, DEFAULT_NONCE_BYTES)
+	})
 
-	err := StartGetWork(address, endpoint)
-	assert.NoError(t, err, "Starting EPOCH should not error: %s", err)
+	// Defaults
+	offset, length := GetNonceRegion()
+	assert.Equal(t, block.MINIBLOCK_SIZE-DEFAULT_NONCE_BYTES, offset, "Default nonce offset should be equal")
+	assert.Equal(t, DEFAULT_NONCE_BYTES, length, "Default nonce length should be equal")
 
-	err = JobIsReady(time.Second * 10) // wait for connection and jobs
-	assert.NoError(t, err, "Finding job should not error: %s", err)
+	// Invalid regions
+	invalid := [][2]int{{0, 12}, {-1, 4}, {40, 0}, {40, 9}, {1, block.MINIBLOCK_SIZE}, {NONCE_FLAG_BYTE, 1}}
+	for _, r := range invalid {
+		err := SetNonceRegion(r[0], r[1])
+		assert.Error(t, err, "SetNonceRegion %v should error", r)
+	}
 
-	if !IsActive() {
-		t.Fatalf("Not connected to GetWork")
+	// Invalid regions should not change the current setting
+	offset, length = GetNonceRegion()
+	assert.Equal(t, block.MINIBLOCK_SIZE-DEFAULT_NONCE_BYTES, offset, "Nonce offset should not change on invalid region")
+	assert.Equal(t, DEFAULT_NONCE_BYTES, length, "Nonce length should not change on invalid region")
+
+	// Valid regions keep all bytes outside of the region and the final byte
+	valid := [][2]int{{block.MINIBLOCK_SIZE - DEFAULT_NONCE_BYTES, DEFAULT_NONCE_BYTES}, {32, 16}, {1, 4}, {46, 2}, {46, 1}}
+	original, _ := hex.DecodeString(blob)
+	epoch.newJob(rpc.GetBlockTemplate_Result{JobID: "1", Blockhashing_blob: blob, Difficulty: "1"})
+	for _, r := range valid {
+		err := SetNonceRegion(r[0], r[1])
+		assert.NoError(t, err, "SetNonceRegion %v should not error: %s", r, err)
+
+		_, _, work, _, err := epoch.powHash(nil)
+		assert.NoError(t, err, "powHash should not error: %s", err)
+		for i := range work {
+			if i == NONCE_FLAG_BYTE {
+				assert.Equal(t, NONCE_FLAG, work[i], "Final work byte should be set")
+			} else if i < r[0] || i >= r[0]+r[1] {
+				assert.Equal(t, original[i], work[i], "Work byte %d outside of nonce region %v should not change", i, r)
+			}
+		}
 	}
+}
 
-	var wg sync.WaitGroup
-	run := 30
-	hashes := 1000
-	start := time.Now()
+// Test the default nonce region randomizes every byte before the flag byte and the flag byte is fixed
+func TestNonceFlag(t *testing.T) {
+	blob := "41dc0600000002062bb9d17900000000a12fda3f33403ee25f490fe665a93a3e0000000056790bb6dd9f5bdad5a18d87"
+	t.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
 
-	for i := 0; i < run; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := AttemptHashes(hashes)
-			if err != nil {
-				t.Errorf("AttemptHashes: %s", err)
+	original, _ := hex.DecodeString(blob)
+	epoch.newJob(rpc.GetBlockTemplate_Result{JobID: "1", Blockhashing_blob: blob, Difficulty: "1"})
+
+	offset, length := GetNonceRegion()
+	assert.Equal(t, NONCE_FLAG_BYTE, offset+length-1, "Default nonce region should end at the flag byte")
+
+	// Each random byte is unchanged across all hashes with probability 1/256^(hashes-1)
+	hashes := 16
+	changed := map[int]bool{}
+	var first [block.MINIBLOCK_SIZE]byte
+	for h := 0; h < hashes; h++ {
+		_, _, work, _, err := epoch.powHash(nil)
+		if err != nil {
+			t.Fatalf("powHash should not error: %s", err)
+		}
+
+		if h == 0 {
+			first = work
+		}
+
+		assert.Equal(t, NONCE_FLAG, work[NONCE_FLAG_BYTE], "Flag byte should be NONCE_FLAG")
+		assert.Equal(t, original[:offset], work[:offset], "Work before the nonce region should not change")
+		for i := offset; i < NONCE_FLAG_BYTE; i++ {
+			if work[i] != first[i] {
+				changed[i] = true
 			}
-		}()
+		}
 	}
 
-	wg.Wait()
+	for i := offset; i < NONCE_FLAG_BYTE; i++ {
+		assert.True(t, changed[i], "Nonce byte %d should be randomized", i)
+	}
+	assert.Len(t, changed, DEFAULT_NONCE_BYTES-1, "Only the bytes before the flag byte should be randomized")
+}
 
-	took := time.Since(start)
-	total := hashes * run
+// Test work calls after StopGetWork return ErrNotActive rather than blocking on the semaphore
+func TestStoppedSemaphore(t *testing.T) {
+	StopGetWork()
+	assert.Nil(t, epoch.getSemaphore(), "Semaphore should be nil after StopGetWork")
 
-	t.Logf("Took %dms for %d hashes, [%.0fH/s]", took.Milliseconds(), total, float64(total)/took.Seconds())
+	done := make(chan error)
+	go func() {
+		_, err := AttemptHashes(1)
+		done <- err
+		_, err = SubmitHashes([]Submit_Params{{}})
+		done <- err
+	}()
+
+	for _, call := range []string{"AttemptHashes", "SubmitHashes"} {
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrNotActive, "%s should return ErrNotActive after StopGetWork", call)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s should not block after StopGetWork", call)
+		}
+	}
 }
 
-// Benchmark for AttemptHashes with default settings against a simulator node
-func BenchmarkAttemptHashes(b *testing.B) {
-	endpoint := "127.0.0.1:20000"
-	globals.Arguments["--testnet"] = true
-	globals.Arguments["--simulator"] = true
-	globals.Arguments["--daemon-address"] = endpoint
-	globals.InitNetwork()
-	address := "deto1qyre7td6x9r88y4cavdgpv6k7lvx6j39lfsx420hpvh3ydpcrtxrxqg8v8e3z"
-	b.Cleanup(StopGetWork)
+// Test which connection errors are reconnected on as per ReconnectPolicy
+func TestReconnectPolicy(t *testing.T) {
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
 
-	if err := StartGetWork(address, endpoint); err != nil {
-		b.Fatalf("StartGetWork error: %s", err)
+	assert.Equal(t, RECONNECT_NEVER, GetReconnectPolicy(), "Default policy should be RECONNECT_NEVER")
+	assert.Error(t, SetReconnectPolicy(ReconnectPolicy(-1)), "Invalid policy should error")
+	assert.Error(t, SetReconnectPolicy(RECONNECT_ALWAYS+1), "Invalid policy should error")
+	assert.NoError(t, SetReconnectPolicy(RECONNECT_TRANSIENT), "Valid policy should not error")
+	assert.Equal(t, RECONNECT_TRANSIENT, GetReconnectPolicy(), "Policy should be equal")
+
+	networkDrop := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	abnormal := &websocket.CloseError{Code: websocket.CloseAbnormalClosure}
+	normal := &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	goingAway := &websocket.CloseError{Code: websocket.CloseGoingAway}
+
+	tests := []struct {
+		policy ReconnectPolicy
+		err    error
+		expect bool
+	}{
+		{RECONNECT_NEVER, networkDrop, false},
+		{RECONNECT_NEVER, normal, false},
+		{RECONNECT_TRANSIENT, networkDrop, true},
+		{RECONNECT_TRANSIENT, io.ErrUnexpectedEOF, true},
+		{RECONNECT_TRANSIENT, abnormal, true},
+		{RECONNECT_TRANSIENT, normal, false},
+		{RECONNECT_TRANSIENT, goingAway, false},
+		{RECONNECT_ALWAYS, networkDrop, true},
+		{RECONNECT_ALWAYS, goingAway, true},
 	}
 
-	err := JobIsReady(time.Second * 10) // wait for connection and jobs
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, shouldReconnect(tt.policy, tt.err), "Policy %d reconnect on %q should be %t", tt.policy, tt.err, tt.expect)
+	}
+}
+
+// Test reconnect delays are randomized within the reconnect jitter
+func TestReconnectJitter(t *testing.T) {
+	t.Cleanup(func() { SetReconnectJitter(0) })
+
+	assert.Zero(t, GetReconnectJitter(), "Default jitter should be 0")
+	assert.Error(t, SetReconnectJitter(-0.1), "Negative jitter should error")
+	assert.Error(t, SetReconnectJitter(1.1), "Jitter above 1 should error")
+	assert.NoError(t, SetReconnectJitter(0.25), "Valid jitter should not error")
+	assert.Equal(t, 0.25, GetReconnectJitter(), "Jitter should be equal")
+
+	assert.Equal(t, RECONNECT_DELAY, jitterDelay(RECONNECT_DELAY, 0), "No jitter should not change the delay")
+
+	// Each attempt's doubled delay varies within ±25%
+	delay := RECONNECT_DELAY
+	for attempt := 0; attempt < 5; attempt++ {
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			d := jitterDelay(delay, GetReconnectJitter())
+			assert.GreaterOrEqual(t, d, delay*3/4, "Jittered delay should not be below the jitter range")
+			assert.LessOrEqual(t, d, delay*5/4, "Jittered delay should not be above the jitter range")
+			seen[d] = true
+		}
+		assert.Greater(t, len(seen), 1, "Jittered delays should vary")
+
+		delay *= 2
+	}
+}
+
+// Test a connection read error is reconnected on when allowed by the ReconnectPolicy, otherwise the connection is stopped
+func TestReconnect(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+
+	err := StartGetWork(testAddress, s.Endpoint())
 	if err != nil {
-		b.Fatalf("Finding job should not error: %s", err)
+		t.Fatalf("StartGetWork should not error: %s", err)
 	}
 
-	if !IsActive() {
-		b.Fatalf("Not connected to GetWork")
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Abnormal closure is reconnected on
+	s.CloseConnections()
+	assert.Eventually(t, func() bool { return len(s.Addresses()) == 2 && IsActive() }, time.Second*5, time.Millisecond*10, "EPOCH should reconnect after read error")
+	assert.True(t, epoch.isRunning(), "EPOCH should still be running after reconnecting")
+
+	res, err := AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error after reconnecting: %s", err)
+	assert.NoError(t, res.Error, "AttemptHashes result should not error after reconnecting")
+
+	// Connection is stopped when reconnect is not allowed
+	SetReconnectPolicy(RECONNECT_NEVER)
+	s.CloseConnections()
+	assert.Eventually(t, func() bool { return !epoch.isRunning() }, time.Second*5, time.Millisecond*10, "EPOCH should stop after read error")
+	assert.False(t, IsActive(), "EPOCH should not be active after read error")
+	assert.Len(t, s.Addresses(), 2, "EPOCH should not reconnect")
+}
+
+// Test StartGetWork retries its initial connect to a node that is not listening yet
+func TestConnectRetries(t *testing.T) {
+	t.Cleanup(func() { SetConnectRetries(0, 0) })
+
+	assert.Error(t, SetConnectRetries(-1, time.Second), "Negative retries should error")
+	assert.Error(t, SetConnectRetries(1, 0), "Retries without backoff should error")
+
+	s := NewDelayedTestServer(t, testJob, time.Millisecond*500)
+	endpoint := "127.0.0.1:" + GetPort()
+
+	// Node is not listening
+	err := StartGetWork(testAddress, endpoint)
+	assert.Error(t, err, "StartGetWork should error without retries")
+
+	retries, backoff := 5, time.Millisecond*100
+	err = SetConnectRetries(retries, backoff)
+	assert.NoError(t, err, "SetConnectRetries should not error: %s", err)
+	n, d := GetConnectRetries()
+	assert.Equal(t, retries, n, "Connect retries should be equal")
+	assert.Equal(t, backoff, d, "Connect backoff should be equal")
+
+	err = StartGetWork(testAddress, endpoint)
+	assert.NoError(t, err, "StartGetWork should connect once the node is listening: %s", err)
+	assert.True(t, IsActive(), "EPOCH should be active after retrying")
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	assert.Len(t, s.Addresses(), 1, "Test server should have one connection")
+	StopGetWork()
+	s.Close()
+
+	// StopGetWork ends the retries without waiting for the backoff
+	err = SetConnectRetries(retries, time.Second*10)
+	assert.NoError(t, err, "SetConnectRetries should not error: %s", err)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- StartGetWork(testAddress, endpoint)
+	}()
+
+	time.Sleep(time.Millisecond * 200)
+	StopGetWork()
+
+	select {
+	case err = <-errs:
+		assert.ErrorIs(t, err, context.Canceled, "StartGetWork should error when stopped while retrying")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("StopGetWork should end the connect retries")
 	}
+	assert.False(t, IsActive(), "EPOCH should not be active after its connect retries are stopped")
+}
 
-	hashes := 100
+// Test Autotune chooses a valid thread count
+func TestAutotune(t *testing.T) {
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() { SetMaxThreads(maxThreads) })
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := AttemptHashes(hashes)
+	// Cancelled autotune should not change maxThreads
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Autotune(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "Autotune should error when cancelled")
+	assert.Equal(t, maxThreads, GetMaxThreads(), "Cancelled Autotune should not change maxThreads")
+
+	threads, err := Autotune(context.Background())
+	assert.NoError(t, err, "Autotune should not error: %s", err)
+	assert.GreaterOrEqual(t, threads, 1, "Autotune should choose at least one thread")
+	assert.LessOrEqual(t, threads, runtime.NumCPU(), "Autotune should not exceed NumCPU")
+	assert.Equal(t, threads, GetMaxThreads(), "Autotune should set maxThreads")
+}
+
+// Test nonce dedupe re-rolls duplicate nonces within a batch
+func TestNonceDedupe(t *testing.T) {
+	t.Cleanup(func() {
+		epoch.newJob(rpc.GetBlockTemplate_Result{})
+		SetNonceDedupe(false)
+		SetNonceRegion(block.MINIBLOCK_SIZE-DEFAULT_NONCE_BYTES, DEFAULT_NONCE_BYTES)
+	})
+
+	assert.False(t, GetNonceDedupe(), "Nonce dedupe should be off by default")
+
+	// Exhausted nonce space should error, a single random byte has 256 nonces
+	err := SetNonceRegion(block.MINIBLOCK_SIZE-2, 1)
+	assert.NoError(t, err, "SetNonceRegion should not error: %s", err)
+	epoch.newJob(testJob)
+	used := &nonces{}
+	_, _, _, _, err = epoch.powHash(used)
+	assert.NoError(t, err, "powHash should not error on first nonce: %s", err)
+	for i := 0; i < 256 && err == nil; i++ {
+		_, _, _, _, err = epoch.powHash(used)
+	}
+	assert.Error(t, err, "powHash should error when nonce space is exhausted")
+
+	// A single random byte nonce space
+	s := NewTestServer(t, testJob)
+	err = SetNonceRegion(block.MINIBLOCK_SIZE-2, 1)
+	assert.NoError(t, err, "SetNonceRegion should not error: %s", err)
+	SetNonceDedupe(true)
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	hashes := 200
+	res, err := AttemptHashes(hashes)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.NoError(t, res.Error, "AttemptHashes result should not error")
+
+	s.WaitSubmissions(res.Submitted, time.Second*5)
+	blobs := map[string]bool{}
+	for _, p := range s.Submissions() {
+		assert.False(t, blobs[p.MiniBlockhashing_blob], "Submitted work should not be duplicated")
+		blobs[p.MiniBlockhashing_blob] = true
+	}
+}
+
+// Test AttemptAndStatsEPOCH returns the session including the attempt
+func TestAttemptAndStatsEPOCH(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	var hashes uint64
+	var minis int
+	for _, h := range []int{5, 10} {
+		res, err := AttemptAndStatsEPOCH(context.Background(), Attempt_Params{Hashes: h})
+		assert.NoError(t, err, "AttemptAndStatsEPOCH should not error: %s", err)
+		assert.Equal(t, uint64(h), res.Result.Hashes, "Result hashes should be equal")
+
+		hashes += res.Result.Hashes
+		minis += res.Result.Submitted
+		assert.Equal(t, hashes, res.Session.Hashes, "Session hashes should include attempt")
+		assert.Equal(t, minis, res.Session.MiniBlocks, "Session miniblocks should include attempt")
+		assert.Equal(t, epoch.session.Version, res.Session.Version, "Session version should be equal")
+	}
+}
+
+// Test all connection goroutines exit after StopGetWork
+func TestConnectionContext(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+
+	baseline := runtime.NumGoroutine()
+
+	for _, drop := range []bool{false, true} {
+		err := StartGetWork(testAddress, s.Endpoint())
 		if err != nil {
-			b.Fatalf("AttemptHashes failed: %s", err)
+			t.Fatalf("StartGetWork should not error: %s", err)
+		}
+
+		err = JobIsReady(time.Second * 5)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+
+		if drop {
+			// Stop while the read loop is waiting to reconnect
+			s.CloseConnections()
+			assert.Eventually(t, func() bool { return !IsActive() }, time.Second*5, time.Millisecond*10, "Should not be active while reconnecting")
+		}
+
+		StopGetWork()
+
+		// Poll in test goroutine as assert.Eventually will add its own goroutines
+		deadline := time.Now().Add(time.Second * 5)
+		for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
 		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "Goroutines should return to baseline after StopGetWork (drop=%t)", drop)
 	}
-	b.StopTimer()
+}
+
+// Test SetMaxHashes warns or errors when a batch would be long
+func TestLongBatch(t *testing.T) {
+	maxHashes := GetMaxHashes()
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() {
+		SetStrictMaxHashes(false)
+		SetMaxThreads(maxThreads)
+		SetMaxHashes(maxHashes)
+	})
+
+	SetMaxThreads(1)
+
+	// Normal ratio should not warn
+	output := captureOutput(func() {
+		err := SetMaxHashes(LONG_BATCH_HASHES)
+		assert.NoError(t, err, "SetMaxHashes should not error: %s", err)
+	})
+	assert.NotContains(t, output, "long batch", "SetMaxHashes should not warn below long batch ratio")
+
+	// Extreme ratio should warn but still be set
+	output = captureOutput(func() {
+		err := SetMaxHashes(LIMIT_MAX_HASHES)
+		assert.NoError(t, err, "SetMaxHashes should not error when not strict: %s", err)
+	})
+	assert.Contains(t, output, "long batch", "SetMaxHashes should warn above long batch ratio")
+	assert.Equal(t, LIMIT_MAX_HASHES, GetMaxHashes(), "SetMaxHashes should set value when not strict")
+
+	// Strict should error and not set
+	SetStrictMaxHashes(true)
+	SetMaxHashes(maxHashes)
+	err := SetMaxHashes(LIMIT_MAX_HASHES)
+	assert.Error(t, err, "SetMaxHashes should error above long batch ratio when strict")
+	assert.Equal(t, maxHashes, GetMaxHashes(), "SetMaxHashes should not set value when strict")
+}
+
+// Test partial and corrupt job frames do not install stale job fields
+func TestPartialJob(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	assert.Equal(t, testJob, epoch.getJob(), "Job should be equal")
+
+	// Partial job should not inherit fields of the previous job
+	partial := rpc.GetBlockTemplate_Result{JobID: "partial", Height: 9}
+	s.SendRaw([]byte(`{"jobid":"partial","height":9}`))
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == partial.JobID }, time.Second*5, time.Millisecond*10, "Partial job should be installed")
+	assert.Equal(t, partial, epoch.getJob(), "Partial job should not have any previous job fields")
+
+	// Corrupt frame should be skipped without closing the connection
+	s.SendRaw([]byte(`{"jobid":"corrupt","difficulty":`))
+	s.SendJob(testJob)
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == testJob.JobID }, time.Second*5, time.Millisecond*10, "Job after corrupt frame should be installed")
+	assert.Equal(t, testJob, epoch.getJob(), "Job should be equal")
+	assert.True(t, IsActive(), "Corrupt frame should not close connection")
+}
+
+// Test the session reward estimate from accepted blocks
+func TestRewardEstimate(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetRewardPerBlock(0)
+		epoch.newJob(rpc.GetBlockTemplate_Result{})
+	})
+
+	reward := uint64(61500)
+	SetRewardPerBlock(reward)
+	assert.Equal(t, reward, GetRewardPerBlock(), "Reward per block should be equal")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Node reports accepted counts for the connection in each job
+	for _, accepted := range []uint64{1, 3, 3, 4} {
+		job := testJob
+		job.JobID = strconv.FormatUint(accepted, 10)
+		job.MiniBlocks = accepted
+		s.SendJob(job)
+		assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be installed")
+	}
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(4), session.Accepted, "Session accepted should be equal")
+	assert.Equal(t, session.Accepted*reward, session.Reward, "Session reward should be accepted * reward per block")
+
+	// A new count from the node is added to the session total
+	job := testJob
+	job.JobID = "new count"
+	job.Blocks = 2
+	s.SendJob(job)
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be installed")
+
+	session, err = GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(6), session.Accepted, "Session accepted should include new node count")
+	assert.Equal(t, session.Accepted*reward, session.Reward, "Session reward should be accepted * reward per block")
+}
+
+// Test SubmitSuccessRatio from the accepted and rejected counts reported by the node
+func TestSubmitSuccessRatio(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// No blocks reported
+	assert.Zero(t, SubmitSuccessRatio(), "Ratio should be 0 without any reported blocks")
+
+	// Node reports accepted and rejected counts for the connection in each job
+	for i, counts := range [][2]uint64{{1, 0}, {2, 1}, {3, 1}} {
+		job := testJob
+		job.JobID = strconv.Itoa(i)
+		job.MiniBlocks = counts[0]
+		job.Rejected = counts[1]
+		s.SendJob(job)
+		assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be installed")
+	}
+
+	assert.Equal(t, 0.75, SubmitSuccessRatio(), "Ratio should be accepted / (accepted+rejected)")
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(3), session.Accepted, "Session accepted should be equal")
+	assert.Equal(t, uint64(1), session.Rejected, "Session rejected should be equal")
+	assert.Equal(t, 0.75, session.SuccessRatio, "Session ratio should be equal")
+}
+
+// Test SetSubmitRate paces submissions to the node
+func TestSubmitRate(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetSubmitRate(0) })
+
+	assert.Error(t, SetSubmitRate(-1), "Negative submit rate should error")
+	assert.Zero(t, GetSubmitRate(), "Default submit rate should be unlimited")
+
+	rate := 5
+	err := SetSubmitRate(rate)
+	assert.NoError(t, err, "SetSubmitRate should not error: %s", err)
+	assert.Equal(t, rate, GetSubmitRate(), "Submit rate should be equal")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Every hash is a valid miniblock at difficulty 1
+	hashes := rate * 3
+	res, err := AttemptHashes(hashes)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Equal(t, hashes, res.Submitted, "All hashes should be submitted")
+	assert.True(t, s.WaitSubmissions(hashes, time.Second*5), "Test server should receive all submissions")
+
+	// A burst of rate submissions is allowed, after which tokens refill at rate, so any span of
+	// submissions received must be no more than the burst plus rate * the span's duration
+	tolerance := time.Millisecond * 50
+	times := s.SubmissionTimes()
+	for j := range times {
+		for i := j + rate; i < len(times); i++ {
+			min := time.Duration(i-j+1-rate) * time.Second / time.Duration(rate)
+			assert.GreaterOrEqual(t, times[i].Sub(times[j]), min-tolerance, "Submissions %d to %d should be paced to the submit rate", j, i)
+		}
+	}
+}
+
+// Test Close gracefully stops EPOCH and can be called repeatedly
+func TestClose(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	// Not active
+	assert.NoError(t, Close(), "Close should not error when EPOCH is not active")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	assert.Equal(t, 1, s.Connections(), "Test server should have one connection")
+
+	err = Close()
+	assert.NoError(t, err, "Close should not error: %s", err)
+	assert.False(t, IsActive(), "EPOCH should not be active after Close")
+	assert.False(t, epoch.isRunning(), "EPOCH should not be running after Close")
+	assert.Eventually(t, func() bool { return s.Connections() == 0 }, time.Second*5, time.Millisecond*10, "Test server connection should be closed")
+
+	// Idempotent
+	assert.NoError(t, Close(), "Close should not error when called again")
+
+	_, err = AttemptHashes(1)
+	assert.ErrorIs(t, err, ErrNotActive, "AttemptHashes should not be active after Close")
+}
+
+// Test StopGetWork can be called concurrently and while the read loop is stopping on a connection error
+func TestConcurrentStopGetWork(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	for i := 0; i < 5; i++ {
+		err := StartGetWork(testAddress, s.Endpoint())
+		if err != nil {
+			t.Fatalf("StartGetWork should not error: %s", err)
+		}
+
+		err = JobIsReady(time.Second * 5)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+
+		// Read loop will call StopGetWork on the connection error
+		s.CloseConnections()
+
+		var wg sync.WaitGroup
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				StopGetWork()
+			}()
+		}
+		wg.Wait()
+
+		assert.False(t, IsActive(), "EPOCH should not be active after StopGetWork")
+		assert.False(t, epoch.isRunning(), "EPOCH should not be running after StopGetWork")
+	}
+}
+
+// Test HashCurrentJob waits for a job and hashes on it
+func TestHashCurrentJob(t *testing.T) {
+	_, _, _, err := HashCurrentJob(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrNotActive, "HashCurrentJob should error when not active")
+
+	s := NewTestServer(t, rpc.GetBlockTemplate_Result{JobID: "no work"})
+	t.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
+
+	// Clear any job with work from previous tests
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	// No job with work before ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	_, _, _, err = HashCurrentJob(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "HashCurrentJob should error without a job")
+
+	// Job with work arrives while waiting
+	go func() {
+		time.Sleep(time.Millisecond * 200)
+		s.SendJob(testJob)
+	}()
+
+	hashes := 5
+	res, height, difficulty, err := HashCurrentJob(context.Background(), hashes)
+	assert.NoError(t, err, "HashCurrentJob should not error: %s", err)
+	assert.NoError(t, res.Error, "HashCurrentJob result should not error")
+	assert.Equal(t, uint64(hashes), res.Hashes, "HashCurrentJob hashes should be equal")
+	assert.Equal(t, testJob.Height, height, "HashCurrentJob height should be equal")
+	assert.Equal(t, testJob.Difficulty, difficulty, "HashCurrentJob difficulty should be equal")
+
+	// The attempt uses ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	res, _, _, err = HashCurrentJob(ctx, hashes)
+	assert.ErrorIs(t, err, context.Canceled, "HashCurrentJob should error when ctx is cancelled")
+	assert.Less(t, res.Hashes, uint64(hashes), "HashCurrentJob should stop hashing when ctx is cancelled")
+}
+
+// Test the hashrate window smooths the session's CurrentHashrate
+func TestHashrateWindow(t *testing.T) {
+	t.Cleanup(func() { SetHashrateWindow(DEFAULT_HASHRATE_WINDOW) })
+
+	assert.Equal(t, DEFAULT_HASHRATE_WINDOW, GetHashrateWindow(), "Default hashrate window should be equal")
+	assert.Error(t, SetHashrateWindow(0), "Zero hashrate window should error")
+	assert.NoError(t, SetHashrateWindow(time.Second*30), "Valid hashrate window should not error")
+	assert.Equal(t, time.Second*30, GetHashrateWindow(), "Hashrate window should be equal")
+
+	short := rateAverage{window: time.Second * 5}
+	long := rateAverage{window: time.Second * 60}
+
+	// Steady 100 H/s then a one second spike of 1000 H/s
+	now := time.Now()
+	for i := 0; i < 300; i++ {
+		now = now.Add(time.Second)
+		short.add(100, time.Second, now)
+		long.add(100, time.Second, now)
+	}
+
+	assert.InDelta(t, 100, short.rate, 1, "Short window should settle at the steady rate")
+	assert.InDelta(t, 100, long.rate, 1, "Long window should settle at the steady rate")
+
+	now = now.Add(time.Second)
+	short.add(1000, time.Second, now)
+	long.add(1000, time.Second, now)
+	assert.Greater(t, short.rate, long.rate, "Long window should smooth the spike more than the short window")
+	assert.Less(t, long.rate, float64(200), "Long window should mostly ignore a single spike")
+
+	// Idle time decays the average
+	rate := long.rate
+	now = now.Add(time.Second * 61)
+	long.add(0, time.Second, now)
+	assert.Less(t, long.rate, rate/2, "Idle time should decay the average")
+
+	// Concurrent batches over the same wall clock time add to the total rate
+	concurrent := rateAverage{window: time.Second * 5}
+	for i := 0; i < 300; i++ {
+		now = now.Add(time.Millisecond * 500)
+		for j := 0; j < 4; j++ {
+			concurrent.add(50, time.Millisecond*500, now.Add(time.Duration(j)*time.Millisecond))
+		}
+	}
+	assert.InDelta(t, 400, concurrent.current(), 10, "Concurrent batches should sum to the total rate")
+}
+
+// Test only one concurrent StartGetWork can connect
+func TestConcurrentStartGetWork(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	for i := 0; i < 5; i++ {
+		errs := make(chan error, 2)
+		for j := 0; j < 2; j++ {
+			go func() {
+				errs <- StartGetWork(testAddress, s.Endpoint())
+			}()
+		}
+
+		var started, running int
+		for j := 0; j < 2; j++ {
+			err := <-errs
+			if err == nil {
+				started++
+			} else {
+				assert.ErrorIs(t, err, ErrAlreadyRunning, "StartGetWork should error when already running")
+				running++
+			}
+		}
+
+		assert.Equal(t, 1, started, "Only one StartGetWork should succeed")
+		assert.Equal(t, 1, running, "One StartGetWork should return ErrAlreadyRunning")
+		assert.Eventually(t, func() bool { return s.Connections() == 1 }, time.Second*5, time.Millisecond*10, "Test server should have one connection")
+
+		StopGetWork()
+		assert.Eventually(t, func() bool { return s.Connections() == 0 }, time.Second*5, time.Millisecond*10, "Test server connection should be closed")
+	}
+
+	assert.Len(t, s.Addresses(), 5, "Test server should have accepted one connection for each start")
+}
+
+// Test StartGetWorkOnPort connects to endpoints on different ports without changing the EPOCH port
+func TestStartGetWorkOnPort(t *testing.T) {
+	s1 := NewTestServer(t, testJob)
+	s2 := NewTestServer(t, testJob)
+	port := GetPort()
+
+	assert.Error(t, StartGetWorkOnPort(testAddress, s1.Endpoint(), 0), "Invalid port should error")
+
+	for _, s := range []*testServer{s1, s2} {
+		err := StartGetWorkOnPort(testAddress, s.Endpoint(), s.Port())
+		if err != nil {
+			t.Fatalf("StartGetWorkOnPort should not error: %s", err)
+		}
+
+		err = JobIsReady(time.Second * 5)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+		assert.Equal(t, 1, s.Connections(), "Test server on port %d should have one connection", s.Port())
+		assert.Equal(t, port, GetPort(), "EPOCH port should not change")
+
+		StopGetWork()
+	}
+
+	assert.Len(t, s1.Addresses(), 1, "First test server should have accepted one connection")
+	assert.Len(t, s2.Addresses(), 1, "Second test server should have accepted one connection")
+}
+
+// Test SetProfiling reports the time spent in AstroBWTv3 and its overhead
+func TestProfiling(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetProfiling(false) })
+
+	assert.False(t, GetProfiling(), "Profiling should be disabled by default")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	_, session, err := epoch.attemptHashes(context.Background(), 5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Zero(t, session.HashFuncNs, "Hash function time should not be measured when profiling is disabled")
+	assert.Zero(t, session.OverheadNs, "Overhead should not be measured when profiling is disabled")
+
+	SetProfiling(true)
+	assert.True(t, GetProfiling(), "Profiling should be enabled")
+
+	_, session, err = epoch.attemptHashes(context.Background(), 5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Positive(t, session.HashFuncNs, "Hash function time should be measured when profiling is enabled")
+	assert.Positive(t, session.OverheadNs, "Overhead should be measured when profiling is enabled")
+	t.Logf("AstroBWTv3: %dns  Overhead: %dns", session.HashFuncNs, session.OverheadNs)
+}
+
+// Test SetMemoryProfiling reports batch allocations and the heap stays bounded across many batches
+func TestMemoryProfiling(t *testing.T) {
+	// Nothing is submitted at this difficulty so the test server does not grow with the batches
+	job := testJob
+	job.Difficulty = "18446744073709551615"
+	job.Difficultyuint64 = math.MaxUint64
+
+	s := NewTestServer(t, job)
+	t.Cleanup(func() {
+		SetMemoryProfiling(false)
+		// Do not leave the unsolvable job for the next test
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+	})
+
+	assert.False(t, GetMemoryProfiling(), "Memory profiling should be disabled by default")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	assert.Eventually(t, func() bool { return epoch.getJob().Difficulty == job.Difficulty }, time.Second*5, time.Millisecond*10, "Job should be received")
+
+	_, session, err := epoch.attemptHashes(context.Background(), 5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Zero(t, session.BatchAlloc, "Batch allocations should not be measured when memory profiling is disabled")
+	assert.Zero(t, session.HeapInUse, "Heap should not be measured when memory profiling is disabled")
+
+	SetMemoryProfiling(true)
+	assert.True(t, GetMemoryProfiling(), "Memory profiling should be enabled")
+
+	heap := func() uint64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse
+	}
+
+	_, _, err = epoch.attemptHashes(context.Background(), 10)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	before := heap()
+
+	for i := 0; i < 50; i++ {
+		_, session, err = epoch.attemptHashes(context.Background(), 10)
+		if err != nil {
+			t.Fatalf("AttemptHashes should not error: %s", err)
+		}
+	}
+
+	after := heap()
+	assert.Positive(t, session.BatchAlloc, "Batch allocations should be measured when memory profiling is enabled")
+	assert.Positive(t, session.HeapInUse, "Heap should be measured when memory profiling is enabled")
+	assert.Less(t, int64(after)-int64(before), int64(4<<20), "Heap should not grow with the number of batches")
+	t.Logf("Batch: %d B  Heap: %d B  Growth: %d B", session.BatchAlloc, session.HeapInUse, int64(after)-int64(before))
+}
+
+// Test hashes streamed through SubmitChannel are submitted
+func TestSubmitChannel(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	assert.Nil(t, SubmitChannel(), "SubmitChannel should be nil when not active")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	ch := SubmitChannel()
+	assert.NotNil(t, ch, "SubmitChannel should not be nil when active")
+
+	// Every hash is a valid miniblock at difficulty 1
+	submissions := 3
+	for i := 0; i < submissions; i++ {
+		job, powhash, work, diff, err := epoch.powHash(nil)
+		if err != nil {
+			t.Fatalf("powHash should not error: %s", err)
+		}
+
+		ch <- Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+	}
+
+	assert.True(t, s.WaitSubmissions(submissions, time.Second*5), "Test server should receive all submissions")
+	assert.Eventually(t, func() bool {
+		session, _ := GetSession(time.Second)
+		return session.MiniBlocks == submissions
+	}, time.Second*5, time.Millisecond*10, "Session miniblocks should include streamed submissions")
+
+	StopGetWork()
+	assert.Nil(t, SubmitChannel(), "SubmitChannel should be nil after StopGetWork")
+}
+
+// Test subscriptions receive the connection states and are closed after StopGetWork
+func TestSubscribe(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+
+	// Not running
+	var states []ConnectionState
+	for state := range Subscribe() {
+		states = append(states, state)
+	}
+	assert.Equal(t, []ConnectionState{STATE_DISCONNECTED}, states, "Subscription should be closed when not running")
+
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	sub := Subscribe()
+	done := make(chan []ConnectionState)
+	go func() {
+		var states []ConnectionState
+		for state := range sub {
+			states = append(states, state)
+		}
+		done <- states
+	}()
+
+	s.CloseConnections()
+	assert.Eventually(t, func() bool { return len(s.Addresses()) == 2 && IsActive() }, time.Second*5, time.Millisecond*10, "EPOCH should reconnect after read error")
+
+	StopGetWork()
+
+	select {
+	case states = <-done:
+		assert.Equal(t, []ConnectionState{STATE_RECONNECTING, STATE_CONNECTED, STATE_DISCONNECTED}, states, "Subscription states should be equal")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Subscription should be closed after StopGetWork")
+	}
+}
+
+// Test the heights missed while reconnecting are added to the session
+func TestMissedHeights(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Network moves from height 518 to 522 while disconnected
+	job := testJob
+	job.JobID = "after gap"
+	job.Height = testJob.Height + 4
+	s.Lock()
+	s.job = job
+	s.Unlock()
+	s.CloseConnections()
+
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be installed after reconnecting")
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(3), session.MissedHeights, "Heights between the last and new job should be missed")
+
+	// Next height after a reconnect has no missed heights
+	job.JobID = "no gap"
+	job.Height++
+	s.Lock()
+	s.job = job
+	s.Unlock()
+	s.CloseConnections()
+
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be installed after reconnecting")
+
+	session, err = GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(3), session.MissedHeights, "Next height should not add missed heights")
+}
+
+// Test SubmitHashes counts every attempted submission in Hashes, including one that errors
+func TestSubmitHashesCount(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() { SetMaxThreads(maxThreads) })
+
+	// One thread so params are attempted in order
+	SetMaxThreads(1)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	job, powhash, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	valid := Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+	invalid := valid
+	for i := range invalid.PowHash {
+		invalid.PowHash[i] = 0xff // above any difficulty
+	}
+	invalid.Difficulty = big.Int{} // not shared with valid
+	invalid.Difficulty.SetUint64(1 << 62)
+	erroring := valid
+	erroring.Difficulty = big.Int{}
+
+	res, err := SubmitHashes([]Submit_Params{valid, invalid, erroring, valid})
+	assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+	assert.Error(t, res.Error, "SubmitHashes result should have the submission error")
+	assert.Equal(t, uint64(3), res.Hashes, "Hashes should count attempted submissions including the error")
+	assert.Equal(t, 1, res.Submitted, "Only the valid param before the error should be submitted")
+	assert.True(t, s.WaitSubmissions(1, time.Second*5), "Test server should receive the valid submission")
+}
+
+// Test hashing halts once the session hash limit is reached
+func TestSessionHashLimit(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetSessionHashLimit(0)
+		OnSessionLimit(nil)
+	})
+
+	var events []SessionLimitEvent
+	OnSessionLimit(func(e SessionLimitEvent) { events = append(events, e) })
+
+	limit := uint64(10)
+	SetSessionHashLimit(limit)
+	assert.Equal(t, limit, GetSessionHashLimit(), "Session hash limit should be equal")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	for i := 0; i < 2; i++ {
+		_, err = AttemptHashes(5)
+		assert.NoError(t, err, "AttemptHashes within the limit should not error: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = AttemptHashes(1)
+		assert.ErrorIs(t, err, ErrSessionHashLimit, "AttemptHashes should error once the limit is reached")
+	}
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, limit, session.Hashes, "Session hashes should not exceed the limit")
+
+	if assert.Len(t, events, 1, "OnSessionLimit should be called once") {
+		assert.Equal(t, limit, events[0].Limit, "Event limit should be equal")
+		assert.Equal(t, limit, events[0].Session.Hashes, "Event session hashes should be equal")
+	}
+
+	// A new session starts counting again
+	StopGetWork()
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	_, err = AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes in a new session should not error: %s", err)
+}
+
+// Test JobStatus reports the job age and if it is stale
+func TestJobStatus(t *testing.T) {
+	t.Cleanup(func() {
+		SetMaxJobAge(DEFAULT_MAX_JOB_AGE)
+		epoch.newJob(rpc.GetBlockTemplate_Result{})
+	})
+
+	// No job with work
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+	assert.True(t, JobStatus().Stale, "JobStatus should be stale without a job")
+
+	epoch.newJob(testJob)
+	status := JobStatus()
+	assert.Equal(t, testJob.JobID, status.JobID, "JobStatus JobID should be equal")
+	assert.Equal(t, testJob.Height, status.Height, "JobStatus height should be equal")
+	assert.Less(t, status.Age, time.Second, "JobStatus age should be recent")
+	assert.False(t, status.Stale, "New job should not be stale")
+
+	// Age the job past maxJobAge
+	SetMaxJobAge(time.Second * 10)
+	epoch.jobs.Lock()
+	snapshot := *epoch.jobs.load()
+	snapshot.received = time.Now().Add(-time.Second * 11)
+	epoch.jobs.store(snapshot)
+	epoch.jobs.Unlock()
+
+	status = JobStatus()
+	assert.GreaterOrEqual(t, status.Age, time.Second*11, "JobStatus age should be equal")
+	assert.True(t, status.Stale, "Job older than maxJobAge should be stale")
+}
+
+// Test RunLoop streams batch results until it is cancelled or the connection is stopped
+func TestRunLoop(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	assert.ErrorIs(t, RunLoop(context.Background(), 1), ErrNotActive, "RunLoop should error when not active")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = RunLoop(ctx, 2)
+	assert.NoError(t, err, "RunLoop should not error: %s", err)
+	assert.Error(t, RunLoop(ctx, 2), "RunLoop should error when already running")
+
+	results := 0
+	for res := range ResultsChannel() {
+		assert.NoError(t, res.Error, "RunLoop result should not error")
+		assert.Equal(t, uint64(2), res.Hashes, "RunLoop result hashes should be equal")
+		results++
+		if results == 3 {
+			cancel()
+		}
+	}
+	assert.GreaterOrEqual(t, results, 3, "Multiple results should be received before the channel is closed")
+
+	// Stopping the connection closes the channel
+	err = RunLoop(context.Background(), 2)
+	assert.NoError(t, err, "RunLoop should not error after the last loop stopped: %s", err)
+	ch := ResultsChannel()
+	<-ch
+	StopGetWork()
+
+	closed := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("ResultsChannel should be closed after StopGetWork")
+	}
+}
+
+// Test RunLoop waits instead of spinning while there is no job
+func TestRunLoopNoJob(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	// Clear any job of a previous connection so JobIsReady waits for this connection's job
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+
+	epoch.RLock()
+	batches := epoch.batches
+	epoch.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = RunLoop(ctx, 2)
+	assert.NoError(t, err, "RunLoop should not error: %s", err)
+
+	select {
+	case res := <-ResultsChannel():
+		t.Errorf("RunLoop should not send results without a job: %v", res.Error)
+	case <-time.After(time.Millisecond * 500):
+	}
+
+	epoch.RLock()
+	attempts := epoch.batches - batches
+	epoch.RUnlock()
+	assert.LessOrEqual(t, attempts, uint64(10), "RunLoop should wait between attempts without a job")
+
+	// Hashing resumes with the next job
+	s.SendJob(testJob)
+	select {
+	case res := <-ResultsChannel():
+		assert.NoError(t, res.Error, "RunLoop result should not error")
+	case <-time.After(time.Second * 5):
+		t.Errorf("RunLoop should send results once a job is received")
+	}
+
+	cancel()
+	for range ResultsChannel() {
+	}
+}
+
+// Test cancelling RunLoop's ctx ends the running batch
+func TestRunLoopCancel(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	maxHashes := GetMaxHashes()
+	t.Cleanup(func() { SetMaxHashes(maxHashes) })
+	SetMaxHashes(LIMIT_MAX_HASHES)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	before, _ := GetSession(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = RunLoop(ctx, LIMIT_MAX_HASHES)
+	assert.NoError(t, err, "RunLoop should not error: %s", err)
+
+	time.Sleep(time.Millisecond * 200)
+	cancel()
+
+	for range ResultsChannel() {
+	}
+
+	after, _ := GetSession(time.Second)
+	assert.Less(t, after.Hashes-before.Hashes, uint64(LIMIT_MAX_HASHES), "Cancelling RunLoop should end the running batch")
+}
+
+// Test the session keeps the thread count it was started with when maxThreads changes
+func TestSessionThreads(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() { SetMaxThreads(maxThreads) })
+
+	// Set directly as SetMaxThreads is limited to NumCPU
+	threads := runtime.NumCPU() + 1
+	epoch.Lock()
+	epoch.maxThreads = threads
+	epoch.Unlock()
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, threads, session.Threads, "Session threads should be the maxThreads it was started with")
+	assert.Equal(t, threads, cap(epoch.getSemaphore()), "Semaphore should be sized to the session threads")
+
+	// Change while running takes effect on the next StartGetWork
+	out := captureOutput(func() { SetMaxThreads(1) })
+	assert.Contains(t, out, "will take effect on the next StartGetWork", "Changing maxThreads while running should log when it takes effect")
+	assert.Equal(t, 1, GetMaxThreads(), "maxThreads should be set")
+
+	session, err = GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, threads, session.Threads, "Session threads should not change while running")
+	assert.Equal(t, threads, cap(epoch.getSemaphore()), "Semaphore should not be resized while running")
+
+	StopGetWork()
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	session, err = GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, 1, session.Threads, "New session should use the changed maxThreads")
+}
+
+// Test short batches have a sub millisecond duration consistent with their hash rate
+func TestResultDuration(t *testing.T) {
+	assert.Equal(t, 0.25, durationMs(time.Microsecond*250), "Duration should have microsecond precision")
+	assert.Zero(t, hashesPerSecond(1, 0), "Hash rate without a duration should be 0")
+
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	res, err := AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Positive(t, res.Duration, "Tiny batch should have a duration")
+	assert.InEpsilon(t, float64(res.Hashes)/(res.Duration/1000), res.HashPerSec, 0.01, "Hash rate should be consistent with duration")
+
+	job, powhash, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	res, err = SubmitHashes([]Submit_Params{{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}})
+	assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+	assert.Positive(t, res.Duration, "Sub millisecond submission should have a duration")
+}
+
+// Test the raw message hook receives each frame before it is decoded
+func TestRawMessageHook(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetRawMessageHook(nil) })
+
+	frames := make(chan []byte, 10)
+	SetRawMessageHook(func(b []byte) { frames <- b })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	// First job
+	select {
+	case b := <-frames:
+		var job rpc.GetBlockTemplate_Result
+		assert.NoError(t, json.Unmarshal(b, &job), "Raw frame should be the job JSON")
+		assert.Equal(t, testJob.JobID, job.JobID, "Raw frame JobID should be equal")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Raw message hook should receive the first job")
+	}
+
+	// Frames that can not be decoded are passed to the hook
+	raw := []byte(`{"jobid": "truncated`)
+	s.SendRaw(raw)
+	select {
+	case b := <-frames:
+		assert.Equal(t, raw, b, "Raw frame should be equal")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Raw message hook should receive the invalid frame")
+	}
+}
+
+// Test Shutdown flushes valid blocks still in the pipeline before closing
+func TestShutdown(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := Shutdown(context.Background())
+	assert.NoError(t, err, "Shutdown should not error when not active: %s", err)
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Every hash is a valid miniblock at difficulty 1
+	streamed := 3
+	queued := make([]Submit_Params, streamed)
+	for i := range queued {
+		job, powhash, work, diff, err := epoch.powHash(nil)
+		if err != nil {
+			t.Fatalf("powHash should not error: %s", err)
+		}
+
+		queued[i] = Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+	}
+
+	done := make(chan EPOCH_Result)
+	go func() {
+		result, _ := AttemptHashes(GetMaxHashes())
+		done <- result
+	}()
+
+	assert.Eventually(t, IsProcessing, time.Second*5, time.Millisecond, "Attempt should be processing")
+
+	ch := SubmitChannel()
+	for _, p := range queued {
+		ch <- p
+	}
+
+	err = Shutdown(context.Background())
+	assert.NoError(t, err, "Shutdown should not error: %s", err)
+	assert.False(t, IsActive(), "EPOCH should not be active after Shutdown")
+
+	result := <-done
+	assert.NoError(t, result.Error, "Attempt should not error during Shutdown: %s", result.Error)
+	assert.Less(t, result.Hashes, uint64(GetMaxHashes()), "Attempt should stop dispatching during Shutdown")
+	assert.Equal(t, int(result.Hashes), result.Submitted, "Every hash in flight should be submitted")
+
+	total := streamed + result.Submitted
+	assert.True(t, s.WaitSubmissions(total, time.Second*5), "Test server should receive every block in the pipeline")
+	assert.Len(t, s.Submissions(), total, "Test server should not receive more submissions than were flushed")
+
+	// Deadline
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	semaphore := epoch.getSemaphore()
+	semaphore <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err = Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Shutdown should return the deadline when the pipeline is not flushed")
+	assert.False(t, IsActive(), "EPOCH should not be active after Shutdown deadline")
+	<-semaphore
+}
+
+// Test SetWorkPath validates the template and the custom path is used in the dialed url
+func TestWorkPath(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	s.SetPrefix("/gateway/getwork/")
+	t.Cleanup(func() { SetWorkPath(DEFAULT_WORK_PATH) })
+
+	assert.Equal(t, DEFAULT_WORK_PATH, GetWorkPath(), "Work path should be the default")
+
+	invalid := []string{
+		"",
+		"ws/%s",
+		"/ws/",
+		"/ws/%s/%s",
+		"/ws/%d",
+		"/ws/%v%s",
+		"/ws/%s%",
+	}
+
+	for _, template := range invalid {
+		assert.Error(t, SetWorkPath(template), "SetWorkPath %q should error", template)
+		assert.Equal(t, DEFAULT_WORK_PATH, GetWorkPath(), "Invalid work path %q should not be set", template)
+	}
+
+	assert.NoError(t, SetWorkPath("/100%%/%s/miner"), "SetWorkPath with a literal %% should not error")
+	u, err := url.Parse(workURL("wss", s.Endpoint(), GetWorkPath(), "deto1/x"))
+	if err != nil {
+		t.Fatalf("workURL should parse: %s", err)
+	}
+	assert.Equal(t, "/100%/deto1/x/miner", u.Path, "workURL should replace the verb and unescape %%")
+	assert.Equal(t, "/100%25/deto1%2Fx/miner", u.EscapedPath(), "workURL should escape the path and address")
+
+	// The default path is not served
+	err = StartGetWork(testAddress, s.Endpoint())
+	assert.Error(t, err, "StartGetWork should error when the work path is not served")
+
+	err = SetWorkPath("/gateway/getwork/%s")
+	assert.NoError(t, err, "SetWorkPath should not error: %s", err)
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error with a custom work path: %s", err)
+	}
+	defer StopGetWork()
+
+	paths := s.Paths()
+	if assert.NotEmpty(t, paths, "Test server should accept a connection") {
+		assert.Equal(t, "/gateway/getwork/"+testAddress, paths[len(paths)-1], "Dialed url should use the custom work path")
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error with a custom work path: %s", err)
+}
+
+// Test SetTLSEnabled connects over plain ws to a non-TLS server
+func TestTLSEnabled(t *testing.T) {
+	s := NewPlainTestServer(t, testJob)
+	t.Cleanup(func() { SetTLSEnabled(true) })
+
+	assert.True(t, GetTLSEnabled(), "TLS should be enabled by default")
+
+	// A TLS handshake cannot be done with a plain server
+	err := StartGetWork(testAddress, s.Endpoint())
+	assert.Error(t, err, "StartGetWork should error connecting with TLS to a plain server")
+
+	SetTLSEnabled(false)
+	assert.False(t, GetTLSEnabled(), "TLS should be disabled")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error over ws: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error over ws: %s", err)
+
+	result, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error over ws: %s", err)
+	assert.True(t, s.WaitSubmissions(result.Submitted, time.Second*5), "Test server should receive submissions over ws")
+
+	StopGetWork()
+}
+
+// Test SetScheme ws connects to a plain ws node with the set address
+func TestScheme(t *testing.T) {
+	s := NewPlainTestServer(t, testJob)
+	t.Cleanup(func() { SetScheme("wss") })
+
+	assert.Equal(t, "wss", GetScheme(), "Scheme should be wss by default")
+	assert.Error(t, SetScheme("http"), "SetScheme should error with a scheme that is not ws or wss")
+	assert.Equal(t, "wss", GetScheme(), "Invalid scheme should not be set")
+
+	err := SetScheme("ws")
+	assert.NoError(t, err, "SetScheme ws should not error: %s", err)
+	assert.Equal(t, "ws", GetScheme(), "Scheme should be ws")
+	assert.False(t, GetTLSEnabled(), "Scheme ws should disable TLS")
+
+	err = SetAddress(testAddress)
+	assert.NoError(t, err, "SetAddress should not error: %s", err)
+
+	err = StartGetWork("", s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error over ws: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error over ws: %s", err)
+
+	result, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error over ws: %s", err)
+	assert.True(t, s.WaitSubmissions(result.Submitted, time.Second*5), "Test server should receive submissions over ws")
+	assert.Equal(t, testAddress, s.Addresses()[0], "Test server should receive the set address")
+}
+
+// Test SetKeepalive keeps a healthy connection and reconnects a connection that stops responding
+func TestKeepalive(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetKeepalive(0)
+		SetReconnect(false, 0)
+		SetReconnectBackoff(RECONNECT_DELAY, RECONNECT_MAX_DELAY)
+	})
+
+	assert.Zero(t, GetKeepalive(), "Keepalive should be disabled by default")
+	assert.Error(t, SetKeepalive(time.Millisecond), "SetKeepalive should error below MIN_KEEPALIVE")
+	assert.Error(t, SetKeepalive(-time.Second), "SetKeepalive should error with a negative interval")
+
+	interval := time.Millisecond * 200
+	err := SetKeepalive(interval)
+	assert.NoError(t, err, "SetKeepalive should not error: %s", err)
+	assert.Equal(t, interval, GetKeepalive(), "Keepalive should be set")
+
+	SetReconnect(true, 0)
+	SetReconnectBackoff(time.Millisecond*10, time.Millisecond*10)
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// No jobs are sent after the first, the pongs keep the connection alive
+	time.Sleep(interval * 3)
+	assert.Greater(t, s.Pings(), 2, "Test server should receive pings")
+	assert.Len(t, s.Addresses(), 1, "A connection with pongs should not be reconnected")
+	assert.True(t, IsActive(), "EPOCH should be active with pongs")
+
+	s.IgnorePings(true)
+	assert.Eventually(t, func() bool { return len(s.Addresses()) > 1 }, time.Second*5, time.Millisecond*10, "A dead connection should be reconnected")
+
+	s.IgnorePings(false)
+	assert.Eventually(t, IsActive, time.Second*5, time.Millisecond*10, "EPOCH should be active after reconnecting")
+	StopGetWork()
+
+	// The read of a dead connection ends with a keepalive error
+	s.IgnorePings(true)
+	ws, err := epoch.dial(context.Background(), workURL("wss", s.Endpoint(), DEFAULT_WORK_PATH, testAddress))
+	if err != nil {
+		t.Fatalf("Dialing test server should not error: %s", err)
+	}
+	defer ws.Close()
+
+	stop := keepalive(ws, interval)
+	defer stop()
+
+	start := time.Now()
+	err = epoch.readJobs(context.Background(), ws, interval)
+	assert.True(t, isKeepaliveTimeout(err), "Read should end with a keepalive timeout: %s", err)
+	assert.ErrorContains(t, err, "keepalive", "Read error should be a keepalive error")
+	assert.Less(t, time.Since(start), interval*3, "Dead connection should be detected within the keepalive interval")
+}
+
+// Test the long-poll transport receives new jobs and posts submissions
+func TestLongPoll(t *testing.T) {
+	s := NewPollServer(t, testJob)
+	t.Cleanup(func() {
+		SetTransport(TRANSPORT_WEBSOCKET)
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+	})
+
+	assert.Equal(t, TRANSPORT_WEBSOCKET, GetTransport(), "Transport should be websocket by default")
+	assert.Error(t, SetTransport(Transport(-1)), "SetTransport should error with an invalid transport")
+
+	err := SetTransport(TRANSPORT_LONGPOLL)
+	assert.NoError(t, err, "SetTransport should not error: %s", err)
+	assert.Equal(t, TRANSPORT_LONGPOLL, GetTransport(), "Transport should be long-poll")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error with long-poll: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error with long-poll: %s", err)
+
+	result, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error with long-poll: %s", err)
+	assert.Equal(t, 5, result.Submitted, "All hashes should be submitted")
+	assert.Eventually(t, func() bool { return len(s.Submissions()) == result.Submitted }, time.Second*5, time.Millisecond*10, "Poll server should receive submissions")
+	for _, p := range s.Submissions() {
+		assert.Equal(t, testJob.JobID, p.JobID, "Submission should be for the polled job")
+	}
+
+	// Polls that are answered with no new job keep polling
+	polls := s.Polls()
+	time.Sleep(time.Millisecond * 500)
+	assert.Greater(t, s.Polls(), polls, "Polls should continue while there is no new job")
+	assert.True(t, IsActive(), "EPOCH should be active while polls return no new job")
+
+	job := testJob
+	job.JobID = "1722895096808.0.notified"
+	job.Height++
+	s.SendJob(job)
+	assert.Eventually(t, func() bool { return epoch.jobs.load().job.JobID == job.JobID }, time.Second*5, time.Millisecond*10, "New job should be received by long-poll")
+
+	// A slow submission does not hold the connection
+	s.SetSubmitDelay(LONGPOLL_SUBMIT)
+	submitted := len(s.Submissions())
+	go AttemptHashes(1)
+	assert.Eventually(t, func() bool { return len(s.Submissions()) > submitted }, time.Second*5, time.Millisecond*10, "Poll server should receive the submission")
+
+	now := time.Now()
+	assert.True(t, IsActive(), "EPOCH should be active while a submission is posted")
+	StopGetWork()
+	assert.Less(t, time.Since(now), time.Second, "StopGetWork should not wait for a submission being posted")
+	assert.False(t, IsActive(), "EPOCH should not be active after StopGetWork")
+
+	// The long-poll url is http with the work path
+	SetTLSEnabled(false)
+	t.Cleanup(func() { SetTLSEnabled(true) })
+	assert.Equal(t, "http", epoch.transportScheme(), "Long-poll without TLS should use http")
+}
+
+// Test SetMinSubmitDifficulty does not submit valid hashes of a job below the floor
+func TestMinSubmitDifficulty(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetMinSubmitDifficulty(0) })
+
+	assert.Zero(t, GetMinSubmitDifficulty(), "Minimum submit difficulty should be 0 by default")
+
+	SetMinSubmitDifficulty(testJob.Difficultyuint64 + 1)
+	assert.Equal(t, testJob.Difficultyuint64+1, GetMinSubmitDifficulty(), "Minimum submit difficulty should be set")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	result, err := AttemptHashes(10)
+	assert.NoError(t, err, "AttemptHashes should not error below the floor: %s", err)
+	assert.Equal(t, uint64(10), result.Hashes, "Hashes should still be run below the floor")
+	assert.Zero(t, result.Submitted, "Nothing should be submitted below the floor")
+	assert.False(t, s.WaitSubmissions(1, time.Millisecond*200), "Test server should not receive submissions below the floor")
+
+	session, _ := GetSession(time.Second)
+	assert.Zero(t, session.MiniBlocks, "Session should not count miniblocks below the floor")
+
+	// A job at the floor is submitted
+	SetMinSubmitDifficulty(testJob.Difficultyuint64)
+	result, err = AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error at the floor: %s", err)
+	assert.Equal(t, 5, result.Submitted, "All hashes should be submitted at the floor")
+	assert.True(t, s.WaitSubmissions(5, time.Second*5), "Test server should receive submissions at the floor")
+}
+
+// Test SetCompression is applied to the dialer and a compressing server still works
+func TestCompression(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	s.Compress(true)
+	t.Cleanup(func() { SetCompression(false) })
+
+	assert.False(t, GetCompression(), "Compression should be disabled by default")
+	assert.False(t, epoch.newDialer().EnableCompression, "Dialer should not offer compression by default")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	StopGetWork()
+
+	SetCompression(true)
+	assert.True(t, GetCompression(), "Compression should be enabled")
+	assert.True(t, epoch.newDialer().EnableCompression, "Dialer should offer compression when enabled")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error with compression: %s", err)
+	}
+
+	assert.Equal(t, []bool{false, true}, s.Deflate(), "Compression should only be negotiated when enabled")
+
+	// The job is received compressed and the submissions are sent compressed
+	job := testJob
+	job.JobID = "compressed"
+	s.SendJob(job)
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Compressed job should be received")
+
+	result, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error with compression: %s", err)
+	assert.True(t, s.WaitSubmissions(result.Submitted, time.Second*5), "Test server should receive compressed submissions")
+}
+
+// Test SetTLSConfig verifies the node's certificate and the default skips verification
+func TestTLSConfig(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetTLSConfig(nil) })
+
+	assert.Nil(t, GetTLSConfig(), "TLS config should not be set by default")
+	assert.True(t, epoch.newDialer().TLSClientConfig.InsecureSkipVerify, "Default dialer should not verify certificates")
+	assert.Nil(t, websocket.DefaultDialer.TLSClientConfig, "Shared default dialer should not be modified")
+
+	// The test server's certificate is not trusted by the system roots
+	SetTLSConfig(&tls.Config{})
+	err := StartGetWork(testAddress, s.Endpoint())
+	assert.Error(t, err, "StartGetWork should error verifying an untrusted certificate")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	config := &tls.Config{RootCAs: roots}
+	SetTLSConfig(config)
+	config.RootCAs = nil
+	assert.Equal(t, roots, GetTLSConfig().RootCAs, "TLS config should be cloned when set")
+	assert.False(t, epoch.newDialer().TLSClientConfig.InsecureSkipVerify, "Dialer should use the TLS config")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error verifying a trusted certificate: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+}
+
+// Test instances created with New mine to separate servers and addresses with their own jobs, sessions and config
+func TestInstances(t *testing.T) {
+	s1 := NewTestServer(t, testJob)
+	s2 := NewTestServer(t, testJob)
+
+	other := rpc.NewAddressFromKeys(&crypto.GPoint)
+	other.Mainnet = false
+	otherAddress := other.String()
+
+	a := New(WithPort(s1.Port()), WithMaxThreads(1))
+	b := New(WithPort(s2.Port()), WithAddress(otherAddress), WithMaxHashes(500))
+	t.Cleanup(func() {
+		a.StopGetWork()
+		b.StopGetWork()
+	})
+
+	assert.Equal(t, epoch, Default(), "Default should be the package-level instance")
+	assert.Equal(t, 1000, a.GetMaxHashes(), "New instance should have the default max hashes")
+	assert.Equal(t, 500, b.GetMaxHashes(), "Option should set the instance max hashes")
+	assert.Equal(t, 1000, GetMaxHashes(), "Instance option should not change the default instance")
+	assert.Equal(t, 1, a.GetMaxThreads(), "Option should set the instance threads")
+	assert.Equal(t, strconv.Itoa(DEFAULT_WORK_PORT), New(WithPort(-1)).GetPort(), "Invalid option should keep the default")
+
+	// NewWithOptions returns the option error
+	c, err := NewWithOptions(WithMaxHashes(500), WithPort(-1))
+	assert.Error(t, err, "NewWithOptions should error with an invalid option")
+	assert.Nil(t, c, "NewWithOptions should not return an EPOCH with an error")
+	c, err = NewWithOptions(WithPort(s2.Port()), WithMaxHashes(500))
+	assert.NoError(t, err, "NewWithOptions should not error: %s", err)
+	assert.Equal(t, strconv.Itoa(s2.Port()), c.GetPort(), "Option should set the instance port")
+	assert.Equal(t, 500, c.GetMaxHashes(), "Option should set the instance max hashes")
+
+	err = a.StartGetWork(testAddress, s1.Endpoint())
+	if err != nil {
+		t.Fatalf("Instance a StartGetWork should not error: %s", err)
+	}
+
+	err = b.StartGetWork("", s2.Endpoint())
+	if err != nil {
+		t.Fatalf("Instance b StartGetWork should not error: %s", err)
+	}
+
+	assert.True(t, a.IsActive(), "Instance a should be active")
+	assert.True(t, b.IsActive(), "Instance b should be active")
+	assert.False(t, IsActive(), "Default instance should not be active")
+	assert.Equal(t, []string{testAddress}, s1.Addresses(), "Server 1 should receive instance a's address")
+	assert.Equal(t, []string{otherAddress}, s2.Addresses(), "Server 2 should receive instance b's address")
+
+	assert.NoError(t, a.JobIsReady(time.Second*5), "Instance a should have a job")
+	assert.NoError(t, b.JobIsReady(time.Second*5), "Instance b should have a job")
+
+	job := testJob
+	job.JobID = "1722895096809.0.notified"
+	s2.SendJob(job)
+	assert.Eventually(t, func() bool { return b.JobStatus().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Instance b should receive its server's job")
+	assert.Equal(t, testJob.JobID, a.JobStatus().JobID, "Instance a should keep its own job")
+
+	var wg sync.WaitGroup
+	var ra, rb EPOCH_Result
+	var errA, errB error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ra, errA = a.AttemptHashes(5)
+	}()
+	go func() {
+		defer wg.Done()
+		rb, errB = b.AttemptHashes(3)
+	}()
+	wg.Wait()
+	assert.NoError(t, errA, "Instance a AttemptHashes should not error: %s", errA)
+	assert.NoError(t, errB, "Instance b AttemptHashes should not error: %s", errB)
+
+	assert.True(t, s1.WaitSubmissions(ra.Submitted, time.Second*5), "Server 1 should receive instance a's submissions")
+	assert.True(t, s2.WaitSubmissions(rb.Submitted, time.Second*5), "Server 2 should receive instance b's submissions")
+	assert.Len(t, s1.Submissions(), ra.Submitted, "Server 1 should only receive instance a's submissions")
+	for _, p := range s2.Submissions() {
+		assert.Equal(t, job.JobID, p.JobID, "Instance b should submit for its own job")
+	}
+
+	sessionA, _ := a.GetSession(time.Second)
+	sessionB, _ := b.GetSession(time.Second)
+	assert.Equal(t, uint64(5), sessionA.Hashes, "Instance a session should only count its hashes")
+	assert.Equal(t, uint64(3), sessionB.Hashes, "Instance b session should only count its hashes")
+
+	a.StopGetWork()
+	assert.False(t, a.IsActive(), "Instance a should not be active after StopGetWork")
+	assert.True(t, b.IsActive(), "Stopping instance a should not stop instance b")
+}
+
+// Test JobUpdates receives each new job, drops the oldest when full and is closed and recreated with the connection
+func TestJobUpdates(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+	})
+
+	_, ok := <-JobUpdates()
+	assert.False(t, ok, "JobUpdates should be closed when not running")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	updates := JobUpdates()
+	receive := func() (job rpc.GetBlockTemplate_Result) {
+		select {
+		case job = <-updates:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("JobUpdates should receive a job")
+		}
+
+		return
+	}
+
+	assert.Equal(t, testJob.JobID, receive().JobID, "JobUpdates should receive the first job")
+
+	job := testJob
+	job.JobID = "1722895096810.0.notified"
+	s.SendJob(job)
+	assert.Equal(t, job.JobID, receive().JobID, "JobUpdates should receive the new job")
+
+	// A consumer that is not receiving does not block the read loop and is left with the newest jobs
+	for i := 0; i < JOB_UPDATES_BUFFER*2; i++ {
+		job.JobID = strconv.Itoa(i)
+		s.SendJob(job)
+	}
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Read loop should not be stalled by a full channel")
+	assert.Len(t, updates, JOB_UPDATES_BUFFER, "JobUpdates should be full")
+	assert.Equal(t, strconv.Itoa(JOB_UPDATES_BUFFER), receive().JobID, "Oldest jobs should be dropped")
+
+	StopGetWork()
+	for range updates {
+	}
+	_, ok = <-updates
+	assert.False(t, ok, "JobUpdates should be closed by StopGetWork")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	renewed := JobUpdates()
+	assert.NotEqual(t, updates, renewed, "StartGetWork should create a new channel")
+	select {
+	case job, ok := <-renewed:
+		assert.True(t, ok, "New JobUpdates should be open")
+		assert.NotEmpty(t, job.JobID, "New JobUpdates should receive the job of the new connection")
+	case <-time.After(time.Second * 5):
+		t.Fatalf("New JobUpdates should receive a job")
+	}
+}
+
+// Test the job of a lost connection is not hashed on while reconnecting, hashing resumes with the new connection's job
+func TestReconnectStaleJob(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetReconnect(false, 0)
+		SetReconnectBackoff(RECONNECT_DELAY, RECONNECT_MAX_DELAY)
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+	})
+
+	SetReconnect(true, 0)
+	SetReconnectBackoff(time.Millisecond*10, time.Millisecond*10)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	defer StopGetWork()
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	assert.False(t, JobStatus().Stale, "Job should not be stale while connected")
+
+	result, err := AttemptHashes(3)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.True(t, s.WaitSubmissions(result.Submitted, time.Second*5), "Test server should receive submissions")
+	before := len(s.Submissions())
+
+	// Hold the reconnect so the gap can be observed, the reconnected node has a new job
+	s.SetDelay(time.Millisecond * 500)
+	job := testJob
+	job.JobID = "1722895096811.0.notified"
+	job.Height++
+	s.CloseConnections()
+	s.SendJob(job)
+
+	assert.Eventually(t, func() bool { return JobStatus().Stale }, time.Second*5, time.Millisecond*5, "Job should be stale once the connection is lost")
+	assert.Equal(t, testJob.JobID, JobStatus().JobID, "Job status should still report the lost connection's job")
+
+	_, err = epoch.getWorkJob()
+	assert.ErrorIs(t, err, ErrJobNotReady, "Lost connection's job should not be hashed on")
+	_, _, _, _, err = epoch.powHash(nil)
+	assert.ErrorIs(t, err, ErrJobNotReady, "No hash should be made on the lost connection's job")
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "JobIsReady should wait for the reconnected job: %s", err)
+	assert.Equal(t, job.JobID, epoch.getJob().JobID, "JobIsReady should return with the new connection's job")
+	assert.False(t, JobStatus().Stale, "New connection's job should not be stale")
+
+	s.SetDelay(0)
+	assert.Eventually(t, IsActive, time.Second*5, time.Millisecond*10, "EPOCH should be active after reconnecting")
+	result, err = AttemptHashes(3)
+	assert.NoError(t, err, "AttemptHashes should not error after reconnecting: %s", err)
+	assert.True(t, s.WaitSubmissions(before+result.Submitted, time.Second*5), "Test server should receive submissions after reconnecting")
+	for _, p := range s.Submissions()[before:] {
+		assert.Equal(t, job.JobID, p.JobID, "Submissions after reconnecting should only be for the new job")
+	}
+}
+
+// Test concurrent batches get distinct BatchIDs in their results
+func TestBatchID(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	batches := 4
+	ids := make(chan uint64, batches+1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < batches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := AttemptHashes(3)
+			assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+			ids <- result.BatchID
+		}()
+	}
+	wg.Wait()
+
+	job, powhash, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	result, err := SubmitHashes([]Submit_Params{{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}})
+	assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+	ids <- result.BatchID
+	close(ids)
+
+	seen := map[uint64]bool{}
+	for id := range ids {
+		assert.NotZero(t, id, "BatchID should be set")
+		assert.False(t, seen[id], "BatchID %d should be unique", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, batches+1, "Every batch should have a distinct BatchID")
+
+	next, _ := AttemptHashes(1)
+	for id := range seen {
+		assert.Less(t, id, next.BatchID, "BatchID should increase for each batch")
+	}
+
+	StopGetWork()
+}
+
+// Test SubmitRefEPOCH reconstructs submissions from the current job
+func TestSubmitRef(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	_, err := SubmitRefs([]SubmitRef{{}})
+	assert.ErrorIs(t, err, ErrNotActive, "SubmitRefs should error when not active")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Every hash is a valid miniblock at difficulty 1
+	job, _, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	ref := SubmitRef{JobID: job.JobID, WorkHex: hex.EncodeToString(work[:]), DifficultyStr: diff.String()}
+	res, err := SubmitRefEPOCH(context.Background(), []SubmitRef{ref})
+	assert.NoError(t, err, "SubmitRefEPOCH should not error: %s", err)
+	assert.NoError(t, res.Error, "SubmitRefEPOCH result should not error: %s", res.Error)
+	assert.Equal(t, 1, res.Submitted, "SubmitRefEPOCH should submit the ref")
+	assert.True(t, s.WaitSubmissions(1, time.Second*5), "Test server should receive the ref submission")
+	assert.Equal(t, fmt.Sprintf("%x", work[:]), s.Submissions()[0].MiniBlockhashing_blob, "Submitted work should be the ref work")
+
+	// Invalid refs
+	unknown := ref
+	unknown.JobID = "1722895096807.1.notified"
+	_, err = SubmitRefs([]SubmitRef{ref, unknown})
+	assert.Error(t, err, "Ref with a JobID that is not current should error")
+
+	badWork := ref
+	badWork.WorkHex = "41dc"
+	_, err = SubmitRefs([]SubmitRef{badWork})
+	assert.Error(t, err, "Ref with short work should error")
+
+	badDiff := ref
+	badDiff.DifficultyStr = "one"
+	_, err = SubmitRefs([]SubmitRef{badDiff})
+	assert.Error(t, err, "Ref with invalid difficulty should error")
+
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, s.Submissions(), 1, "Rejected refs should not be submitted")
+
+	StopGetWork()
+}
+
+// Test a connection that closes as soon as it is started leaves consistent state
+func TestStartGetWorkDropped(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	s.DropConnections(true)
+
+	for i := 0; i < 10; i++ {
+		err := StartGetWork(testAddress, s.Endpoint())
+		if err != nil {
+			t.Fatalf("StartGetWork should not error: %s", err)
+		}
+
+		// Connection is usable until the read loop stops it
+		if IsActive() {
+			assert.NotNil(t, epoch.getSemaphore(), "Semaphore should be set while active")
+		}
+
+		assert.Eventually(t, func() bool { return !epoch.isRunning() }, time.Second*5, time.Millisecond, "Dropped connection should be stopped")
+		assert.Nil(t, epoch.getSemaphore(), "Semaphore should be nil after the connection is stopped")
+		assert.Nil(t, SubmitChannel(), "SubmitChannel should be nil after the connection is stopped")
+
+		_, err = AttemptHashes(1)
+		assert.ErrorIs(t, err, ErrNotActive, "AttemptHashes should error after the connection is stopped")
+	}
+
+	// StopGetWork while starting does not clear the new connection's state
+	s.DropConnections(false)
+	for i := 0; i < 10; i++ {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			StopGetWork()
+		}()
+
+		err := StartGetWork(testAddress, s.Endpoint())
+		wg.Wait()
+		if err != nil {
+			t.Fatalf("StartGetWork should not error: %s", err)
+		}
+
+		if epoch.isRunning() {
+			assert.NotNil(t, epoch.getSemaphore(), "Semaphore should be set while running")
+			assert.NotNil(t, SubmitChannel(), "SubmitChannel should be set while running")
+		} else {
+			assert.Nil(t, epoch.getSemaphore(), "Semaphore should be nil when stopped")
+		}
+
+		StopGetWork()
+	}
+}
+
+// Test Dashboard populates all of its fields while active
+func TestDashboard(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	_, err := Dashboard(context.Background())
+	assert.ErrorIs(t, err, ErrNotActive, "Dashboard should error when not active")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	_, err = AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+
+	dash, err := Dashboard(context.Background())
+	assert.NoError(t, err, "Dashboard should not error: %s", err)
+
+	assert.True(t, dash.Connection.Active, "Dashboard connection should be active")
+	assert.Equal(t, testAddress, dash.Connection.Address, "Dashboard address should be the connected address")
+	assert.Equal(t, strconv.Itoa(s.Port()), dash.Connection.Port, "Dashboard port should be the connected port")
+	assert.True(t, dash.Connection.TLS, "Dashboard should report TLS")
+	assert.Equal(t, GetMaxThreads(), dash.Connection.Threads, "Dashboard threads should be the session threads")
+	assert.Equal(t, GetMaxHashes(), dash.Connection.MaxHashes, "Dashboard maxHashes should be set")
+
+	// Settings changed for the next StartGetWork do not change the running connection
+	SetTLSEnabled(false)
+	SetPort(1)
+	dash, err = Dashboard(context.Background())
+	assert.NoError(t, err, "Dashboard should not error: %s", err)
+	assert.Equal(t, strconv.Itoa(s.Port()), dash.Connection.Port, "Dashboard port should be the connected port")
+	assert.True(t, dash.Connection.TLS, "Dashboard should report TLS of the connection")
+	SetTLSEnabled(true)
+	SetPort(s.Port())
+
+	assert.Equal(t, uint64(5), dash.Session.Hashes, "Dashboard session should include the attempt")
+	assert.NotEmpty(t, dash.Session.Version, "Dashboard session version should be set")
+	assert.Greater(t, dash.Hashrate, float64(0), "Dashboard hashrate should be above zero")
+	assert.Equal(t, dash.Session.CurrentHashrate, dash.Hashrate, "Dashboard hashrate should be the session hashrate")
+
+	assert.Equal(t, testJob.JobID, dash.Job.JobID, "Dashboard job should be the current job")
+	assert.Equal(t, testJob.Height, dash.Job.Height, "Dashboard job height should be set")
+	assert.Equal(t, testJob.Difficulty, dash.Job.Difficulty, "Dashboard job difficulty should be set")
+	assert.False(t, dash.Job.Stale, "Dashboard job should not be stale")
+	assert.True(t, dash.Healthy, "Dashboard should be healthy")
+	assert.Equal(t, HEALTH_HEALTHY, dash.Health.Status, "Dashboard health should be healthy")
+
+	// Healthy follows Health rather than only the job
+	epoch.Lock()
+	epoch.orphans.add(5, ORPHAN_ALERT_MIN)
+	epoch.Unlock()
+	dash, err = Dashboard(context.Background())
+	assert.NoError(t, err, "Dashboard should not error: %s", err)
+	assert.False(t, dash.Job.Stale, "Dashboard job should not be stale")
+	assert.Equal(t, Health(), dash.Health, "Dashboard health should be equal to Health")
+	assert.Equal(t, HEALTH_DEGRADED, dash.Health.Status, "Dashboard health should be degraded with a high orphan rate")
+	assert.False(t, dash.Healthy, "Dashboard should not be healthy when Health is degraded")
+	epoch.Lock()
+	epoch.orphans.reset()
+	epoch.Unlock()
+
+	_, ok := GetHandler()["DashboardEPOCH"]
+	assert.True(t, ok, "DashboardEPOCH should be registered")
+
+	StopGetWork()
+}
+
+// Test GetEndpointStats attributes connects, reconnects and blocks to the endpoint they occurred on
+func TestEndpointStats(t *testing.T) {
+	s1 := NewTestServer(t, testJob)
+	s2 := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+
+	// Stats are kept for the process so only what this test adds is checked
+	before := GetEndpointStats()
+
+	hash := func(s *testServer, hashes int) int {
+		err := StartGetWorkOnPort(testAddress, s.Endpoint(), s.Port())
+		if err != nil {
+			t.Fatalf("StartGetWorkOnPort should not error: %s", err)
+		}
+
+		err = JobIsReady(time.Second * 5)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+
+		result, err := AttemptHashes(hashes)
+		assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+
+		return result.Submitted
+	}
+
+	blocks1 := hash(s1, 3)
+	time.Sleep(time.Millisecond * 20)
+	StopGetWork()
+
+	blocks2 := hash(s2, 2)
+	s2.CloseConnections()
+	assert.Eventually(t, func() bool {
+		return GetEndpointStats()[s2.Endpoint()].Reconnects == before[s2.Endpoint()].Reconnects+1 && IsActive()
+	}, time.Second*5, time.Millisecond*10, "Endpoint should record the reconnect")
+
+	result, err := AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error after reconnecting: %s", err)
+	blocks2 += result.Submitted
+
+	during := GetEndpointStats()[s2.Endpoint()]
+	time.Sleep(time.Millisecond * 20)
+	assert.Greater(t, GetEndpointStats()[s2.Endpoint()].Uptime, during.Uptime, "Uptime should include the running connection")
+
+	StopGetWork()
+
+	stats := GetEndpointStats()
+	stat1, stat2 := stats[s1.Endpoint()], stats[s2.Endpoint()]
+	prev1, prev2 := before[s1.Endpoint()], before[s2.Endpoint()]
+
+	assert.Equal(t, prev1.Connects+1, stat1.Connects, "Endpoint 1 should have one more connect")
+	assert.Equal(t, prev1.Reconnects, stat1.Reconnects, "Endpoint 1 should not have any reconnects")
+	assert.Equal(t, prev1.MiniBlocks+blocks1, stat1.MiniBlocks, "Endpoint 1 blocks should be attributed to it")
+	assert.GreaterOrEqual(t, stat1.Uptime-prev1.Uptime, time.Millisecond*20, "Endpoint 1 uptime should include the connection")
+	assert.False(t, stat1.LastConnected.IsZero(), "Endpoint 1 should have a last connected time")
+
+	assert.Equal(t, prev2.Connects+1, stat2.Connects, "Endpoint 2 should have one more connect")
+	assert.Equal(t, prev2.Reconnects+1, stat2.Reconnects, "Endpoint 2 should have one more reconnect")
+	assert.Equal(t, prev2.MiniBlocks+blocks2, stat2.MiniBlocks, "Endpoint 2 blocks should be attributed to it")
+	assert.True(t, stat2.LastConnected.After(stat1.LastConnected), "Endpoint 2 should be connected after endpoint 1")
+
+	// Stopped endpoints do not accumulate uptime
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, stat2.Uptime, GetEndpointStats()[s2.Endpoint()].Uptime, "Uptime should not increase after stopping")
+}
+
+// Test StartGetWorkPool selects endpoints as per the PoolStrategy
+func TestPoolStrategy(t *testing.T) {
+	s1 := NewTestServer(t, testJob)
+	s2 := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetReconnectPolicy(RECONNECT_NEVER)
+		SetPoolStrategy(POOL_FIRST_AVAILABLE)
+	})
+
+	assert.Equal(t, POOL_FIRST_AVAILABLE, GetPoolStrategy(), "Default strategy should be POOL_FIRST_AVAILABLE")
+	assert.Error(t, SetPoolStrategy(PoolStrategy(-1)), "Invalid strategy should error")
+	assert.Error(t, SetPoolStrategy(POOL_LOWEST_LATENCY+1), "Invalid strategy should error")
+	assert.Error(t, StartGetWorkPool(testAddress, nil), "StartGetWorkPool should error without endpoints")
+	assert.Error(t, StartGetWorkPool(testAddress, []string{"127.0.0.1"}), "StartGetWorkPool should error on an endpoint without a port")
+
+	// Reserve a port with nothing listening on it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %s", err)
+	}
+	down := l.Addr().String()
+	l.Close()
+
+	connected := func(s *testServer, n int) func() bool {
+		return func() bool { return len(s.Addresses()) == n && IsActive() }
+	}
+
+	// First available
+	err = StartGetWorkPool(testAddress, []string{down, s1.Endpoint(), s2.Endpoint()})
+	if err != nil {
+		t.Fatalf("StartGetWorkPool should not error: %s", err)
+	}
+	assert.Eventually(t, connected(s1, 1), time.Second*5, time.Millisecond*10, "First available endpoint should be connected to")
+	assert.Empty(t, s2.Addresses(), "Later endpoints should not be connected to")
+	StopGetWork()
+
+	// Round robin cycles endpoints across reconnects
+	SetReconnectPolicy(RECONNECT_TRANSIENT)
+	assert.NoError(t, SetPoolStrategy(POOL_ROUND_ROBIN), "Valid strategy should not error")
+
+	err = StartGetWorkPool(testAddress, []string{s1.Endpoint(), s2.Endpoint()})
+	if err != nil {
+		t.Fatalf("StartGetWorkPool should not error: %s", err)
+	}
+	assert.Eventually(t, connected(s1, 2), time.Second*5, time.Millisecond*10, "Round robin should start on the first endpoint")
+
+	s1.CloseConnections()
+	assert.Eventually(t, connected(s2, 1), time.Second*5, time.Millisecond*10, "Round robin should reconnect to the next endpoint")
+
+	s2.CloseConnections()
+	assert.Eventually(t, connected(s1, 3), time.Second*5, time.Millisecond*10, "Round robin should cycle back to the first endpoint")
+	StopGetWork()
+
+	// Lowest latency picks the faster endpoint
+	SetReconnectPolicy(RECONNECT_NEVER)
+	assert.NoError(t, SetPoolStrategy(POOL_LOWEST_LATENCY), "Valid strategy should not error")
+	s1.SetDelay(time.Millisecond * 200)
+
+	err = StartGetWorkPool(testAddress, []string{down, s1.Endpoint(), s2.Endpoint()})
+	if err != nil {
+		t.Fatalf("StartGetWorkPool should not error: %s", err)
+	}
+	assert.Eventually(t, connected(s2, 2), time.Second*5, time.Millisecond*10, "Lowest latency should connect to the faster endpoint")
+	assert.Len(t, s1.Addresses(), 3, "Slower endpoint should not be connected to")
+	assert.Equal(t, []string{s2.Endpoint(), s1.Endpoint(), down}, epoch.byLatency([]string{down, s1.Endpoint(), s2.Endpoint()}), "Endpoints should be ordered by latency with unreachable endpoints last")
+	StopGetWork()
+
+	// Probes verify certificates as per SetTLSConfig, untrusted endpoints are treated as unreachable
+	t.Cleanup(func() { SetTLSConfig(nil) })
+	SetTLSConfig(&tls.Config{})
+	assert.Equal(t, []string{down, s1.Endpoint(), s2.Endpoint()}, epoch.byLatency([]string{down, s1.Endpoint(), s2.Endpoint()}), "Untrusted endpoints should keep their order")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s1.Certificate())
+	roots.AddCert(s2.Certificate())
+	SetTLSConfig(&tls.Config{RootCAs: roots})
+	assert.Equal(t, []string{s2.Endpoint(), s1.Endpoint(), down}, epoch.byLatency([]string{down, s1.Endpoint(), s2.Endpoint()}), "Trusted endpoints should be ordered by latency")
+}
+
+// Test the GetWork url path round trips the address exactly
+func TestWorkURL(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	addresses := []string{
+		testAddress,
+		"deto1/../admin",
+		"deto1?query=1#fragment",
+		"deto1 %2F%zz;+&=",
+		"deto1ü",
+	}
+
+	for _, address := range addresses {
+		target := workURL("wss", s.Endpoint(), DEFAULT_WORK_PATH, address)
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatalf("workURL %q should parse: %s", target, err)
+		}
+
+		assert.Equal(t, s.Endpoint(), u.Host, "workURL host should be the endpoint")
+		assert.Equal(t, "/ws/"+address, u.Path, "workURL path should round trip the address")
+		assert.Empty(t, u.RawQuery, "workURL should not have a query")
+		assert.Empty(t, u.Fragment, "workURL should not have a fragment")
+		assert.True(t, strings.HasPrefix(u.EscapedPath(), "/ws/") && !strings.Contains(strings.TrimPrefix(u.EscapedPath(), "/ws/"), "/"), "workURL address should be a single path segment")
+
+		ws, err := epoch.dial(context.Background(), target)
+		if err != nil {
+			t.Fatalf("Dialing %q should not error: %s", target, err)
+		}
+		ws.Close()
+
+		got := s.Addresses()
+		assert.Equal(t, address, got[len(got)-1], "Test server should receive the exact address")
+	}
+}
+
+// Test SwitchAddress reconnects with the new address keeping or resetting the session
+func TestSwitchAddress(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetAddress(testAddress) })
+
+	other := rpc.NewAddressFromKeys(&crypto.GPoint)
+	other.Mainnet = false
+	newAddress := other.String()
+
+	err := SwitchAddress(newAddress, false)
+	assert.ErrorIs(t, err, ErrNotActive, "SwitchAddress should error when not active")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	result, err := AttemptHashes(3)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+
+	// Invalid address keeps the connection
+	err = SwitchAddress("deto1invalid", false)
+	assert.Error(t, err, "SwitchAddress should error on an invalid address")
+	assert.True(t, IsActive(), "EPOCH should still be active after an invalid address")
+	assert.Equal(t, testAddress, GetAddress(), "Address should not change after an invalid address")
+	assert.Len(t, s.Addresses(), 1, "Invalid address should not reconnect")
+
+	// Session is kept
+	err = SwitchAddress(newAddress, false)
+	assert.NoError(t, err, "SwitchAddress should not error: %s", err)
+	assert.True(t, IsActive(), "EPOCH should be active after switching")
+	assert.Equal(t, newAddress, GetAddress(), "Address should be the new address")
+	assert.Eventually(t, func() bool { return len(s.Addresses()) == 2 }, time.Second*5, time.Millisecond*10, "Switching should reconnect")
+	assert.Equal(t, []string{testAddress, newAddress}, s.Addresses(), "New connection should use the new address")
+
+	session, _ := GetSession(time.Second)
+	assert.Equal(t, result.Hashes, session.Hashes, "Session hashes should be kept")
+	assert.Equal(t, result.Submitted, session.MiniBlocks, "Session miniblocks should be kept")
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	_, err = AttemptHashes(2)
+	assert.NoError(t, err, "AttemptHashes should not error after switching: %s", err)
+
+	session, _ = GetSession(time.Second)
+	assert.Equal(t, result.Hashes+2, session.Hashes, "Session should add hashes after switching")
+
+	// Session is reset
+	err = SwitchAddress(testAddress, true)
+	assert.NoError(t, err, "SwitchAddress should not error: %s", err)
+	assert.Eventually(t, func() bool { return len(s.Addresses()) == 3 }, time.Second*5, time.Millisecond*10, "Switching should reconnect")
+	assert.Equal(t, testAddress, s.Addresses()[2], "New connection should use the switched address")
+
+	session, _ = GetSession(time.Second)
+	assert.Zero(t, session.Hashes, "Session hashes should be reset")
+	assert.Zero(t, session.MiniBlocks, "Session miniblocks should be reset")
+
+	StopGetWork()
+}
+
+// Test the String summaries of results and sessions
+func TestResultString(t *testing.T) {
+	tests := []struct {
+		hashPerSec float64
+		expect     string
+	}{
+		{0, "0.0 H/s"},
+		{853.114, "853.1 H/s"},
+		{1000, "1.0 KH/s"},
+		{1234567, "1.2 MH/s"},
+		{2500000000, "2.5 GH/s"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, HashrateToString(tt.hashPerSec), "HashrateToString %f should be equal", tt.hashPerSec)
+	}
+
+	result := EPOCH_Result{Hashes: 10000, Submitted: 42, Duration: 83, HashPerSec: 1200000}
+	assert.Equal(t, "42 submitted / 10000 hashes @ 1.2 MH/s in 83ms", result.String(), "Result summary should be equal")
+
+	result = EPOCH_Result{Hashes: 1, Submitted: 1, Duration: 0.412, HashPerSec: 2427.18}
+	assert.Equal(t, "1 submitted / 1 hashes @ 2.4 KH/s in 412µs", result.String(), "Result summary should keep microsecond durations")
+
+	result = EPOCH_Result{Hashes: 5, Duration: 117.2149, HashPerSec: 42.66, Error: fmt.Errorf("connection is closed")}
+	assert.Equal(t, "0 submitted / 5 hashes @ 42.7 H/s in 117.215ms: connection is closed", result.String(), "Result summary should include the error")
+	assert.Equal(t, result.String(), fmt.Sprintf("%v", result), "Result should format with its summary")
+
+	session := GetSessionEPOCH_Result{Hashes: 1200, MiniBlocks: 3, Accepted: 2, Rejected: 1, CurrentHashrate: 853.11, Threads: 2}
+	assert.Equal(t, "1200 hashes, 3 miniblocks (2 accepted / 1 rejected) @ 853.1 H/s on 2 threads", session.String(), "Session summary should be equal")
+}
+
+// Test NetworkShareEstimate computes the share and miniblocks per day from the job difficulty
+func TestNetworkShare(t *testing.T) {
+	t.Cleanup(func() { SetBlockTime(DEFAULT_BLOCK_TIME) })
+
+	assert.Equal(t, DEFAULT_BLOCK_TIME, GetBlockTime(), "Default block time should be DEFAULT_BLOCK_TIME")
+	assert.Error(t, SetBlockTime(0), "Zero block time should error")
+	assert.NoError(t, SetBlockTime(time.Second*9), "Valid block time should not error")
+	assert.Equal(t, time.Second*9, GetBlockTime(), "Block time should be equal")
+
+	tests := []struct {
+		difficulty string
+		highDiff   bool
+		hashrate   float64
+		blockTime  time.Duration
+		network    float64
+		share      float64
+		perDay     float64
+	}{
+		{"1800000", false, 1000, time.Second * 18, 1800000, 1000.0 / 1800000, 48},
+		{"36000", false, 500, time.Second * 18, 36000, 500.0 / 36000, 1200},
+		{"1800000", false, 0, time.Second * 18, 1800000, 0, 0},
+		{"900", false, 100, time.Second * 9, 1800, 100.0 / 1800, 9600},
+		{"16200000", true, 1000, time.Second * 18, 1800000, 1000.0 / 1800000, 1000 * 86400 / 16200000.0},
+	}
+
+	for _, tt := range tests {
+		result, err := networkShare(tt.difficulty, tt.highDiff, tt.hashrate, tt.blockTime)
+		assert.NoError(t, err, "networkShare should not error: %s", err)
+		assert.InDelta(t, tt.network, result.NetworkHashrate, 1e-9, "Network hashrate for difficulty %s should be equal", tt.difficulty)
+		assert.InDelta(t, tt.share, result.Share, 1e-9, "Share for difficulty %s at %f H/s should be equal", tt.difficulty, tt.hashrate)
+		assert.InDelta(t, tt.perDay, result.MiniBlocksPerDay, 1e-9, "Miniblocks per day for difficulty %s at %f H/s should be equal", tt.difficulty, tt.hashrate)
+		assert.Equal(t, tt.difficulty, result.Difficulty, "Difficulty should be equal")
+		assert.Equal(t, tt.blockTime, result.BlockTime, "Block time should be equal")
+	}
+
+	assert.False(t, blobHighDiff(testJob.Blockhashing_blob), "Test job should not be HighDiff")
+	assert.True(t, blobHighDiff("51"+testJob.Blockhashing_blob[2:]), "Blob with the HighDiff bit should be HighDiff")
+	assert.False(t, blobHighDiff(""), "Empty blob should not be HighDiff")
+
+	_, err := networkShare("", false, 1000, time.Second)
+	assert.Error(t, err, "Missing difficulty should error")
+	_, err = networkShare("0", false, 1000, time.Second)
+	assert.Error(t, err, "Zero difficulty should error")
+
+	// No job
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+
+	_, err = NetworkShareEstimate()
+	assert.ErrorIs(t, err, ErrJobNotReady, "NetworkShareEstimate should error without a job")
+
+	s := NewTestServer(t, testJob)
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	_, err = AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+
+	session, _ := GetSession(time.Second)
+	result, err := NetworkShareEstimate()
+	assert.NoError(t, err, "NetworkShareEstimate should not error: %s", err)
+	assert.Equal(t, session.CurrentHashrate, result.Hashrate, "Estimate should use the session hash rate")
+	assert.Equal(t, testJob.Difficulty, result.Difficulty, "Estimate should use the job difficulty")
+	assert.InDelta(t, session.CurrentHashrate/2, result.Share, 1e-9, "Share should be hashrate over difficulty scaled to the block time")
+
+	StopGetWork()
+}
+
+// Test the pooled POW check and batch state match their unpooled results
+func TestPools(t *testing.T) {
+	diffs := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(1000), big.NewInt(1 << 40), new(big.Int).Lsh(big.NewInt(1), 255)}
+	for i := 0; i < 200; i++ {
+		var powhash [32]byte
+		rand.Read(powhash[:])
+		// Small hashes meet higher difficulties
+		if i%2 == 0 {
+			for j := 16; j < 32; j++ {
+				powhash[j] = 0
+			}
+		}
+
+		for _, diff := range diffs {
+			assert.Equal(t, blockchain.CheckPowHashBig(powhash, diff), checkPowHash(powhash, diff), "checkPowHash %x should match CheckPowHashBig at difficulty %s", powhash, diff)
+		}
+	}
+
+	assert.False(t, checkPowHash([32]byte{}, big.NewInt(0)), "checkPowHash should be false at zero difficulty")
+
+	// Batch state is reset between uses
+	b := getBatchState()
+	b.workErr.set(fmt.Errorf("worker error"))
+	assert.True(t, b.used.add([]byte("nonce")), "Nonce should not be used")
+	putBatchState(b)
+
+	for i := 0; i < 5; i++ {
+		b = getBatchState()
+		assert.NoError(t, b.workErr.get(), "Pooled batch state should not have an error")
+		assert.True(t, b.used.add([]byte("nonce")), "Pooled batch state should not have used nonces")
+		putBatchState(b)
+	}
+}
+
+// Test SubmitHashes and the SubmitChannel verify submission signatures when a worker key is set
+func TestWorkerKey(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetWorkerKey(nil) })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	key := []byte("shared worker key")
+	SetWorkerKey(key)
+
+	submission := func() Submit_Params {
+		job, powhash, work, diff, err := epoch.powHash(nil)
+		if err != nil {
+			t.Fatalf("powHash should not error: %s", err)
+		}
+
+		return Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+	}
+
+	// Valid signature
+	p := submission()
+	p.Sign(key)
+	res, err := SubmitHashes([]Submit_Params{p})
+	assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+	assert.NoError(t, res.Error, "Signed submission should not error: %s", res.Error)
+	assert.Equal(t, 1, res.Submitted, "Signed submission should be submitted")
+
+	// Unsigned, wrong key and tampered values
+	unsigned := submission()
+
+	wrongKey := submission()
+	wrongKey.Sign([]byte("attacker key"))
+
+	tamperedWork := submission()
+	tamperedWork.Sign(key)
+	tamperedWork.EpochWork[block.MINIBLOCK_SIZE-2]++
+
+	tamperedJob := submission()
+	tamperedJob.Sign(key)
+	tamperedJob.Job.JobID = "1722895096807.1.notified"
+
+	tamperedDiff := submission()
+	tamperedDiff.Sign(key)
+	tamperedDiff.Difficulty.SetInt64(2)
+
+	badHex := submission()
+	badHex.Signature = "not hex"
+
+	for name, p := range map[string]Submit_Params{"unsigned": unsigned, "wrong key": wrongKey, "tampered work": tamperedWork, "tampered job": tamperedJob, "tampered difficulty": tamperedDiff, "bad hex": badHex} {
+		res, err := SubmitHashes([]Submit_Params{p})
+		assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+		assert.Error(t, res.Error, "Submission that is %s should error", name)
+		assert.Zero(t, res.Submitted, "Submission that is %s should not be submitted", name)
+	}
+
+	// Refs carry the signature of the submission they reconstruct
+	p = submission()
+	p.Sign(key)
+	ref := SubmitRef{JobID: p.Job.JobID, WorkHex: hex.EncodeToString(p.EpochWork[:]), DifficultyStr: p.Difficulty.String(), Signature: p.Signature}
+	res, err = SubmitRefs([]SubmitRef{ref})
+	assert.NoError(t, err, "SubmitRefs should not error: %s", err)
+	assert.NoError(t, res.Error, "Signed ref should not error: %s", res.Error)
+	assert.Equal(t, 1, res.Submitted, "Signed ref should be submitted")
+
+	ref.Signature = ""
+	res, _ = SubmitRefs([]SubmitRef{ref})
+	assert.Error(t, res.Error, "Unsigned ref should error")
+
+	assert.True(t, s.WaitSubmissions(2, time.Second*5), "Test server should receive the signed submissions")
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, s.Submissions(), 2, "Test server should only receive the signed submissions")
+
+	// The SubmitChannel checks signatures the same as SubmitHashes
+	ch := SubmitChannel()
+	ch <- submission()
+	p = submission()
+	p.Sign(key)
+	ch <- p
+
+	assert.True(t, s.WaitSubmissions(3, time.Second*5), "Test server should receive the signed streamed submission")
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, s.Submissions(), 3, "Test server should not receive the unsigned streamed submission")
+
+	// No key
+	SetWorkerKey(nil)
+	res, _ = SubmitHashes([]Submit_Params{submission()})
+	assert.NoError(t, res.Error, "Unsigned submission should not error without a worker key: %s", res.Error)
+
+	StopGetWork()
+}
+
+// Test future job blob versions are only hashed on once accepted
+func TestAcceptedVersions(t *testing.T) {
+	job := testJob
+	job.Blockhashing_blob = "42" + testJob.Blockhashing_blob[2:] // version 2
+	s := NewTestServer(t, job)
+	t.Cleanup(func() { SetAcceptedVersions([]byte{1}) })
+
+	assert.Equal(t, []byte{1}, GetAcceptedVersions(), "Default accepted versions should be 1")
+	assert.Error(t, SetAcceptedVersions(nil), "SetAcceptedVersions should error with no versions")
+	assert.Error(t, SetAcceptedVersions([]byte{1, MAX_BLOB_VERSION + 1}), "SetAcceptedVersions should error with a version above MAX_BLOB_VERSION")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	assert.Eventually(t, func() bool { return epoch.getJob().Blockhashing_blob == job.Blockhashing_blob }, time.Second*5, time.Millisecond*10, "Version 2 job should be received")
+
+	_, _, _, _, err = epoch.powHash(nil)
+	assert.Error(t, err, "powHash should error with a version 2 blob by default")
+
+	err = SetAcceptedVersions([]byte{1, 2})
+	assert.NoError(t, err, "SetAcceptedVersions should not error: %s", err)
+	assert.Equal(t, []byte{1, 2}, GetAcceptedVersions(), "Accepted versions should be equal")
+
+	res, err := AttemptHashes(10)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.NoError(t, res.Error, "Version 2 blob should be hashed once accepted: %s", res.Error)
+	assert.Equal(t, uint64(10), res.Hashes, "Hashes should be equal")
+
+	session, err := GetSession(time.Second * 5)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, []int{2}, session.BlobVersions, "Session should report the version 2 blob was seen")
+
+	StopGetWork()
+}
+
+// Test JobIsReadyContext returns when its context is cancelled and when a job is received
+func TestJobIsReadyContext(t *testing.T) {
+	epoch.newJob(rpc.GetBlockTemplate_Result{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := JobIsReadyContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "JobIsReadyContext should return ctx.Err() when cancelled")
+	assert.Less(t, time.Since(start), time.Millisecond*500, "JobIsReadyContext should return promptly when cancelled")
+
+	err = JobIsReady(time.Millisecond * 50)
+	assert.Error(t, err, "JobIsReady should error after timeout")
+	assert.NotErrorIs(t, err, context.DeadlineExceeded, "JobIsReady should keep its timeout error")
+
+	// Signaled by newJob
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		epoch.newJob(testJob)
+	}()
+
+	start = time.Now()
+	err = JobIsReadyContext(context.Background())
+	assert.NoError(t, err, "JobIsReadyContext should not error when a job is received: %s", err)
+	assert.Less(t, time.Since(start), time.Millisecond*500, "JobIsReadyContext should return promptly when a job is received")
+
+	epoch.newJob(rpc.GetBlockTemplate_Result{})
+}
+
+// Test the rolling orphan rate of reported blocks and its alert
+func TestOrphanRate(t *testing.T) {
+	var alerts []OrphanAlertEvent
+	t.Cleanup(func() {
+		SetOrphanAlert(0, nil)
+		epoch.Lock()
+		epoch.orphans.reset()
+		epoch.accepted = 0
+		epoch.rejected = 0
+		epoch.Unlock()
+	})
+
+	assert.Error(t, SetOrphanAlert(0, func(OrphanAlertEvent) {}), "SetOrphanAlert should error with a threshold of 0")
+	assert.Error(t, SetOrphanAlert(100, func(OrphanAlertEvent) {}), "SetOrphanAlert should error with a threshold of 100")
+	err := SetOrphanAlert(20, func(event OrphanAlertEvent) { alerts = append(alerts, event) })
+	assert.NoError(t, err, "SetOrphanAlert should not error: %s", err)
+
+	epoch.Lock()
+	epoch.orphans.reset()
+	epoch.accepted = 0
+	epoch.rejected = 0
+	epoch.Unlock()
+
+	report := func(accepted, rejected uint64) GetSessionEPOCH_Result {
+		job := testJob
+		job.MiniBlocks = accepted
+		job.Rejected = rejected
+		epoch.newJob(job)
+		session, _ := GetSession(0)
+
+		return session
+	}
+
+	// Below ORPHAN_ALERT_MIN the alert is not called
+	session := report(4, 1)
+	assert.Equal(t, float64(20), session.OrphanRate, "Orphan rate should be equal")
+	session = report(4, 4)
+	assert.Equal(t, 50.0, session.OrphanRate, "Orphan rate should be equal")
+	assert.Empty(t, alerts, "Alert should not be called below ORPHAN_ALERT_MIN blocks")
+
+	// 10 of 20 rejected
+	session = report(10, 10)
+	assert.Equal(t, 50.0, session.OrphanRate, "Orphan rate should be equal")
+	if assert.Len(t, alerts, 1, "Alert should be called once the rate exceeds its threshold") {
+		assert.Equal(t, 50.0, alerts[0].Rate, "Alert rate should be equal")
+		assert.Equal(t, float64(20), alerts[0].Threshold, "Alert threshold should be equal")
+		assert.Equal(t, 20, alerts[0].Blocks, "Alert blocks should be equal")
+	}
+
+	// Still above threshold, no repeat alert
+	session = report(10, 11)
+	assert.Len(t, alerts, 1, "Alert should not repeat while the rate stays above threshold")
+
+	// Accepted blocks roll the rejections out of the window, 11 of the last 100 rejected
+	session = report(89, 11)
+	assert.Equal(t, 11.0, session.OrphanRate, "Orphan rate should be equal")
+	session = report(189, 11)
+	assert.Equal(t, float64(0), session.OrphanRate, "Rejections should roll out of the window")
+
+	// Rising above threshold again re-fires
+	report(189, 41)
+	assert.Len(t, alerts, 2, "Alert should be called again once the rate rises above threshold")
+}
+
+// Test submissions are bounded by maxSubmits separately from the hashing threads
+func TestMaxSubmitConcurrency(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() {
+		SetMaxThreads(maxThreads)
+		SetMaxSubmitConcurrency(DEFAULT_MAX_SUBMITS)
+	})
+
+	assert.Equal(t, DEFAULT_MAX_SUBMITS, GetMaxSubmitConcurrency(), "Default max submit concurrency should be equal")
+	assert.Error(t, SetMaxSubmitConcurrency(0), "SetMaxSubmitConcurrency should error below 1")
+
+	SetMaxThreads(runtime.NumCPU())
+	err := SetMaxSubmitConcurrency(1)
+	assert.NoError(t, err, "SetMaxSubmitConcurrency should not error: %s", err)
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	submitting := epoch.getSubmitSemaphore()
+	assert.Equal(t, 1, cap(submitting), "Submit slots should be sized from maxSubmits")
+	assert.Equal(t, GetMaxThreads(), cap(epoch.getSemaphore()), "Hashing slots should be sized from maxThreads")
+
+	// Hold the only submit slot, every hash is valid so workers can hash but not submit
+	submitting <- struct{}{}
+
+	done := make(chan EPOCH_Result)
+	go func() {
+		res, _ := AttemptHashes(3)
+		done <- res
+	}()
+
+	time.Sleep(time.Millisecond * 300)
+	assert.Empty(t, s.Submissions(), "Workers should not submit without a submit slot")
+	assert.Len(t, submitting, 1, "Submit slot should still be held")
+
+	<-submitting
+
+	select {
+	case res := <-done:
+		assert.NoError(t, res.Error, "AttemptHashes should not error: %s", res.Error)
+		assert.Equal(t, 3, res.Submitted, "Submitted should be equal once submit slots are available")
+	case <-time.After(time.Second * 10):
+		t.Fatal("AttemptHashes should finish once the submit slot is released")
+	}
+
+	assert.True(t, s.WaitSubmissions(3, time.Second*5), "Test server should receive the submissions")
+	assert.Empty(t, submitting, "Submit slots should be released")
+
+	StopGetWork()
+}
+
+// Test a submission the node does not read does not block the connection from being checked or stopped
+func TestStalledSubmit(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	stalled := stallConn(epoch)
+	done := make(chan EPOCH_Result)
+	go func() {
+		res, _ := AttemptHashes(1)
+		done <- res
+	}()
+
+	select {
+	case <-stalled.writing:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Submission should be written")
+	}
+
+	assert.WithinDuration(t, time.Now().Add(SUBMIT_TIMEOUT), stalled.Deadline(), time.Second, "Submission should have a write deadline")
+
+	active := make(chan bool)
+	go func() { active <- IsActive() }()
+	select {
+	case ok := <-active:
+		assert.True(t, ok, "EPOCH should be active while a submission is being written")
+	case <-time.After(time.Second * 2):
+		t.Fatal("IsActive should not wait for a submission being written")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		StopGetWork()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("StopGetWork should not wait for a submission being written")
+	}
+
+	select {
+	case res := <-done:
+		assert.Zero(t, res.Submitted, "Submission should not be counted when its write fails")
+	case <-time.After(time.Second * 5):
+		t.Fatal("AttemptHashes should end when the connection is closed")
+	}
+}
+
+// Test EPOCH stops with ErrNetworkChanged when the network is switched during a session
+func TestNetworkChanged(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	assert.Error(t, SetNetwork(""), "Empty network should error")
+
+	start := func() {
+		// Clear the last connection's job so JobIsReady waits for this connection's job before the network is switched
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+
+		err := StartGetWork(testAddress, s.Endpoint())
+		if err != nil {
+			t.Fatalf("StartGetWork should not error: %s", err)
+		}
+
+		err = JobIsReady(time.Second * 5)
+		assert.NoError(t, err, "Finding job should not error: %s", err)
+		assert.NoError(t, epoch.checkNetwork(), "Network should not have changed")
+		assert.Equal(t, globals.Config.Name, GetNetwork(), "Network should be the globals network when connecting")
+	}
+
+	// Globals are not switched while the connection's goroutines are running, the host reports the switch with SetNetwork
+	// Detected before submitting
+	start()
+	assert.NoError(t, SetNetwork(config.Mainnet.Name), "SetNetwork should not error")
+	res, err := AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.ErrorIs(t, res.Error, ErrNetworkChanged, "Submitting should error when the network has changed")
+	assert.Zero(t, res.Submitted, "Nothing should be submitted when the network has changed")
+	assert.Eventually(t, func() bool { return !epoch.isRunning() }, time.Second*5, time.Millisecond*10, "EPOCH should stop when the network has changed")
+	assert.Empty(t, s.Submissions(), "Test server should not receive submissions")
+
+	// Detected when a job is received
+	start()
+	assert.NoError(t, SetNetwork(config.Mainnet.Name), "SetNetwork should not error")
+	s.SendJob(testJob)
+	assert.Eventually(t, func() bool { return !epoch.isRunning() }, time.Second*5, time.Millisecond*10, "EPOCH should stop when a job is received after the network has changed")
+
+	// Setting the network that was connected on is not a change
+	start()
+	assert.NoError(t, SetNetwork(globals.Config.Name), "SetNetwork should not error")
+	res, err = AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.NoError(t, res.Error, "Submitting should not error when the network is unchanged")
+	assert.True(t, epoch.isRunning(), "EPOCH should keep running when the network is unchanged")
+
+	StopGetWork()
+}
+
+// Test each submission attempt is written to the submission log and the log is rotated at its max size
+func TestSubmissionLog(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	path := filepath.Join(t.TempDir(), "submissions.log")
+	t.Cleanup(func() {
+		SetSubmissionLogFile("")
+		SetSubmissionLogMaxSize(0)
+	})
+
+	assert.Error(t, SetSubmissionLogFile(filepath.Join(t.TempDir(), "missing", "submissions.log")), "SetSubmissionLogFile should error when the file can not be created")
+	assert.Error(t, SetSubmissionLogMaxSize(-1), "SetSubmissionLogMaxSize should error below 0")
+
+	err := SetSubmissionLogFile(path)
+	assert.NoError(t, err, "SetSubmissionLogFile should not error: %s", err)
+	assert.Equal(t, path, GetSubmissionLogFile(), "Submission log file should be equal")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	res, err := AttemptHashes(3)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Equal(t, 3, res.Submitted, "Submitted should be equal")
+
+	entries := func(path string) (entries []SubmissionLogEntry) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Reading submission log should not error: %s", err)
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			var entry SubmissionLogEntry
+			assert.NoError(t, json.Unmarshal([]byte(line), &entry), "Submission log line should be JSON: %s", line)
+			entries = append(entries, entry)
+		}
+
+		return
+	}
+
+	logged := entries(path)
+	if assert.Len(t, logged, 3, "Submission log should have an entry for each submission") {
+		for _, entry := range logged {
+			assert.Equal(t, SUBMIT_OUTCOME_SUBMITTED, entry.Outcome, "Outcome should be equal")
+			assert.Equal(t, res.BatchID, entry.Batch, "Batch should be equal")
+			assert.Equal(t, testJob.JobID, entry.JobID, "JobID should be equal")
+			assert.Equal(t, testJob.Height, entry.Height, "Height should be equal")
+			assert.Equal(t, testJob.Difficulty, entry.Difficulty, "Difficulty should be equal")
+			assert.Len(t, entry.Work, block.MINIBLOCK_SIZE*2, "Work should be the hex of the miniblock")
+			assert.False(t, entry.Time.IsZero(), "Time should be set")
+		}
+	}
+
+	// A failed rotation keeps appending to the current log, a directory can not be replaced by the rotated log
+	info, _ := os.Stat(path)
+	err = SetSubmissionLogMaxSize(info.Size())
+	assert.NoError(t, err, "SetSubmissionLogMaxSize should not error: %s", err)
+	blocked := filepath.Join(path+".1", "blocked")
+	if err := os.MkdirAll(blocked, 0700); err != nil {
+		t.Fatalf("Creating blocking directory should not error: %s", err)
+	}
+	res, _ = AttemptHashes(1)
+	assert.Equal(t, 1, res.Submitted, "Submitted should be equal")
+	assert.Len(t, entries(path), 4, "Current log should have the entry when rotating fails")
+
+	// Rotated once the next entry would exceed max size
+	assert.NoError(t, os.RemoveAll(path+".1"), "Removing blocking directory should not error")
+	res, _ = AttemptHashes(1)
+	assert.Equal(t, 1, res.Submitted, "Submitted should be equal")
+	assert.Len(t, entries(path+".1"), 4, "Rotated log should have the previous entries")
+	assert.Len(t, entries(path), 1, "New log should have the entry after rotating")
+
+	// Disabled
+	err = SetSubmissionLogFile("")
+	assert.NoError(t, err, "SetSubmissionLogFile should not error: %s", err)
+	AttemptHashes(1)
+	assert.Len(t, entries(path), 1, "Nothing should be logged once disabled")
+
+	StopGetWork()
+}
+
+// Test concurrent workers read consistent job snapshots while newJob publishes, run with -race
+func TestJobSnapshot(t *testing.T) {
+	t.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
+
+	job := func(height uint64) rpc.GetBlockTemplate_Result {
+		j := testJob
+		j.Height = height
+		j.JobID = strconv.FormatUint(height, 10)
+
+		return j
+	}
+
+	epoch.newJob(job(1))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				j, err := epoch.getWorkJob()
+				if !assert.NoError(t, err, "getWorkJob should not error: %s", err) ||
+					!assert.Equal(t, strconv.FormatUint(j.Height, 10), j.JobID, "Job should not be torn while newJob publishes") {
+					return
+				}
+
+				status := JobStatus()
+				assert.Equal(t, strconv.FormatUint(status.Height, 10), status.JobID, "JobStatus should not be torn while newJob publishes")
+			}
+		}()
+	}
+
+	for h := uint64(2); h <= 1000; h++ {
+		epoch.newJob(job(h))
+		if h%100 == 0 {
+			epoch.newJob(rpc.GetBlockTemplate_Result{JobID: "no work"}) // last job with work is still used
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, "no work", epoch.getJob().JobID, "Current job should be the last published")
+	j, err := epoch.getWorkJob()
+	assert.NoError(t, err, "getWorkJob should not error: %s", err)
+	assert.Equal(t, uint64(1000), j.Height, "Work job should be the last job with work")
+}
+
+// Test session totals exported from one session are restored by the next StartGetWork
+func TestExportSession(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	res, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Equal(t, 5, res.Submitted, "Submitted should be equal")
+
+	epoch.Lock()
+	epoch.session.Accepted = 4
+	epoch.session.Rejected = 1
+	epoch.Unlock()
+
+	exported, err := GetSession(0)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.False(t, exported.Started.IsZero(), "Session should have a start time")
+
+	data, err := ExportSession()
+	assert.NoError(t, err, "ExportSession should not error: %s", err)
+
+	assert.ErrorIs(t, ImportSession(data), ErrAlreadyRunning, "ImportSession should error while running")
+
+	StopGetWork()
+
+	assert.Error(t, ImportSession([]byte("{")), "ImportSession should error with invalid data")
+	assert.Error(t, ImportSession([]byte(`{"version":0}`)), "ImportSession should error with an unknown version")
+
+	err = ImportSession(data)
+	assert.NoError(t, err, "ImportSession should not error: %s", err)
+
+	time.Sleep(time.Millisecond * 10)
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	restored, err := GetSession(0)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, exported.Hashes, restored.Hashes, "Restored hashes should be equal")
+	assert.Equal(t, exported.MiniBlocks, restored.MiniBlocks, "Restored miniblocks should be equal")
+	assert.Equal(t, exported.Accepted, restored.Accepted, "Restored accepted should be equal")
+	assert.Equal(t, exported.Rejected, restored.Rejected, "Restored rejected should be equal")
+	assert.True(t, exported.Started.Equal(restored.Started), "Restored start time should be equal")
+
+	// Totals continue from the restored session
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+	AttemptHashes(5)
+	session, _ := GetSession(0)
+	assert.Equal(t, exported.Hashes+5, session.Hashes, "Hashes should continue from the restored session")
+
+	StopGetWork()
+
+	// The import is only restored once
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	session, _ = GetSession(0)
+	assert.Zero(t, session.Hashes, "A new session should not restore the import again")
+	assert.True(t, session.Started.After(exported.Started), "A new session should have a new start time")
+
+	StopGetWork()
+}
+
+// Test StopHashing winds down running batches with partial results without disconnecting
+func TestStopHashing(t *testing.T) {
+	job := testJob
+	job.Difficulty = "1000000000000" // no submissions
+	s := NewTestServer(t, job)
+
+	assert.NoError(t, StopHashing(time.Second), "StopHashing should not error with no batches")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	hashes := GetMaxHashes()
+	done := make(chan EPOCH_Result, 2)
+	for b := 0; b < 2; b++ {
+		go func() {
+			res, _ := AttemptHashes(hashes)
+			done <- res
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	start := time.Now()
+	err = StopHashing(time.Second * 10)
+	assert.NoError(t, err, "StopHashing should not error: %s", err)
+	assert.Less(t, time.Since(start), time.Second*2, "StopHashing should return promptly")
+
+	for b := 0; b < 2; b++ {
+		select {
+		case res := <-done:
+			assert.NoError(t, res.Error, "Stopped batch should not error: %s", res.Error)
+			assert.Less(t, res.Hashes, uint64(hashes), "Stopped batch should have partial results")
+		default:
+			t.Fatal("Batches should have returned when StopHashing returns")
+		}
+	}
+
+	assert.True(t, IsActive(), "StopHashing should not disconnect")
+
+	// Batches started after StopHashing are not affected
+	res, err := AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Equal(t, uint64(5), res.Hashes, "Batch after StopHashing should run all hashes")
+
+	StopGetWork()
+}
+
+// Test Health reports each status with its reasons from crafted conditions
+func TestHealth(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetMaxJobAge(DEFAULT_MAX_JOB_AGE) })
+
+	_, ok := GetHandler()["HealthEPOCH"]
+	assert.True(t, ok, "HealthEPOCH should be in the handler")
+
+	// Not connected
+	health, err := HealthEPOCH(context.Background())
+	assert.NoError(t, err, "HealthEPOCH should not error when not active: %s", err)
+	assert.Equal(t, HEALTH_UNHEALTHY, health.Status, "Health should be unhealthy when not connected")
+	assert.NotEmpty(t, health.Reasons, "Unhealthy should have a reason")
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID != "" && !JobStatus().Stale }, time.Second*5, time.Millisecond*10, "Job should be received")
+
+	health = Health()
+	assert.Equal(t, HEALTH_HEALTHY, health.Status, "Health should be healthy: %v", health.Reasons)
+	assert.Empty(t, health.Reasons, "Healthy should have no reasons")
+
+	// Stale job
+	SetMaxJobAge(time.Second)
+	epoch.jobs.Lock()
+	snapshot := *epoch.jobs.load()
+	snapshot.received = time.Now().Add(-time.Second * 2)
+	epoch.jobs.store(snapshot)
+	epoch.jobs.Unlock()
+
+	health = Health()
+	assert.Equal(t, HEALTH_DEGRADED, health.Status, "Health should be degraded with a stale job")
+	if assert.Len(t, health.Reasons, 1, "Degraded should have one reason") {
+		assert.Contains(t, health.Reasons[0], "stale", "Reason should be equal")
+	}
+	SetMaxJobAge(DEFAULT_MAX_JOB_AGE)
+
+	// High orphan rate
+	epoch.Lock()
+	epoch.orphans.add(5, ORPHAN_ALERT_MIN)
+	epoch.Unlock()
+
+	health = Health()
+	assert.Equal(t, HEALTH_DEGRADED, health.Status, "Health should be degraded with a high orphan rate")
+	if assert.Len(t, health.Reasons, 1, "Degraded should have one reason") {
+		assert.Contains(t, health.Reasons[0], "orphan rate", "Reason should be equal")
+	}
+
+	epoch.Lock()
+	epoch.orphans.reset()
+	epoch.Unlock()
+
+	// Frequent reconnects
+	for r := 0; r < HEALTH_RECONNECTS; r++ {
+		epoch.endpointReconnected(s.Endpoint())
+	}
+
+	health = Health()
+	assert.Equal(t, HEALTH_DEGRADED, health.Status, "Health should be degraded when reconnecting frequently")
+	if assert.Len(t, health.Reasons, 1, "Degraded should have one reason") {
+		assert.Contains(t, health.Reasons[0], "reconnected", "Reason should be equal")
+	}
+
+	// Reconnecting
+	SetReconnectPolicy(RECONNECT_ALWAYS)
+	t.Cleanup(func() { SetReconnectPolicy(RECONNECT_NEVER) })
+	s.DropConnections(true)
+	s.CloseConnections()
+	assert.Eventually(t, func() bool { return Health().Status == HEALTH_UNHEALTHY }, time.Second*5, time.Millisecond*10, "Health should be unhealthy while reconnecting")
+	assert.Equal(t, []string{"reconnecting"}, Health().Reasons, "Reason should be equal")
+
+	StopGetWork()
+	s.DropConnections(false)
+}
+
+// Test AttemptHashes hashes on a fixed job instead of the stream until it is cleared
+func TestFixedJob(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(ClearFixedJob)
+
+	fixed := testJob
+	fixed.JobID = "1722895096807.99.fixed"
+	fixed.Height = 99
+	fixed.Blockhashing_blob = "41dc" + strings.Repeat("00", block.MINIBLOCK_SIZE-2)
+
+	invalid := fixed
+	invalid.Blockhashing_blob = "invalid"
+	assert.Error(t, SetFixedJob(invalid), "SetFixedJob should error with an invalid blob")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	err = SetFixedJob(fixed)
+	assert.NoError(t, err, "SetFixedJob should not error: %s", err)
+	job, ok := GetFixedJob()
+	assert.True(t, ok, "Fixed job should be set")
+	assert.Equal(t, fixed, job, "Fixed job should be equal")
+
+	// Jobs from the stream are tracked but not hashed on
+	streamed := testJob
+	streamed.JobID = "1722895096807.1.notified"
+	s.SendJob(streamed)
+	assert.Eventually(t, func() bool { return epoch.getJob().JobID == streamed.JobID }, time.Second*5, time.Millisecond*10, "Streamed job should be tracked")
+
+	// Valid hashes of the fixed job are a dry run
+	logs := captureOutput(func() {
+		res, err := AttemptHashes(2)
+		assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+		assert.Equal(t, uint64(2), res.Hashes, "Hashes should be equal")
+		assert.Zero(t, res.Submitted, "Fixed job hashes should not be submitted")
+	})
+	assert.Contains(t, logs, "Valid miniblock POW hash of fixed job "+fixed.JobID, "Fixed job hashes should be logged")
+	assert.False(t, s.WaitSubmissions(1, time.Millisecond*200), "Test server should not receive fixed job submissions")
+
+	ClearFixedJob()
+	_, ok = GetFixedJob()
+	assert.False(t, ok, "Fixed job should be cleared")
+
+	res, _ := AttemptHashes(1)
+	assert.Equal(t, 1, res.Submitted, "Submitted should be equal")
+	assert.True(t, s.WaitSubmissions(1, time.Second*5), "Test server should receive the submission")
+	assert.Equal(t, streamed.JobID, s.Submissions()[0].JobID, "Submission should use the streamed job once cleared")
+
+	StopGetWork()
+}
+
+// Test confirm mode submissions are confirmed by the node's reported counts or become Unknown after the confirm timeout
+func TestConfirmTimeout(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { SetConfirmTimeout(0) })
+
+	assert.Zero(t, GetConfirmTimeout(), "Confirm mode should be disabled by default")
+	assert.Error(t, SetConfirmTimeout(-time.Second), "SetConfirmTimeout should error below 0")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Test server never reports the submission
+	timeout := time.Millisecond * 200
+	err = SetConfirmTimeout(timeout)
+	assert.NoError(t, err, "SetConfirmTimeout should not error: %s", err)
+
+	start := time.Now()
+	res, err := AttemptHashes(1)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.Equal(t, 1, res.Submitted, "Submitted should be equal")
+	assert.GreaterOrEqual(t, time.Since(start), timeout, "Submission should wait for the confirm timeout")
+
+	session, _ := GetSession(0)
+	assert.Equal(t, uint64(1), session.Unknown, "Unconfirmed submission should be Unknown")
+
+	// Confirmed by the next job's accepted and rejected counts
+	SetConfirmTimeout(time.Second * 10)
+	for _, report := range []func(*rpc.GetBlockTemplate_Result){
+		func(job *rpc.GetBlockTemplate_Result) { job.MiniBlocks++ },
+		func(job *rpc.GetBlockTemplate_Result) { job.Rejected++ },
+	} {
+		job := epoch.getJob()
+		report(&job)
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			s.SendJob(job)
+		}()
+
+		start = time.Now()
+		res, _ = AttemptHashes(1)
+		assert.Equal(t, 1, res.Submitted, "Submitted should be equal")
+		assert.Less(t, time.Since(start), time.Second*5, "Confirmed submission should not wait for the timeout")
+	}
+
+	session, _ = GetSession(0)
+	assert.Equal(t, uint64(1), session.Unknown, "Confirmed submissions should not be Unknown")
+	epoch.RLock()
+	assert.Empty(t, epoch.confirming, "No submissions should be waiting")
+	epoch.RUnlock()
+
+	StopGetWork()
+}
+
+// Test SetPort warns on well-known ports that are not a GetWork port, or errors when strict
+func TestSetPortWarning(t *testing.T) {
+	port := GetPort()
+	t.Cleanup(func() {
+		SetStrictPort(false)
+		p, _ := strconv.Atoi(port)
+		SetPort(p)
+	})
+
+	output := captureOutput(func() {
+		err := SetPort(DEFAULT_WORK_PORT)
+		assert.NoError(t, err, "SetPort should not error: %s", err)
+	})
+	assert.NotContains(t, output, "GetWork port is usually", "SetPort should not warn on the GetWork port")
+
+	// Well-known port should warn but still be set
+	output = captureOutput(func() {
+		err := SetPort(443)
+		assert.NoError(t, err, "SetPort should not error when not strict: %s", err)
+	})
+	assert.Contains(t, output, "port 443 is the HTTPS port", "SetPort should warn on port 443")
+	assert.Equal(t, "443", GetPort(), "SetPort should set the port when not strict")
+
+	output = captureOutput(func() { SetPort(10102) })
+	assert.Contains(t, output, "mainnet daemon RPC", "SetPort should warn on the daemon RPC port")
+
+	// Strict should error and not set
+	SetPort(DEFAULT_WORK_PORT)
+	SetStrictPort(true)
+	assert.True(t, GetStrictPort(), "Strict port should be set")
+	err := SetPort(443)
+	assert.Error(t, err, "SetPort should error on port 443 when strict")
+	assert.Equal(t, strconv.Itoa(DEFAULT_WORK_PORT), GetPort(), "SetPort should not set the port when strict")
+}
+
+// Test MethodSchemas has a schema for each registered method with the expected top-level properties
+func TestMethodSchemas(t *testing.T) {
+	schemas := MethodSchemas()
+	handler := GetHandler()
+	assert.Len(t, schemas, len(handler), "Each method should have a schema")
+	for n := range handler {
+		schema, ok := schemas[n]
+		if assert.True(t, ok, "Method %s should have a schema", n) {
+			assert.Equal(t, JSON_SCHEMA, schema.Result["$schema"], "Method %s result should have the schema dialect", n)
+			assert.Equal(t, "object", schema.Result["type"], "Method %s result should be an object", n)
+		}
+	}
+
+	properties := func(s Schema) Schema {
+		p, _ := s["properties"].(Schema)
+		return p
+	}
+
+	tests := []struct {
+		method string
+		result []string
+		params []string
+	}{
+		{"AttemptEPOCH", []string{"epochHashes", "epochSubmitted", "epochDuration", "epochHashPerSecond", "epochError", "epochBatch"}, []string{"hashes"}},
+		{"AttemptAndStatsEPOCH", []string{"epochResult", "epochSession"}, []string{"hashes"}},
+		{"GetMaxHashesEPOCH", []string{"maxHashes"}, nil},
+		{"GetAddressEPOCH", []string{"epochAddress"}, nil},
+		{"GetSessionEPOCH", []string{"sessionHashes", "sessionMinis", "sessionStarted", "sessionVersion"}, nil},
+		{"DashboardEPOCH", []string{"connection", "session", "hashrate", "job", "healthy"}, nil},
+		{"HealthEPOCH", []string{"status", "reasons"}, nil},
+	}
+
+	for _, tt := range tests {
+		schema := schemas[tt.method]
+		for _, p := range tt.result {
+			assert.Contains(t, properties(schema.Result), p, "Method %s result should have property %s", tt.method, p)
+		}
+
+		if tt.params == nil {
+			assert.Nil(t, schema.Params, "Method %s should not have params", tt.method)
+			continue
+		}
+
+		for _, p := range tt.params {
+			assert.Contains(t, properties(schema.Params), p, "Method %s params should have property %s", tt.method, p)
+		}
+	}
+
+	// Submit params are arrays of submissions
+	submit := schemas["SubmitEPOCH"].Params
+	assert.Equal(t, "array", submit["type"], "SubmitEPOCH params should be an array")
+	items, _ := submit["items"].(Schema)
+	for _, p := range []string{"jobTemplate", "powHash", "epochWork", "epochDifficulty", "epochSignature"} {
+		assert.Contains(t, properties(items), p, "SubmitEPOCH params should have property %s", p)
+	}
+	assert.Equal(t, []string{"jobTemplate", "powHash", "epochWork", "epochDifficulty"}, items["required"], "Omitempty params should not be required")
+	assert.Equal(t, Schema{"type": "integer"}, properties(items)["epochDifficulty"], "Difficulty should be an integer")
+	assert.Equal(t, block.MINIBLOCK_SIZE, properties(items)["epochWork"].(Schema)["maxItems"], "Work should be a MINIBLOCK_SIZE array")
+
+	ref := schemas["SubmitRefEPOCH"].Params
+	items, _ = ref["items"].(Schema)
+	for _, p := range []string{"jobid", "epochWork", "epochDifficulty", "epochSignature"} {
+		assert.Contains(t, properties(items), p, "SubmitRefEPOCH params should have property %s", p)
+	}
+
+	// Schemas should be valid JSON
+	_, err := json.Marshal(schemas)
+	assert.NoError(t, err, "Schemas should marshal: %s", err)
+}
+
+// Test lowering maxHashes while a batch is running does not affect the batch and it reports its original limit
+func TestMaxHashesSnapshot(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	max := GetMaxHashes()
+	t.Cleanup(func() { SetMaxHashes(max) })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	hashes := 40
+	err = SetMaxHashes(hashes)
+	assert.NoError(t, err, "SetMaxHashes should not error: %s", err)
+
+	done := make(chan EPOCH_Result)
+	go func() {
+		res, err := AttemptHashes(hashes)
+		assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+		done <- res
+	}()
+
+	assert.Eventually(t, IsProcessing, time.Second*5, time.Millisecond, "Batch should be processing")
+	err = SetMaxHashes(1)
+	assert.NoError(t, err, "SetMaxHashes should not error: %s", err)
+
+	res := <-done
+	assert.NoError(t, res.Error, "Batch should not error")
+	assert.Equal(t, uint64(hashes), res.Hashes, "Batch should complete all of its hashes")
+	assert.Equal(t, hashes, res.MaxHashes, "Batch should report the maxHashes it started with")
+
+	res, err = AttemptHashes(hashes)
+	assert.Error(t, err, "AttemptHashes should error when exceeding the lowered maxHashes")
+	assert.Equal(t, 1, res.MaxHashes, "Refused attempt should report the maxHashes it was checked against")
+}
+
+// Test concurrent workers count every submission and return the first error, run with -race to check the workers
+func TestAttemptHashesWorkers(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() { ClearFixedJob() })
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Every hash is a valid miniblock at difficulty 1
+	batches, hashes := 10, 100
+	results := make(chan EPOCH_Result, batches)
+	var wg sync.WaitGroup
+	for b := 0; b < batches; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := AttemptHashes(hashes)
+			assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+			results <- res
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	submitted := 0
+	for res := range results {
+		assert.NoError(t, res.Error, "Batch should not error")
+		assert.Equal(t, uint64(hashes), res.Hashes, "Batch should perform all of its hashes")
+		assert.Equal(t, hashes, res.Submitted, "Batch should count each of its submissions")
+		submitted += res.Submitted
+	}
+
+	assert.True(t, s.WaitSubmissions(submitted, time.Second*10), "Test server should receive all submissions")
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, submitted, session.MiniBlocks, "Session should count all submissions")
+
+	// Every worker errors on a job with an unknown version, the first error is returned and the batch stops dispatching
+	job := testJob
+	job.Blockhashing_blob = "42" + job.Blockhashing_blob[2:]
+	err = SetFixedJob(job)
+	assert.NoError(t, err, "SetFixedJob should not error: %s", err)
+
+	res, err := AttemptHashes(hashes)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.ErrorContains(t, res.Error, "unknown version", "Batch should return the worker error")
+	assert.Less(t, res.Hashes, uint64(hashes), "Batch should stop dispatching after a worker errors")
+	assert.Zero(t, res.Submitted, "Errored hashes should not be counted as submitted")
+}
+
+// Test OnBackpressure is called when submissions queue behind a stalled writer and OnBackpressureRelieved once they drain
+func TestBackpressure(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		OnBackpressure(nil)
+		OnBackpressureRelieved(nil)
+		SetBackpressureMarks(DEFAULT_BACKPRESSURE_HIGH, DEFAULT_BACKPRESSURE_LOW)
+	})
+
+	high, low := GetBackpressureMarks()
+	assert.Equal(t, DEFAULT_BACKPRESSURE_HIGH, high, "Default high mark should be equal")
+	assert.Equal(t, DEFAULT_BACKPRESSURE_LOW, low, "Default low mark should be equal")
+	assert.Error(t, SetBackpressureMarks(2, 2), "High mark equal to low mark should error")
+	assert.Error(t, SetBackpressureMarks(2, -1), "Negative low mark should error")
+
+	// Each worker of a batch waits for a submit slot
+	threads := GetMaxThreads()
+	err := SetBackpressureMarks(threads, 0)
+	assert.NoError(t, err, "SetBackpressureMarks should not error: %s", err)
+
+	pressure := make(chan int, 1)
+	relieved := make(chan int, 1)
+	// Only the first of each is checked, the queue can saturate again while the released workers wait for slots
+	send := func(ch chan int) func(int) {
+		return func(depth int) {
+			select {
+			case ch <- depth:
+			default:
+			}
+		}
+	}
+	OnBackpressure(send(pressure))
+	OnBackpressureRelieved(send(relieved))
+
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Stall the writer by holding every submit slot
+	submitting := epoch.getSubmitSemaphore()
+	for i := 0; i < cap(submitting); i++ {
+		submitting <- struct{}{}
+	}
+
+	hashes := 10
+	done := make(chan EPOCH_Result)
+	go func() {
+		res, _ := AttemptHashes(hashes)
+		done <- res
+	}()
+
+	select {
+	case depth := <-pressure:
+		assert.Equal(t, threads, depth, "Backpressure should be called with the queue depth at the high mark")
+	case <-time.After(time.Second * 10):
+		t.Fatalf("Backpressure should be called when the writer is stalled")
+	}
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, threads, session.SubmitQueue, "Session should have the queue depth")
+
+	for i := 0; i < cap(submitting); i++ {
+		<-submitting
+	}
+
+	select {
+	case depth := <-relieved:
+		assert.Zero(t, depth, "Backpressure relieved should be called with the queue depth at the low mark")
+	case <-time.After(time.Second * 10):
+		t.Fatalf("Backpressure relieved should be called when the queue drains")
+	}
+
+	res := <-done
+	assert.NoError(t, res.Error, "Batch should not error once the writer resumes")
+	assert.Equal(t, hashes, res.Submitted, "Queued submissions should be submitted once the writer resumes")
+}
+
+// Test hundreds of concurrent submissions are all counted, run with -race to check the submission counters
+func TestSubmitHashesConcurrent(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	job, powhash, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	before, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+
+	// Any hash is a valid miniblock at difficulty 1, the work is made unique so it is not deduped
+	params := make([]Submit_Params, 300)
+	for i := range params {
+		params[i] = Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+		params[i].EpochWork[NONCE_FLAG_BYTE-1] = byte(i)
+		params[i].EpochWork[NONCE_FLAG_BYTE-2] = byte(i >> 8)
+	}
+
+	result, err := SubmitHashes(params)
+	assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+	assert.NoError(t, result.Error, "SubmitHashes result should not error")
+	assert.Equal(t, uint64(len(params)), result.Hashes, "Result hashes should be the number of params")
+	assert.Equal(t, len(params), result.Submitted, "Result should count each submission")
+	assert.True(t, s.WaitSubmissions(len(params), time.Second*10), "Test server should receive all submissions")
+
+	after, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, before.MiniBlocks+len(params), after.MiniBlocks, "Session should count each submission")
+}
+
+// Test synchronous mode produces the same results as concurrent mode for the same job, submitting in hash order
+func TestSynchronous(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetSynchronous(false)
+		OnBlockFound(nil)
+	})
+
+	assert.False(t, GetSynchronous(), "Synchronous should be disabled by default")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	var found []string
+	var mu sync.Mutex
+	OnBlockFound(func(event BlockFoundEvent) {
+		mu.Lock()
+		found = append(found, fmt.Sprintf("%x", event.Block.EpochWork[:]))
+		mu.Unlock()
+	})
+
+	// Every hash is a valid miniblock at difficulty 1
+	hashes := 10
+	attempt := func() (EPOCH_Result, int) {
+		before, _ := GetSession(time.Second)
+		res, err := AttemptHashes(hashes)
+		assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+		after, _ := GetSession(time.Second)
+
+		return res, after.MiniBlocks - before.MiniBlocks
+	}
+
+	concurrent, concurrentMinis := attempt()
+	assert.True(t, s.WaitSubmissions(hashes, time.Second*5), "Test server should receive the concurrent submissions")
+
+	SetSynchronous(true)
+	assert.True(t, GetSynchronous(), "Synchronous should be enabled")
+
+	mu.Lock()
+	found = nil
+	mu.Unlock()
+
+	synchronous, synchronousMinis := attempt()
+	assert.True(t, s.WaitSubmissions(hashes*2, time.Second*5), "Test server should receive the synchronous submissions")
+
+	assert.NoError(t, synchronous.Error, "Synchronous batch should not error")
+	assert.Equal(t, concurrent.Hashes, synchronous.Hashes, "Synchronous hashes should equal concurrent hashes")
+	assert.Equal(t, concurrent.Submitted, synchronous.Submitted, "Synchronous submissions should equal concurrent submissions")
+	assert.Equal(t, concurrentMinis, synchronousMinis, "Synchronous session miniblocks should equal concurrent session miniblocks")
+
+	// Each hash is submitted before the next is hashed
+	var submitted []string
+	for _, p := range s.Submissions()[hashes:] {
+		submitted = append(submitted, p.MiniBlockhashing_blob)
+	}
+	mu.Lock()
+	assert.Equal(t, found, submitted, "Synchronous submissions should be received in hash order")
+	mu.Unlock()
+}
+
+// Test concurrent attempts of known sizes add exactly their hashes and miniblocks to the session, run with -race
+func TestConcurrentSessionTotals(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// Every hash is a valid miniblock at difficulty 1
+	sizes := []int{1, 2, 3, 5, 8, 13, 21, 34}
+	var wg sync.WaitGroup
+	var submitted atomic.Int64
+	total := 0
+	for _, size := range sizes {
+		total += size
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			res, err := AttemptEPOCH(context.Background(), Attempt_Params{Hashes: size})
+			assert.NoError(t, err, "AttemptEPOCH should not error: %s", err)
+			submitted.Add(int64(res.Submitted))
+		}(size)
+	}
+	wg.Wait()
+
+	session, err := GetSessionEPOCH(context.Background())
+	assert.NoError(t, err, "GetSessionEPOCH should not error: %s", err)
+	assert.Equal(t, uint64(total), session.Hashes, "Session hashes should be the exact sum of all attempts")
+	assert.Equal(t, int64(total), submitted.Load(), "Every hash should be submitted")
+	assert.Equal(t, total, session.MiniBlocks, "Session miniblocks should be the exact sum of all submissions")
+}
+
+// Test StartGetWork returns ErrNoAddress when no address is passed or set
+func TestNoAddress(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	address := GetAddress()
+	t.Cleanup(func() {
+		epoch.Lock()
+		epoch.address = address
+		epoch.Unlock()
+	})
+
+	epoch.Lock()
+	epoch.address = ""
+	epoch.Unlock()
+
+	err := StartGetWork("", s.Endpoint())
+	assert.ErrorIs(t, err, ErrNoAddress, "StartGetWork should return ErrNoAddress when no address is set")
+	assert.False(t, IsActive(), "EPOCH should not be active without an address")
+
+	// A passed address is used
+	err = StartGetWork(testAddress, s.Endpoint())
+	assert.NoError(t, err, "StartGetWork should not error with an address: %s", err)
+}
+
+// Test AttemptHashesContext and AttemptEPOCH stop hashing when ctx is done and return the hashes performed
+func TestAttemptHashesContext(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	// A done ctx performs no hashes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := AttemptEPOCH(ctx, Attempt_Params{Hashes: 10})
+	assert.ErrorIs(t, err, context.Canceled, "AttemptEPOCH should return the ctx error")
+	assert.Zero(t, res.Hashes, "AttemptEPOCH should not hash with a done ctx")
+
+	before, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+
+	hashes := GetMaxHashes()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	res, err = AttemptHashesContext(ctx, hashes)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "AttemptHashesContext should return the ctx error")
+	assert.Less(t, time.Since(start), time.Second*5, "AttemptHashesContext should return soon after ctx is done")
+	assert.Positive(t, res.Hashes, "Hashes before ctx was done should be performed")
+	assert.Less(t, res.Hashes, uint64(hashes), "Hashing should stop when ctx is done")
+	assert.NoError(t, res.Error, "Result should not have a worker error")
+
+	after, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, before.Hashes+res.Hashes, after.Hashes, "Session should include the hashes performed")
+
+	// A ctx that is not done performs all hashes
+	res, err = AttemptHashesContext(context.Background(), 5)
+	assert.NoError(t, err, "AttemptHashesContext should not error: %s", err)
+	assert.Equal(t, uint64(5), res.Hashes, "All hashes should be performed")
+}
+
+// Test StartStatsEmitter pushes populated snapshots at the interval and stops with the connection
+func TestStatsEmitter(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	interval := MIN_STATS_INTERVAL
+	var mu sync.Mutex
+	var snapshots []Dashboard_Result
+	var times []time.Time
+	emit := func(d Dashboard_Result) {
+		mu.Lock()
+		snapshots = append(snapshots, d)
+		times = append(times, time.Now())
+		mu.Unlock()
+	}
+	emitted := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(snapshots)
+	}
+
+	assert.ErrorIs(t, StartStatsEmitter(interval, emit), ErrNotActive, "StartStatsEmitter should error when not active")
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	assert.Error(t, StartStatsEmitter(interval-1, emit), "StartStatsEmitter should error below MIN_STATS_INTERVAL")
+	assert.Error(t, StartStatsEmitter(interval, nil), "StartStatsEmitter should error without a callback")
+
+	started := time.Now()
+	err = StartStatsEmitter(interval, emit)
+	assert.NoError(t, err, "StartStatsEmitter should not error: %s", err)
+
+	_, err = AttemptHashes(5)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+
+	assert.Eventually(t, func() bool { return emitted() >= 5 }, time.Second*5, time.Millisecond*10, "Emitter should fire each interval")
+	StopGetWork()
+	time.Sleep(interval) // a snapshot taken before the stop may still be delivered
+
+	mu.Lock()
+	n := len(snapshots)
+	elapsed := times[n-1].Sub(started)
+	assert.InDelta(t, float64(interval*time.Duration(n)), float64(elapsed), float64(interval*time.Duration(n)/2), "Emitter should fire roughly at the interval")
+	last := snapshots[n-1]
+	mu.Unlock()
+
+	assert.True(t, last.Connection.Active, "Snapshot should have the connection")
+	assert.Equal(t, testAddress, last.Connection.Address, "Snapshot should have the address")
+	assert.Positive(t, last.Session.Threads, "Snapshot should have the session")
+	assert.Equal(t, testJob.JobID, last.Job.JobID, "Snapshot should have the job")
+
+	time.Sleep(interval * 3)
+	assert.Equal(t, n, emitted(), "Emitter should not fire after the connection has stopped")
+}
+
+// Test SetReconnect gives up after its maximum attempts and a StopGetWork does not reconnect
+func TestReconnectAttempts(t *testing.T) {
+	s := NewTestServer(t, testJob)
+	t.Cleanup(func() {
+		SetReconnect(false, 0)
+		SetReconnectBackoff(RECONNECT_DELAY, RECONNECT_MAX_DELAY)
+	})
+
+	enabled, attempts := GetReconnect()
+	assert.False(t, enabled, "Reconnect should be disabled by default")
+	assert.Zero(t, attempts, "Reconnect attempts should be unlimited by default")
+	base, max := GetReconnectBackoff()
+	assert.Equal(t, RECONNECT_DELAY, base, "Default reconnect delay should be equal")
+	assert.Equal(t, RECONNECT_MAX_DELAY, max, "Default max reconnect delay should be equal")
+
+	assert.Error(t, SetReconnect(true, -1), "Negative reconnect attempts should error")
+	assert.Error(t, SetReconnectBackoff(0, time.Second), "Zero reconnect delay should error")
+	assert.Error(t, SetReconnectBackoff(time.Second, time.Millisecond), "Reconnect delay above max should error")
+
+	err := SetReconnect(true, 2)
+	assert.NoError(t, err, "SetReconnect should not error: %s", err)
+	assert.Equal(t, RECONNECT_ALWAYS, GetReconnectPolicy(), "Enabling reconnect should reconnect on any error")
+	err = SetReconnectBackoff(time.Millisecond*10, time.Millisecond*20)
+	assert.NoError(t, err, "SetReconnectBackoff should not error: %s", err)
+
+	// A StopGetWork does not reconnect
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+	StopGetWork()
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, s.Addresses(), 1, "StopGetWork should not reconnect")
+	assert.False(t, epoch.isRunning(), "EPOCH should be stopped")
+
+	// Every redial fails once the server is closed
+	err = StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	s.CloseConnections()
+	s.Close()
+	assert.Eventually(t, func() bool { return !IsActive() }, time.Second*5, time.Millisecond, "EPOCH should not be active while reconnecting")
+	_, err = AttemptHashes(1)
+	assert.ErrorIs(t, err, ErrNotActive, "AttemptHashes should error while reconnecting")
+	assert.Eventually(t, func() bool { return !epoch.isRunning() }, time.Second*5, time.Millisecond*10, "EPOCH should stop after the reconnect attempts are exhausted")
+
+	err = SetReconnect(false, 0)
+	assert.NoError(t, err, "SetReconnect should not error: %s", err)
+	assert.Equal(t, RECONNECT_NEVER, GetReconnectPolicy(), "Disabling reconnect should never reconnect")
+}
+
+// Test identical submissions from concurrent batches are only submitted once and counted as Deduped
+func TestSubmitDedupe(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	job, powhash, work, diff, err := epoch.powHash(nil)
+	if err != nil {
+		t.Fatalf("powHash should not error: %s", err)
+	}
+
+	batches, duplicates := 4, 5
+	params := make([]Submit_Params, duplicates)
+	for i := range params {
+		params[i] = Submit_Params{Job: job, PowHash: powhash, EpochWork: work, Difficulty: diff}
+	}
+
+	var wg sync.WaitGroup
+	var submitted atomic.Int64
+	for b := 0; b < batches; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := SubmitHashes(params)
+			assert.NoError(t, err, "SubmitHashes should not error: %s", err)
+			assert.NoError(t, res.Error, "Duplicates should not be an error")
+			submitted.Add(int64(res.Submitted))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), submitted.Load(), "Identical work should only be submitted once")
+	s.WaitSubmissions(1, time.Second*5)
+	time.Sleep(time.Millisecond * 50)
+	assert.Len(t, s.Submissions(), 1, "Test server should only receive the work once")
+
+	session, err := GetSession(time.Second)
+	assert.NoError(t, err, "GetSession should not error: %s", err)
+	assert.Equal(t, uint64(batches*duplicates-1), session.Deduped, "Session should count each duplicate")
+	assert.Equal(t, 1, session.MiniBlocks, "Session should only count the submitted work")
+
+	// Work that was not submitted can be submitted again
+	var recent recentSubmits
+	now := time.Now()
+	assert.True(t, recent.add(work, now), "New work should be added")
+	assert.False(t, recent.add(work, now), "Recent work should not be added again")
+	recent.remove(work)
+	assert.True(t, recent.add(work, now), "Removed work should be added again")
+	assert.True(t, recent.add(work, now.Add(RECENT_SUBMIT_WINDOW)), "Expired work should be added again")
+}
+
+// Test StopGetWork and Close exit the read loop without logging an error
+func TestStopGetWorkNoError(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	for _, stop := range []func(){StopGetWork, func() { Close() }} {
+		output := captureOutput(func() {
+			err := StartGetWork(testAddress, s.Endpoint())
+			if err != nil {
+				t.Fatalf("StartGetWork should not error: %s", err)
+			}
+
+			err = JobIsReady(time.Second * 5)
+			assert.NoError(t, err, "Finding job should not error: %s", err)
+
+			stop()
+			time.Sleep(time.Millisecond * 100) // the read loop logs when it exits
+		})
+
+		assert.Contains(t, output, "Closed", "Read loop should exit when stopped")
+		assert.NotContains(t, output, "ERROR", "Stopping should not log an error")
+	}
+}
+
+// Test repeated identical job errors are logged once and then summarized
+func TestJobErrorThrottle(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	jobs := 10
+	output := captureOutput(func() {
+		for i := 1; i <= jobs; i++ {
+			job := testJob
+			job.JobID = strconv.Itoa(i)
+			job.LastError = "upstream failure"
+			s.SendJob(job)
+		}
+		assert.Eventually(t, func() bool { return epoch.getJob().JobID == strconv.Itoa(jobs) }, time.Second*5, time.Millisecond*10, "Jobs should be received")
+
+		// A different error is logged straight away
+		job := testJob
+		job.JobID = "other"
+		job.LastError = "other failure"
+		s.SendJob(job)
+		assert.Eventually(t, func() bool { return epoch.getJob().JobID == job.JobID }, time.Second*5, time.Millisecond*10, "Job should be received")
+	})
+
+	assert.Equal(t, 1, strings.Count(output, "Job error: upstream failure"), "Repeated job error should only be logged once")
+	assert.Equal(t, 1, strings.Count(output, "Job error: other failure"), "A different job error should be logged")
+
+	// Repeats are summarized each JOB_ERROR_SUMMARY
+	var jobErr jobErrors
+	now := time.Now()
+	output = captureOutput(func() {
+		jobErr.log("upstream failure", now)
+		for i := 1; i <= 5; i++ {
+			jobErr.log("upstream failure", now.Add(time.Second*time.Duration(i)))
+		}
+		jobErr.log("upstream failure", now.Add(JOB_ERROR_SUMMARY))
+		jobErr.log("upstream failure", now.Add(JOB_ERROR_SUMMARY+time.Second))
+	})
+	assert.Equal(t, 1, strings.Count(output, "Job error: upstream failure"), "Job error should be logged once")
+	assert.Equal(t, 1, strings.Count(output, "still occurring, repeated 6 times in 1m0s"), "Repeats should be summarized once per JOB_ERROR_SUMMARY")
+
+	// A job without an error ends the repeat
+	output = captureOutput(func() {
+		jobErr.log("", now)
+		jobErr.log("upstream failure", now)
+	})
+	assert.Contains(t, output, "Job error: upstream failure", "Job error should be logged again after a job without an error")
+}
+
+// Test GetSession returns consistent totals while concurrent batches are running
+func TestConcurrentGetSession(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	batch := 7
+	batches := 6
+	var wg sync.WaitGroup
+	for i := 0; i < batches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := AttemptHashes(batch)
+			assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var last uint64
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+
+		// Each batch adds its hashes at once, so a consistent total is always a multiple of batch
+		session, err := GetSession(0)
+		assert.NoError(t, err, "GetSession should not error: %s", err)
+		assert.Zero(t, session.Hashes%uint64(batch), "Session hashes %d should be a multiple of %d", session.Hashes, batch)
+		assert.GreaterOrEqual(t, session.Hashes, last, "Session hashes should not decrease")
+		last = session.Hashes
+	}
+
+	assert.Equal(t, uint64(batch*batches), last, "Session hashes should include all batches")
+}
+
+// Test each setter fires one OnConfigChange event
+func TestOnConfigChange(t *testing.T) {
+	globals.Arguments["--testnet"] = true
+	globals.Arguments["--simulator"] = true
+	globals.InitNetwork()
+
+	address := GetAddress()
+	port, _ := strconv.Atoi(GetPort())
+	maxHashes := GetMaxHashes()
+	maxThreads := GetMaxThreads()
+
+	var changes []ConfigChange
+	OnConfigChange(func(c ConfigChange) { changes = append(changes, c) })
+	t.Cleanup(func() {
+		OnConfigChange(nil)
+		epoch.address = address
+		SetPort(port)
+		SetMaxHashes(maxHashes)
+		SetMaxThreads(maxThreads)
+	})
+
+	tests := []struct {
+		set    func() error
+		expect ConfigChange
+	}{
+		{func() error { return SetAddress(testAddress) }, ConfigChange{"address", testAddress}},
+		{func() error { return SetPort(20100) }, ConfigChange{"port", 20100}},
+		{func() error { return SetMaxHashes(500) }, ConfigChange{"maxHashes", 500}},
+		{func() error { SetMaxThreads(1); return nil }, ConfigChange{"maxThreads", 1}},
+	}
+
+	for _, tt := range tests {
+		changes = nil
+		err := tt.set()
+		assert.NoError(t, err, "Setting %s should not error: %s", tt.expect.Field, err)
+		assert.Equal(t, []ConfigChange{tt.expect}, changes, "Setting %s should fire one config change", tt.expect.Field)
+	}
+
+	// Invalid values should not fire
+	changes = nil
+	SetAddress("invalid")
+	SetPort(0)
+	SetMaxHashes(LIMIT_MAX_HASHES + 1)
+	assert.Empty(t, changes, "Invalid settings should not fire config changes")
+}
+
+// Test StartAndRun accumulates hashes until cancelled
+func TestStartAndRun(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	res, err := StartAndRun(ctx, testAddress, s.Endpoint(), 5)
+	assert.NoError(t, err, "StartAndRun should not error: %s", err)
+	assert.NotZero(t, res.Hashes, "StartAndRun should accumulate hashes")
+	assert.NotZero(t, res.Submitted, "StartAndRun should accumulate submissions")
+	assert.NotZero(t, res.HashPerSec, "StartAndRun should have a hash rate")
+	assert.False(t, IsActive(), "StartAndRun should stop when done")
+
+	// The running batch ends with ctx
+	maxHashes := GetMaxHashes()
+	t.Cleanup(func() { SetMaxHashes(maxHashes) })
+	SetMaxHashes(LIMIT_MAX_HASHES)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	res, err = StartAndRun(ctx, testAddress, s.Endpoint(), LIMIT_MAX_HASHES)
+	assert.NoError(t, err, "StartAndRun should not error: %s", err)
+	assert.Less(t, res.Hashes, uint64(LIMIT_MAX_HASHES), "StartAndRun should end the running batch when ctx is done")
+	SetMaxHashes(maxHashes)
+
+	// Batches wait instead of spinning while there is no job
+	epoch.RLock()
+	started := epoch.batches
+	epoch.RUnlock()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	attempts := make(chan uint64, 1)
+	go func() {
+		batches := func() uint64 {
+			epoch.RLock()
+			defer epoch.RUnlock()
+
+			return epoch.batches
+		}
+
+		for deadline := time.Now().Add(time.Second); batches() == started && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		// Jobs pushed after submissions of the last batch are without work too
+		s.SendJob(rpc.GetBlockTemplate_Result{JobID: "no work"})
+		time.Sleep(time.Millisecond * 100)
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+
+		before := batches()
+		time.Sleep(time.Millisecond * 500)
+		attempts <- batches() - before
+	}()
+
+	_, err = StartAndRun(ctx, testAddress, s.Endpoint(), 5)
+	assert.NoError(t, err, "StartAndRun should not error: %s", err)
+	assert.LessOrEqual(t, <-attempts, uint64(10), "StartAndRun should wait between batches without a job")
+	s.SendJob(testJob)
+
+	// Connection dropped without a reconnect policy
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	go func() {
+		JobIsReady(time.Second * 5)
+		time.Sleep(time.Millisecond * 500)
+		s.CloseConnections()
+	}()
+
+	_, err = StartAndRun(ctx, testAddress, s.Endpoint(), 5)
+	assert.ErrorIs(t, err, ErrNotActive, "StartAndRun should return ErrNotActive when dropped without reconnect")
+	assert.NoError(t, ctx.Err(), "StartAndRun should return before context is done when dropped")
+
+	// Waiting for the first job ends with ctx
+	noWork := NewTestServer(t, rpc.GetBlockTemplate_Result{})
+	epoch.jobs.Lock()
+	epoch.jobs.store(jobSnapshot{})
+	epoch.jobs.Unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	now := time.Now()
+	_, err = StartAndRun(ctx, testAddress, noWork.Endpoint(), 5)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "StartAndRun should error when ctx is done before a job")
+	assert.Less(t, time.Since(now), time.Second*5, "StartAndRun should not wait for a job after ctx is done")
+}
+
+// Test StartGetWork refuses a zero capacity semaphore
+func TestZeroThreads(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	maxThreads := GetMaxThreads()
+	t.Cleanup(func() { SetMaxThreads(maxThreads) })
+
+	epoch.Lock()
+	epoch.maxThreads = 0 // SetMaxThreads will not allow zero
+	epoch.Unlock()
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	assert.Error(t, err, "StartGetWork should error with zero threads")
+	assert.False(t, IsActive(), "StartGetWork should not connect with zero threads")
+	assert.Empty(t, s.Addresses(), "StartGetWork should not dial with zero threads")
+}
+
+// Test hashing continues on the last job with work through a brief gap
+func TestJobGap(t *testing.T) {
+	t.Cleanup(func() {
+		epoch.jobs.Lock()
+		epoch.jobs.store(jobSnapshot{})
+		epoch.jobs.Unlock()
+		SetMaxJobAge(DEFAULT_MAX_JOB_AGE)
+	})
+
+	assert.Equal(t, DEFAULT_MAX_JOB_AGE, GetMaxJobAge(), "Default max job age should be equal")
+	assert.Error(t, SetMaxJobAge(0), "Zero max job age should error")
+
+	maxAge := time.Millisecond * 200
+	err := SetMaxJobAge(maxAge)
+	assert.NoError(t, err, "SetMaxJobAge should not error: %s", err)
+
+	epoch.newJob(testJob)
+	epoch.newJob(rpc.GetBlockTemplate_Result{JobID: "gap"}) // no work
+
+	// Within max job age last job is used
+	job, _, _, _, err := epoch.powHash(nil)
+	assert.NoError(t, err, "powHash should not error within max job age: %s", err)
+	assert.Equal(t, testJob.JobID, job.JobID, "powHash should use last job with work")
+
+	// Past max job age
+	time.Sleep(maxAge)
+	_, _, _, _, err = epoch.powHash(nil)
+	assert.ErrorIs(t, err, ErrJobNotReady, "powHash should error past max job age")
+
+	// New job with work
+	next := testJob
+	next.JobID = "next"
+	epoch.newJob(next)
+	job, _, _, _, err = epoch.powHash(nil)
+	assert.NoError(t, err, "powHash should not error with new job: %s", err)
+	assert.Equal(t, next.JobID, job.JobID, "powHash should use new job")
+}
+
+// Test SingleThreadBenchmark returns a hash rate
+func TestSingleThreadBenchmark(t *testing.T) {
+	rate := SingleThreadBenchmark(time.Millisecond * 100)
+	assert.Greater(t, rate, float64(0), "SingleThreadBenchmark should return a positive hash rate")
+	t.Logf("Single thread: %.2f H/s", rate)
+
+	// At least one hash is measured
+	rate = SingleThreadBenchmark(0)
+	assert.Greater(t, rate, float64(0), "SingleThreadBenchmark should measure at least one hash")
+}
+
+// Test EPOCH at its full capacity against a mainnet node, test must have RUN_MAINNET_TEST=true argument or this will be skipped
+func TestMainnet(t *testing.T) {
+	if os.Getenv("RUN_MAINNET_TEST") != "true" {
+		t.Skipf("Use %q to run mainnet test", "RUN_MAINNET_TEST=true go test -run TestMainnet -v")
+	}
+
+	endpoint := "89.38.99.117:10102" // DERO mainnet remote default
+	globals.Arguments["--testnet"] = false
+	globals.Arguments["--simulator"] = false
+	globals.Arguments["--daemon-address"] = endpoint
+	globals.InitNetwork()
+	address := "dero1qy0khp9s9yw2h0eu20xmy9lth3zp5cacmx3rwt6k45l568d2mmcf6qgcsevzx" // Artificer
+	t.Cleanup(StopGetWork)
+
+	// Use less then available
+	SetMaxThreads(runtime.NumCPU() - 1)
+
+	err := StartGetWork(address, endpoint)
+	assert.NoError(t, err, "Starting EPOCH should not error: %s", err)
+
+	err = JobIsReady(time.Second * 10) // wait for connection and jobs
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	if !IsActive() {
+		t.Fatalf("Not connected to GetWork")
+	}
+
+	var wg sync.WaitGroup
+	run := 30
+	hashes := 1000
+	start := time.Now()
+
+	for i := 0; i < run; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := AttemptHashes(hashes)
+			if err != nil {
+				t.Errorf("AttemptHashes: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	took := time.Since(start)
+	total := hashes * run
+
+	t.Logf("Took %dms for %d hashes, [%.0fH/s]", took.Milliseconds(), total, float64(total)/took.Seconds())
+}
+
+// Benchmark for AttemptHashes with default settings against a simulator node
+func BenchmarkAttemptHashes(b *testing.B) {
+	endpoint := "127.0.0.1:20000"
+	globals.Arguments["--testnet"] = true
+	globals.Arguments["--simulator"] = true
+	globals.Arguments["--daemon-address"] = endpoint
+	globals.InitNetwork()
+	address := "deto1qyre7td6x9r88y4cavdgpv6k7lvx6j39lfsx420hpvh3ydpcrtxrxqg8v8e3z"
+	b.Cleanup(StopGetWork)
+
+	if err := StartGetWork(address, endpoint); err != nil {
+		b.Fatalf("StartGetWork error: %s", err)
+	}
+
+	err := JobIsReady(time.Second * 10) // wait for connection and jobs
+	if err != nil {
+		b.Fatalf("Finding job should not error: %s", err)
+	}
+
+	if !IsActive() {
+		b.Fatalf("Not connected to GetWork")
+	}
+
+	hashes := 100
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := AttemptHashes(hashes)
+		if err != nil {
+			b.Fatalf("AttemptHashes failed: %s", err)
+		}
+	}
+	b.StopTimer()
+}
+
+// Benchmark reading the current job from many concurrent workers while jobs are published,
+// compared with the RWMutex the jobs were previously read under
+func BenchmarkGetJob(b *testing.B) {
+	epoch.newJob(testJob)
+	b.Cleanup(func() { epoch.newJob(rpc.GetBlockTemplate_Result{}) })
+
+	publish := func(newJob func()) (stop func()) {
+		done := make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					newJob()
+				}
+			}
+		}()
+
+		return func() { close(done) }
+	}
+
+	b.Run("atomic", func(b *testing.B) {
+		defer publish(func() { epoch.newJob(testJob) })()
+
+		b.SetParallelism(64)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if epoch.getJob().Blockhashing_blob == "" {
+					b.Errorf("job has no work")
+					return
+				}
+			}
+		})
+	})
+
+	b.Run("RWMutex", func(b *testing.B) {
+		var mu sync.RWMutex
+		job := testJob
+		defer publish(func() {
+			mu.Lock()
+			job = testJob
+			mu.Unlock()
+		})()
+
+		b.SetParallelism(64)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				mu.RLock()
+				j := job
+				mu.RUnlock()
+				if j.Blockhashing_blob == "" {
+					b.Errorf("job has no work")
+					return
+				}
+			}
+		})
+	})
+}
+
+// Benchmark for continuous RunLoop batches against the in-memory GetWork server, run with -benchmem to see allocations per batch
+func BenchmarkRunLoop(b *testing.B) {
+	job := testJob
+	job.Difficulty = "1000000000000" // no submissions
+	s := NewTestServer(b, job)
+
+	if err := StartGetWork(testAddress, s.Endpoint()); err != nil {
+		b.Fatalf("StartGetWork error: %s", err)
+	}
+
+	err := JobIsReady(time.Second * 5)
+	if err != nil {
+		b.Fatalf("Finding job should not error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := RunLoop(ctx, 10); err != nil {
+		b.Fatalf("RunLoop error: %s", err)
+	}
+
+	results := ResultsChannel()
+	<-results
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, ok := <-results
+		if !ok || res.Error != nil {
+			b.Fatalf("RunLoop batch failed: %v", res.Error)
+		}
+	}
+	b.StopTimer()
+}
+
+// Benchmark for the first AttemptHashes batch hash rate with and without Warmup against a simulator node
+func BenchmarkWarmup(b *testing.B) {
+	endpoint := "127.0.0.1:20000"
+	globals.Arguments["--testnet"] = true
+	globals.Arguments["--simulator"] = true
+	globals.Arguments["--daemon-address"] = endpoint
+	globals.InitNetwork()
+	address := "deto1qyre7td6x9r88y4cavdgpv6k7lvx6j39lfsx420hpvh3ydpcrtxrxqg8v8e3z"
+	b.Cleanup(StopGetWork)
+
+	if err := StartGetWork(address, endpoint); err != nil {
+		b.Fatalf("StartGetWork error: %s", err)
+	}
+
+	err := JobIsReady(time.Second * 10) // wait for connection and jobs
+	if err != nil {
+		b.Fatalf("Finding job should not error: %s", err)
+	}
+
+	hashes := GetMaxThreads() * 2
+
+	for _, warmup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Warmup=%t", warmup), func(b *testing.B) {
+			var hashPerSec float64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				// Clear the hashing buffer pools so each first batch starts cold
+				runtime.GC()
+				runtime.GC()
+				if warmup {
+					Warmup()
+				}
+				b.StartTimer()
+
+				res, err := AttemptHashes(hashes)
+				if err != nil {
+					b.Fatalf("AttemptHashes failed: %s", err)
+				}
+
+				hashPerSec += res.HashPerSec
+			}
+			b.StopTimer()
+			b.ReportMetric(hashPerSec/float64(b.N), "H/s")
+		})
+	}
+}
+
+// Capture stdout written while fn is called, EPOCH logs are written to stdout
+func captureOutput(fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	fn()
+	w.Close()
+
+	return <-out
 }
 
 // Create test wallet for simulator
@@ -0,0 +1,275 @@
+package epoch
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/deroproject/derohe/block"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// EPOCH event callbacks and sync
+type events struct {
+	blockFound   func(BlockFoundEvent)
+	configChange func(ConfigChange)
+	sessionLimit func(SessionLimitEvent)
+	rawMessage   func([]byte)
+	orphanAlert  func(OrphanAlertEvent)
+	pressure     func(int)                        // pressure is called with the submit queue depth when it reaches the high mark
+	relieved     func(int)                        // relieved is called with the submit queue depth when it drains to the low mark
+	subscribers  []chan ConnectionState           // subscribers receive the connection states until it is stopped
+	jobs         chan rpc.GetBlockTemplate_Result // jobs receives each new job of the running connection, see JobUpdates
+	serial       sync.Mutex                       // serial delivers one ConfigChange at a time
+	sync.RWMutex
+}
+
+// BlockFoundEvent is passed to the OnBlockFound callback when a valid miniblock has been submitted,
+// Block holds a copy of the exact job, work and powhash used so a win can be independently verified
+type BlockFoundEvent struct {
+	Block Submit_Params `json:"block"`
+	Time  time.Time     `json:"time"`
+}
+
+// OnBlockFound sets the callback that is called after each valid miniblock is submitted, it is
+// called from the submitting worker so it should not block. Setting nil will remove the callback
+func (e *EPOCH) OnBlockFound(fn func(BlockFoundEvent)) {
+	e.events.Lock()
+	e.events.blockFound = fn
+	e.events.Unlock()
+}
+
+// Call the OnBlockFound callback if set, submission values are copied so no buffers are shared with the caller
+func (e *EPOCH) blockFound(job rpc.GetBlockTemplate_Result, powhash [32]byte, work [block.MINIBLOCK_SIZE]byte, diff *big.Int) {
+	e.events.RLock()
+	fn := e.events.blockFound
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	event := BlockFoundEvent{
+		Block: Submit_Params{
+			Job:       job,
+			PowHash:   powhash,
+			EpochWork: work,
+		},
+		Time: time.Now(),
+	}
+	event.Block.Difficulty.Set(diff)
+
+	fn(event)
+}
+
+// ConfigChange is passed to the OnConfigChange callback when an EPOCH setting is changed
+type ConfigChange struct {
+	Field string `json:"field"` // Name of the setting, "address", "port", "maxThreads" or "maxHashes"
+	Value any    `json:"value"` // New value of the setting
+}
+
+// OnConfigChange sets the callback that is called after SetAddress, SetPort, SetMaxThreads or SetMaxHashes
+// changes a setting. Changes are delivered serially from the setter's goroutine. Setting nil will remove the callback
+func (e *EPOCH) OnConfigChange(fn func(ConfigChange)) {
+	e.events.Lock()
+	e.events.configChange = fn
+	e.events.Unlock()
+}
+
+// Call the OnConfigChange callback if set
+func (e *EPOCH) configChanged(field string, value any) {
+	e.events.RLock()
+	fn := e.events.configChange
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	e.events.serial.Lock()
+	defer e.events.serial.Unlock()
+
+	fn(ConfigChange{Field: field, Value: value})
+}
+
+// ConnectionState of the GetWork connection sent to subscribers
+type ConnectionState int
+
+const (
+	STATE_CONNECTED    ConnectionState = iota // GetWork connection is active
+	STATE_RECONNECTING                        // GetWork connection errored and is being re-established
+	STATE_DISCONNECTED                        // GetWork connection has stopped, this is the last state sent before the channel is closed
+)
+
+const (
+	SUBSCRIBER_BUFFER  = 8 // Buffer size of each subscriber channel
+	JOB_UPDATES_BUFFER = 8 // Buffer size of the JobUpdates channel
+)
+
+// Subscribe returns a channel that receives the ConnectionState changes of the current GetWork connection. When the
+// connection stops, STATE_DISCONNECTED is sent and then the channel is closed, so subscribers can range over it and exit
+// cleanly. States are not waited on, if the channel is full a state is dropped, except STATE_DISCONNECTED which replaces
+// the oldest state. If EPOCH is not running the channel will receive STATE_DISCONNECTED and already be closed
+func (e *EPOCH) Subscribe() <-chan ConnectionState {
+	ch := make(chan ConnectionState, SUBSCRIBER_BUFFER)
+
+	e.events.Lock()
+	defer e.events.Unlock()
+
+	if !e.isRunning() {
+		ch <- STATE_DISCONNECTED
+		close(ch)
+		return ch
+	}
+
+	e.events.subscribers = append(e.events.subscribers, ch)
+
+	return ch
+}
+
+// Send state to all subscribers without blocking
+func (e *EPOCH) stateChanged(state ConnectionState) {
+	e.events.RLock()
+	defer e.events.RUnlock()
+
+	for _, ch := range e.events.subscribers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// Send STATE_DISCONNECTED to all subscribers and then close and remove their channels
+func (e *EPOCH) disconnected() {
+	e.events.Lock()
+	defer e.events.Unlock()
+
+	for _, ch := range e.events.subscribers {
+		select {
+		case ch <- STATE_DISCONNECTED:
+		default:
+			// Make room for the final state
+			select {
+			case <-ch:
+			default:
+			}
+
+			select {
+			case ch <- STATE_DISCONNECTED:
+			default:
+			}
+		}
+
+		close(ch)
+	}
+
+	e.events.subscribers = nil
+}
+
+// JobUpdates returns the channel that receives each job the node sends while the GetWork connection is running, so
+// external workers can be restarted or nonce ranges reset as soon as the template changes. The channel is created by
+// StartGetWork, kept while reconnecting and closed by StopGetWork, so it can be ranged over. Jobs are not waited on, if
+// the channel is full the oldest job is dropped so the read loop is never stalled. There is one channel per connection,
+// concurrent receivers will each get some of the jobs. If EPOCH is not running the channel will already be closed
+func (e *EPOCH) JobUpdates() <-chan rpc.GetBlockTemplate_Result {
+	e.events.Lock()
+	defer e.events.Unlock()
+
+	if e.events.jobs == nil {
+		ch := make(chan rpc.GetBlockTemplate_Result)
+		close(ch)
+		return ch
+	}
+
+	return e.events.jobs
+}
+
+// Create the JobUpdates channel for a new connection
+func (e *EPOCH) openJobUpdates() {
+	e.events.Lock()
+	e.events.jobs = make(chan rpc.GetBlockTemplate_Result, JOB_UPDATES_BUFFER)
+	e.events.Unlock()
+}
+
+// Send job to the JobUpdates channel without blocking, dropping the oldest job if it is full
+func (e *EPOCH) jobUpdated(job rpc.GetBlockTemplate_Result) {
+	e.events.Lock()
+	defer e.events.Unlock()
+
+	ch := e.events.jobs
+	if ch == nil {
+		return
+	}
+
+	for {
+		select {
+		case ch <- job:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Close and remove the JobUpdates channel of the stopped connection
+func (e *EPOCH) closeJobUpdates() {
+	e.events.Lock()
+	defer e.events.Unlock()
+
+	if e.events.jobs != nil {
+		close(e.events.jobs)
+		e.events.jobs = nil
+	}
+}
+
+// SessionLimitEvent is passed to the OnSessionLimit callback when the session hash limit has been reached
+type SessionLimitEvent struct {
+	Limit   uint64                 `json:"limit"`   // Session hash limit set by SetSessionHashLimit
+	Session GetSessionEPOCH_Result `json:"session"` // Session when the limit was reached
+	Time    time.Time              `json:"time"`
+}
+
+// OnSessionLimit sets the callback that is called once per session when an attempt is refused for exceeding the
+// session hash limit, it is called from the attempting goroutine. Setting nil will remove the callback
+func (e *EPOCH) OnSessionLimit(fn func(SessionLimitEvent)) {
+	e.events.Lock()
+	e.events.sessionLimit = fn
+	e.events.Unlock()
+}
+
+// Call the OnSessionLimit callback if set
+func (e *EPOCH) sessionLimit(event SessionLimitEvent) {
+	e.events.RLock()
+	fn := e.events.sessionLimit
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	fn(event)
+}
+
+// SetRawMessageHook sets a hook that is called with each raw frame received from the GetWork server before it is
+// decoded, to help diagnose unexpected node behavior. It is called from the read loop so it should not block, and
+// the frame is not reused by EPOCH. Setting nil will remove the hook (default)
+func (e *EPOCH) SetRawMessageHook(fn func([]byte)) {
+	e.events.Lock()
+	e.events.rawMessage = fn
+	e.events.Unlock()
+}
+
+// Call the raw message hook if set
+func (e *EPOCH) rawMessage(message []byte) {
+	e.events.RLock()
+	fn := e.events.rawMessage
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	fn(message)
+}
@@ -0,0 +1,54 @@
+package epoch
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/deroproject/derohe/block"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// SetFixedJob pins hashing to job instead of the jobs received from GetWork, so a reported template can be reproduced.
+// Jobs received while a job is fixed are still tracked by JobStatus and the session but are not hashed on, and the fixed
+// job is not aged out by maxJobAge. Hashing the fixed job is a dry run, valid hashes are logged but not submitted to the node
+// as the template is not the node's current job. Use ClearFixedJob to resume the stream
+func (e *EPOCH) SetFixedJob(job rpc.GetBlockTemplate_Result) (err error) {
+	var work [block.MINIBLOCK_SIZE]byte
+	if n, hErr := hex.Decode(work[:], []byte(job.Blockhashing_blob)); hErr != nil || n != block.MINIBLOCK_SIZE {
+		err = fmt.Errorf("fixed job blockhashing_blob must be %d hex bytes", block.MINIBLOCK_SIZE)
+		return
+	}
+
+	e.jobs.Lock()
+	snapshot := *e.jobs.load()
+	snapshot.fixed = &job
+	e.jobs.store(snapshot)
+	e.jobs.Unlock()
+
+	return
+}
+
+// ClearFixedJob removes the job set by SetFixedJob, hashing resumes on the jobs received from GetWork
+func (e *EPOCH) ClearFixedJob() {
+	e.jobs.Lock()
+	snapshot := *e.jobs.load()
+	snapshot.fixed = nil
+	e.jobs.store(snapshot)
+	e.jobs.Unlock()
+}
+
+// Get the job set by SetFixedJob, ok is false if there is no fixed job
+func (e *EPOCH) GetFixedJob() (job rpc.GetBlockTemplate_Result, ok bool) {
+	if fixed := e.jobs.load().fixed; fixed != nil {
+		return *fixed, true
+	}
+
+	return
+}
+
+// Check if job is the job set by SetFixedJob
+func (e *EPOCH) isFixedJob(job rpc.GetBlockTemplate_Result) bool {
+	fixed := e.jobs.load().fixed
+
+	return fixed != nil && *fixed == job
+}
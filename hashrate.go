@@ -0,0 +1,110 @@
+package epoch
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	DEFAULT_HASHRATE_WINDOW = time.Second * 60 // Default time span of the session's CurrentHashrate average
+	HASHRATE_INTERVAL       = time.Second      // Wall clock time hashes from all batches are summed over before being added to the average
+)
+
+// Exponentially weighted moving average of the hash rate, hashes from concurrent batches are summed over
+// wall clock intervals so the average is the total rate of all batches rather than the rate of one batch
+type rateAverage struct {
+	rate   float64       // rate is the current average in H/s
+	hashes uint64        // hashes is the sum of hashes added since the current interval began
+	since  time.Time     // since is when the current interval began
+	last   time.Time     // last is when the latest sample ended
+	window time.Duration // window is the time constant of the average, samples older than window have a weight of less than 1/e
+}
+
+// Add a sample of hashes performed over duration, ending at end. Once HASHRATE_INTERVAL of wall clock time has been
+// summed the total is added to the average, time between samples with no hashes decays the average
+func (r *rateAverage) add(hashes uint64, duration time.Duration, end time.Time) {
+	if duration <= 0 {
+		return
+	}
+
+	start := end.Add(-duration)
+	switch {
+	case r.since.IsZero():
+		r.since = start
+	case start.After(r.last):
+		// Nothing was hashing since the latest sample ended, close the interval there and decay over the idle time
+		r.fold(r.last)
+		r.rate *= math.Exp(-start.Sub(r.last).Seconds() / r.window.Seconds())
+		r.since = start
+	}
+
+	r.hashes += hashes
+	if end.After(r.last) {
+		r.last = end
+	}
+
+	if r.last.Sub(r.since) >= HASHRATE_INTERVAL {
+		r.fold(r.last)
+	}
+}
+
+// Add the hashes summed from since until end to the average and begin a new interval at end
+func (r *rateAverage) fold(end time.Time) {
+	r.rate = r.sample(end)
+	r.hashes = 0
+	r.since = end
+}
+
+// Get the average including the hashes summed from since until end
+func (r *rateAverage) sample(end time.Time) float64 {
+	elapsed := end.Sub(r.since).Seconds()
+	if elapsed <= 0 {
+		return r.rate
+	}
+
+	alpha := 1 - math.Exp(-elapsed/r.window.Seconds())
+
+	return r.rate + alpha*(float64(r.hashes)/elapsed-r.rate)
+}
+
+// Get the current average, until an interval has been added the hashes summed so far are used so a new session has a rate.
+// Later partial intervals are not used as hashes from concurrent batches that began before the interval would inflate them
+func (r *rateAverage) current() float64 {
+	if r.rate == 0 {
+		return r.sample(r.last)
+	}
+
+	return r.rate
+}
+
+// Reset the average
+func (r *rateAverage) reset() {
+	r.rate = 0
+	r.hashes = 0
+	r.since = time.Time{}
+	r.last = time.Time{}
+}
+
+// Set the time span used to average the session's CurrentHashrate, a longer window is more stable for bursty
+// workloads while a shorter window responds faster to changes. Default is DEFAULT_HASHRATE_WINDOW
+func (e *EPOCH) SetHashrateWindow(d time.Duration) (err error) {
+	if d <= 0 {
+		err = fmt.Errorf("hashrate window must be greater than 0")
+		return
+	}
+
+	e.Lock()
+	e.hashrate.window = d
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH hashrate window
+func (e *EPOCH) GetHashrateWindow() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.hashrate.window
+}
@@ -0,0 +1,73 @@
+package epoch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HealthStatus of EPOCH reported by Health
+type HealthStatus string
+
+const (
+	HEALTH_HEALTHY   HealthStatus = "healthy"   // Connected with a fresh job
+	HEALTH_DEGRADED  HealthStatus = "degraded"  // Connected but the job is stale, the orphan rate is high or it is reconnecting frequently
+	HEALTH_UNHEALTHY HealthStatus = "unhealthy" // Not connected or reconnecting
+
+	HEALTH_ORPHAN_RATE      = 10               // Orphan rate percentage above which EPOCH is degraded
+	HEALTH_RECONNECTS       = 3                // Reconnects within HEALTH_RECONNECT_WINDOW at which EPOCH is degraded
+	HEALTH_RECONNECT_WINDOW = time.Minute * 10 // Time span reconnects are counted over
+)
+
+// EPOCH Health result
+type Health_Result struct {
+	Status  HealthStatus `json:"status"`
+	Reasons []string     `json:"reasons,omitempty"` // Why EPOCH is degraded or unhealthy
+}
+
+// Health returns if EPOCH is healthy, degraded or unhealthy with the reasons it is not healthy. It is degraded when connected but
+// the job is stale as per SetMaxJobAge, the orphan rate is above HEALTH_ORPHAN_RATE or there have been HEALTH_RECONNECTS within
+// HEALTH_RECONNECT_WINDOW. The orphan rate is only considered once ORPHAN_ALERT_MIN blocks have been reported
+func (e *EPOCH) Health() (result Health_Result) {
+	if !e.isRunning() {
+		result.Status = HEALTH_UNHEALTHY
+		result.Reasons = []string{"not connected"}
+		return
+	}
+
+	if !e.IsActive() {
+		result.Status = HEALTH_UNHEALTHY
+		result.Reasons = []string{"reconnecting"}
+		return
+	}
+
+	if job := e.JobStatus(); job.Stale {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("job is stale, last job with work was %s ago", job.Age.Round(time.Second)))
+	}
+
+	e.RLock()
+	rate, blocks := e.orphans.rate(), e.orphans.count
+	e.RUnlock()
+	if blocks >= ORPHAN_ALERT_MIN && rate > HEALTH_ORPHAN_RATE {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("orphan rate is %0.2f%% of the last %d blocks", rate, blocks))
+	}
+
+	e.endpoints.Lock()
+	reconnects := len(e.endpoints.recentReconnects())
+	e.endpoints.Unlock()
+	if reconnects >= HEALTH_RECONNECTS {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("reconnected %d times in %s", reconnects, HEALTH_RECONNECT_WINDOW))
+	}
+
+	result.Status = HEALTH_HEALTHY
+	if len(result.Reasons) > 0 {
+		result.Status = HEALTH_DEGRADED
+	}
+
+	return
+}
+
+// HealthEPOCH returns the EPOCH Health, it is unhealthy rather than erroring when EPOCH is not active
+func (e *EPOCH) HealthEPOCH(ctx context.Context) (result Health_Result, err error) {
+	return e.Health(), nil
+}
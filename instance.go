@@ -0,0 +1,102 @@
+package epoch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/civilware/tela/logger"
+	"github.com/deroproject/derohe/block"
+)
+
+// Option configures an EPOCH created by New
+type Option func(*EPOCH) error
+
+// New creates an EPOCH with the package defaults and opts applied in order. Each EPOCH has its own connection, jobs,
+// session and config, so a host application can mine to different daemons or reward addresses from one process. The
+// package-level functions use a default instance, see Default. An option that errors is logged and its default is kept,
+// use NewWithOptions to have the error returned
+func New(opts ...Option) *EPOCH {
+	e := newEPOCH()
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			logger.Warnf("[EPOCH] %s\n", err)
+		}
+	}
+
+	return e
+}
+
+// NewWithOptions is New returning the error of the first option that errors instead of logging it, no EPOCH is returned with the error
+func NewWithOptions(opts ...Option) (e *EPOCH, err error) {
+	e = newEPOCH()
+	for _, opt := range opts {
+		if err = opt(e); err != nil {
+			return nil, fmt.Errorf("invalid EPOCH option: %w", err)
+		}
+	}
+
+	return
+}
+
+// Create an EPOCH with the package defaults
+func newEPOCH() *EPOCH {
+	e := &EPOCH{}
+	e.port = fmt.Sprintf(":%d", DEFAULT_WORK_PORT)
+	e.SetMaxThreads(DEFAULT_MAX_THREADS)
+	e.maxHashes = 1000
+	e.maxSubmits = DEFAULT_MAX_SUBMITS
+	e.SetBackpressureMarks(DEFAULT_BACKPRESSURE_HIGH, DEFAULT_BACKPRESSURE_LOW)
+	e.maxJobAge = DEFAULT_MAX_JOB_AGE
+	e.blockTime = DEFAULT_BLOCK_TIME
+	e.versions = []byte{1}
+	e.tls = true
+	e.workPath = DEFAULT_WORK_PATH
+	e.hashrate.window = DEFAULT_HASHRATE_WINDOW
+	e.redial = [2]time.Duration{RECONNECT_DELAY, RECONNECT_MAX_DELAY}
+	e.SetNonceRegion(block.MINIBLOCK_SIZE-DEFAULT_NONCE_BYTES, DEFAULT_NONCE_BYTES)
+
+	e.session.Version = "1.0.0" // EPOCH package version
+
+	return e
+}
+
+// Default returns the EPOCH instance used by the package-level functions
+func Default() *EPOCH {
+	return epoch
+}
+
+// WithAddress sets the reward address of a new EPOCH
+func WithAddress(address string) Option {
+	return func(e *EPOCH) error {
+		return e.SetAddress(address)
+	}
+}
+
+// WithPort sets the GetWork port of a new EPOCH
+func WithPort(port int) Option {
+	return func(e *EPOCH) error {
+		return e.SetPort(port)
+	}
+}
+
+// WithMaxThreads sets the maximum concurrent workers of a new EPOCH
+func WithMaxThreads(i int) Option {
+	return func(e *EPOCH) error {
+		e.SetMaxThreads(i)
+		return nil
+	}
+}
+
+// WithMaxHashes sets the maximum hashes per request of a new EPOCH
+func WithMaxHashes(i int) Option {
+	return func(e *EPOCH) error {
+		return e.SetMaxHashes(i)
+	}
+}
+
+// WithReconnectPolicy sets the ReconnectPolicy of a new EPOCH
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(e *EPOCH) error {
+		return e.SetReconnectPolicy(policy)
+	}
+}
@@ -0,0 +1,90 @@
+package epoch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const MIN_KEEPALIVE = time.Millisecond * 100 // Minimum interval of SetKeepalive
+
+// Set the keepalive interval of the GetWork connection, a ping is sent every half interval and the connection is declared
+// dead and closed if nothing is received from the node within interval, so a silently dropped connection is not waited on
+// forever. A dead connection is reconnected as per the ReconnectPolicy, otherwise EPOCH is stopped. Interval must be at
+// least MIN_KEEPALIVE or 0 to disable keepalive, default is 0. The setting is used by the next connect or reconnect
+func (e *EPOCH) SetKeepalive(interval time.Duration) (err error) {
+	if interval != 0 && interval < MIN_KEEPALIVE {
+		err = fmt.Errorf("keepalive interval %s is less than %s", interval, MIN_KEEPALIVE)
+		return
+	}
+
+	e.Lock()
+	e.keepalive = interval
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH keepalive interval
+func (e *EPOCH) GetKeepalive() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.keepalive
+}
+
+// Ping ws every half interval and set its read deadline to interval, which is extended by each pong and received frame.
+// Returns a func that stops the pings, when interval is 0 or ws is not a websocket keepalive is disabled and ws is unchanged
+func keepalive(conn workConn, interval time.Duration) (stop func()) {
+	ws, ok := conn.(*websocket.Conn)
+	if !ok || interval <= 0 {
+		return func() {}
+	}
+
+	extendDeadline(ws, interval)
+	ws.SetPongHandler(func(string) error {
+		extendDeadline(ws, interval)
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			// A failed ping will also fail the read, which ends the connection
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval/2)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Extend the read deadline of ws to interval from now, when interval is 0 or ws is not a websocket keepalive is disabled
+func extendDeadline(conn workConn, interval time.Duration) {
+	ws, ok := conn.(*websocket.Conn)
+	if !ok || interval <= 0 {
+		return
+	}
+
+	ws.SetReadDeadline(time.Now().Add(interval))
+}
+
+// Check if err is from the read deadline set by keepalive
+func isKeepaliveTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,42 @@
+package epoch
+
+// Set the maximum total hashes for a session, once an attempt would exceed it EPOCH stops accepting new
+// attempts returning ErrSessionHashLimit and the OnSessionLimit callback is called. A limit of 0 is unlimited (default)
+func (e *EPOCH) SetSessionHashLimit(n uint64) {
+	e.Lock()
+	e.hashLimit = n
+	e.limited = false
+	e.Unlock()
+}
+
+// Get the EPOCH session hash limit
+func (e *EPOCH) GetSessionHashLimit() uint64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.hashLimit
+}
+
+// Reserve hashes for an attempt against the session hash limit, returns ErrSessionHashLimit if the
+// session hashes and hashes reserved by running attempts would exceed the limit
+func (e *EPOCH) reserveHashes(hashes uint64) (err error) {
+	var event *SessionLimitEvent
+
+	e.Lock()
+	if e.hashLimit > 0 && e.session.Hashes+e.pending+hashes > e.hashLimit {
+		err = ErrSessionHashLimit
+		if !e.limited {
+			e.limited = true
+			event = &SessionLimitEvent{Limit: e.hashLimit, Session: e.sessionSnapshot()}
+		}
+	} else {
+		e.pending += hashes
+	}
+	e.Unlock()
+
+	if event != nil {
+		e.sessionLimit(*event)
+	}
+
+	return
+}
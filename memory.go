@@ -0,0 +1,82 @@
+package epoch
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Per batch memory totals and sync
+type memProfile struct {
+	enabled bool   // enabled when batches should be measured
+	batches uint64 // batches is the count of batches measured
+	alloc   uint64 // alloc is the total bytes allocated during measured batches
+	heap    uint64 // heap is the heap in use after the last measured batch
+	sync.Mutex
+}
+
+// Check if memory profiling is enabled
+func (m *memProfile) on() bool {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.enabled
+}
+
+// Read the current memory stats if memory profiling is enabled, ok is false when disabled
+func (m *memProfile) start() (stats runtime.MemStats, ok bool) {
+	if !m.on() {
+		return
+	}
+
+	runtime.ReadMemStats(&stats)
+
+	return stats, true
+}
+
+// Add the bytes allocated since start to the totals
+func (m *memProfile) add(start *runtime.MemStats) {
+	var end runtime.MemStats
+	runtime.ReadMemStats(&end)
+
+	m.Lock()
+	m.batches++
+	m.alloc += end.TotalAlloc - start.TotalAlloc
+	m.heap = end.HeapInuse
+	m.Unlock()
+}
+
+// Get the average bytes allocated per batch and the heap in use after the last batch
+func (m *memProfile) averages() (batchAlloc, heapInUse uint64) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.batches == 0 {
+		return
+	}
+
+	return m.alloc / m.batches, m.heap
+}
+
+// Reset the memory totals
+func (m *memProfile) reset() {
+	m.Lock()
+	m.batches, m.alloc, m.heap = 0, 0, 0
+	m.Unlock()
+}
+
+// Set if EPOCH should measure the memory of each AttemptHashes batch, reporting the average bytes allocated per batch and
+// the heap in use in the session so memory can be watched on low-memory devices. Allocations are process-wide while a batch
+// is running, so they include any concurrent batches and host work. Measuring briefly stops the world twice per batch so
+// default is false. Per-batch state is pooled so the memory retained by EPOCH is bounded by maxHashes, not total hashes
+func (e *EPOCH) SetMemoryProfiling(b bool) {
+	e.memory.Lock()
+	e.memory.enabled = b
+	e.memory.Unlock()
+
+	e.memory.reset()
+}
+
+// Get if EPOCH memory profiling is enabled
+func (e *EPOCH) GetMemoryProfiling() bool {
+	return e.memory.on()
+}
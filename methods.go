@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"net"
 	"time"
 
 	"github.com/creachadair/jrpc2/handler"
@@ -11,22 +12,29 @@ import (
 	"github.com/deroproject/derohe/rpc"
 )
 
-var epochHandler = map[string]handler.Func{
-	"AttemptEPOCH":      handler.New(AttemptEPOCH),
-	"SubmitEPOCH":       handler.New(SubmitEPOCH),
-	"GetMaxHashesEPOCH": handler.New(GetMaxHashesEPOCH),
-	"GetAddressEPOCH":   handler.New(GetAddressEPOCH),
-	"GetSessionEPOCH":   handler.New(GetSessionEPOCH),
+// EPOCH methods of e by name, GetHandler and MethodSchemas are both built from these
+func (e *EPOCH) methods() map[string]any {
+	return map[string]any{
+		"AttemptEPOCH":         e.AttemptEPOCH,
+		"AttemptAndStatsEPOCH": e.AttemptAndStatsEPOCH,
+		"SubmitEPOCH":          e.SubmitEPOCH,
+		"SubmitRefEPOCH":       e.SubmitRefEPOCH,
+		"GetMaxHashesEPOCH":    e.GetMaxHashesEPOCH,
+		"GetAddressEPOCH":      e.GetAddressEPOCH,
+		"GetSessionEPOCH":      e.GetSessionEPOCH,
+		"DashboardEPOCH":       e.Dashboard,
+		"HealthEPOCH":          e.HealthEPOCH,
+	}
 }
 
-// Returns methods in epochHandler
-func GetHandler() map[string]handler.Func {
-	handler := map[string]handler.Func{}
-	for n, f := range epochHandler {
-		handler[n] = f
+// Returns a handler for each of the EPOCH methods of e
+func (e *EPOCH) GetHandler() map[string]handler.Func {
+	methods := map[string]handler.Func{}
+	for n, fn := range e.methods() {
+		methods[n] = handler.New(fn)
 	}
 
-	return handler
+	return methods
 }
 
 // EPOCH call structures
@@ -42,26 +50,69 @@ type (
 		PowHash    [32]byte                    `json:"powHash"`
 		EpochWork  [block.MINIBLOCK_SIZE]byte  `json:"epochWork"`
 		Difficulty big.Int                     `json:"epochDifficulty"`
+		Signature  string                      `json:"epochSignature,omitempty"` // Hex HMAC from Sign, required when the host has set a worker key
+	}
+
+	// EPOCH slim submit params, the job is taken from the host's current job for JobID
+	SubmitRef struct {
+		JobID         string `json:"jobid"`
+		WorkHex       string `json:"epochWork"`                // Hex of the miniblock work that was hashed
+		DifficultyStr string `json:"epochDifficulty"`          // Base 10 difficulty the work was hashed at
+		Signature     string `json:"epochSignature,omitempty"` // Signature of the reconstructed submission, required when the host has set a worker key
 	}
 
 	// EPOCH attempt/submit result
 	EPOCH_Result struct {
 		Hashes     uint64  `json:"epochHashes"`
 		Submitted  int     `json:"epochSubmitted"`
-		Duration   int64   `json:"epochDuration"`
+		Duration   float64 `json:"epochDuration"` // Milliseconds with microsecond precision
 		HashPerSec float64 `json:"epochHashPerSecond,omitempty"`
 		Error      error   `json:"epochError,omitempty"`
+		BatchID    uint64  `json:"epochBatch"`               // Unique ID of the batch that is included in its log lines
+		MaxHashes  int     `json:"epochMaxHashes,omitempty"` // maxHashes when the attempt started, the limit that applied to the batch
 	}
 )
 
-// AttemptEPOCH performs the POW and submits its results to the connected node
-func AttemptEPOCH(ctx context.Context, p Attempt_Params) (result EPOCH_Result, err error) {
-	return AttemptHashes(p.Hashes)
+// String summarizes the result as "<submitted> submitted / <hashes> hashes @ <hash rate> in <duration>",
+// with the error appended if the batch errored
+func (r EPOCH_Result) String() string {
+	duration := time.Duration(r.Duration * float64(time.Millisecond)).Round(time.Microsecond)
+	summary := fmt.Sprintf("%d submitted / %d hashes @ %s in %s", r.Submitted, r.Hashes, HashrateToString(r.HashPerSec), duration)
+	if r.Error != nil {
+		summary += ": " + r.Error.Error()
+	}
+
+	return summary
+}
+
+// AttemptEPOCH performs the POW and submits its results to the connected node, hashing stops if the request's ctx is done
+func (e *EPOCH) AttemptEPOCH(ctx context.Context, p Attempt_Params) (result EPOCH_Result, err error) {
+	return e.AttemptHashesContext(ctx, p.Hashes)
+}
+
+// EPOCH AttemptAndStatsEPOCH result
+type AttemptAndStats_Result struct {
+	Result  EPOCH_Result           `json:"epochResult"`
+	Session GetSessionEPOCH_Result `json:"epochSession"`
+}
+
+// AttemptAndStatsEPOCH performs the POW and submits its results to the connected node, returning the result with the session
+// as it was when the attempt was added to it. Other concurrent attempts that finished before this one will be included in the session
+func (e *EPOCH) AttemptAndStatsEPOCH(ctx context.Context, p Attempt_Params) (result AttemptAndStats_Result, err error) {
+	result.Result, result.Session, err = e.attemptHashes(ctx, p.Hashes)
+
+	return
 }
 
 // SubmitEPOCH submits pre computed block data to the connected node
-func SubmitEPOCH(ctx context.Context, params []Submit_Params) (result EPOCH_Result, err error) {
-	return SubmitHashes(params)
+func (e *EPOCH) SubmitEPOCH(ctx context.Context, params []Submit_Params) (result EPOCH_Result, err error) {
+	return e.SubmitHashes(params)
+}
+
+// SubmitRefEPOCH submits pre computed work to the connected node using the host's current job, so remote workers
+// do not need to send back the whole job template. Refs for a JobID that is no longer current are rejected
+func (e *EPOCH) SubmitRefEPOCH(ctx context.Context, refs []SubmitRef) (result EPOCH_Result, err error) {
+	return e.SubmitRefs(refs)
 }
 
 // EPOCH GetMaxHashes result
@@ -70,13 +121,13 @@ type GetMaxHashes_Result struct {
 }
 
 // GetMaxHashesEPOCH returns the current max hash per request setting if EPOCH is active
-func GetMaxHashesEPOCH(ctx context.Context) (result GetMaxHashes_Result, err error) {
-	if !IsActive() {
-		err = fmt.Errorf("epoch is not active")
+func (e *EPOCH) GetMaxHashesEPOCH(ctx context.Context) (result GetMaxHashes_Result, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
 		return
 	}
 
-	result.MaxHashes = GetMaxHashes()
+	result.MaxHashes = e.GetMaxHashes()
 
 	return
 }
@@ -87,31 +138,110 @@ type GetAddressEPOCH_Result struct {
 }
 
 // GetAddressEPOCH returns the current address EPOCH has set if active
-func GetAddressEPOCH(ctx context.Context) (result GetAddressEPOCH_Result, err error) {
-	if !IsActive() {
-		err = fmt.Errorf("epoch is not active")
+func (e *EPOCH) GetAddressEPOCH(ctx context.Context) (result GetAddressEPOCH_Result, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
 		return
 	}
 
-	result.Address = GetAddress()
+	result.Address = e.GetAddress()
 
 	return
 }
 
 // EPOCH GetSessionEPOCH result
 type GetSessionEPOCH_Result struct {
-	Hashes     uint64 `json:"sessionHashes"`
-	MiniBlocks int    `json:"sessionMinis"`
-	Version    string `json:"sessionVersion"`
+	Hashes               uint64    `json:"sessionHashes"`
+	CurrentHashrate      float64   `json:"sessionHashrate"` // Moving average hash rate in H/s, see SetHashrateWindow
+	MiniBlocks           int       `json:"sessionMinis"`
+	Threads              int       `json:"sessionThreads"`      // Worker threads the session was started with, see SetMaxThreads
+	CumulativeDifficulty string    `json:"sessionDifficulty"`   // Sum of the difficulty of all submitted miniblocks, estimates the total POW contributed
+	Accepted             uint64    `json:"sessionAccepted"`     // Blocks the node has reported as accepted
+	Rejected             uint64    `json:"sessionRejected"`     // Blocks the node has reported as rejected
+	Unknown              uint64    `json:"sessionUnknown"`      // Submissions not confirmed before the confirm timeout, see SetConfirmTimeout
+	Deduped              uint64    `json:"sessionDeduped"`      // Identical miniblocks not submitted again within RECENT_SUBMIT_WINDOW
+	SuccessRatio         float64   `json:"sessionRatio"`        // Accepted / (Accepted+Rejected), see SubmitSuccessRatio
+	OrphanRate           float64   `json:"sessionOrphanRate"`   // Percentage of the last ORPHAN_WINDOW reported blocks that were rejected, see SetOrphanAlert
+	Reward               uint64    `json:"sessionReward"`       // Estimated reward of accepted blocks in atomic units, see SetRewardPerBlock
+	MissedHeights        uint64    `json:"sessionMissed"`       // Heights that were not seen while reconnecting
+	HashFuncNs           int64     `json:"sessionHashFuncNs"`   // Average ns spent in AstroBWTv3 per hash, see SetProfiling
+	OverheadNs           int64     `json:"sessionOverheadNs"`   // Average ns of worker overhead around AstroBWTv3 per hash, see SetProfiling
+	BatchAlloc           uint64    `json:"sessionBatchAlloc"`   // Average bytes allocated per batch, see SetMemoryProfiling
+	HeapInUse            uint64    `json:"sessionHeapInUse"`    // Bytes of heap in use after the last measured batch, see SetMemoryProfiling
+	SubmitQueue          int       `json:"sessionSubmitQueue"`  // Valid hashes currently waiting to be submitted, see SetBackpressureMarks
+	BlobVersions         []int     `json:"sessionBlobVersions"` // Job blob versions received during the session, see SetAcceptedVersions
+	Started              time.Time `json:"sessionStarted"`      // When the session was started, or the start time restored by ImportSession
+	Version              string    `json:"sessionVersion"`
+}
+
+// String summarizes the session as "<hashes> hashes, <miniblocks> miniblocks (<accepted> accepted / <rejected> rejected)
+// @ <hash rate> on <threads> threads"
+func (s GetSessionEPOCH_Result) String() string {
+	return fmt.Sprintf("%d hashes, %d miniblocks (%d accepted / %d rejected) @ %s on %d threads",
+		s.Hashes, s.MiniBlocks, s.Accepted, s.Rejected, HashrateToString(s.CurrentHashrate), s.Threads)
 }
 
 // GetSessionEPOCH returns the statistics for the current EPOCH session if active. There may be multiple applications connected to
-// a EPOCH session, the result values will be the sum of all the connections
-func GetSessionEPOCH(ctx context.Context) (result GetSessionEPOCH_Result, err error) {
-	if !IsActive() {
-		err = fmt.Errorf("epoch is not active")
+// a EPOCH session, the result values will be the sum of all the connections. The GetWork protocol does not report how many
+// miners are connected to the node, so the session only includes what has been hashed and reported through this EPOCH
+func (e *EPOCH) GetSessionEPOCH(ctx context.Context) (result GetSessionEPOCH_Result, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
 		return
 	}
 
-	return GetSession(time.Second * 15)
+	return e.GetSession(time.Second * 15)
+}
+
+// EPOCH Dashboard result
+type Dashboard_Result struct {
+	Connection DashboardConnection    `json:"connection"`
+	Session    GetSessionEPOCH_Result `json:"session"`
+	Hashrate   float64                `json:"hashrate"` // Moving average hash rate in H/s, the same as the session's CurrentHashrate
+	Job        JobStatus_Result       `json:"job"`
+	Health     Health_Result          `json:"health"`
+	Healthy    bool                   `json:"healthy"` // True when Health is HEALTH_HEALTHY
+}
+
+// Connection info of the Dashboard
+type DashboardConnection struct {
+	Active     bool   `json:"active"`
+	Processing bool   `json:"processing"`
+	Address    string `json:"address"`
+	Port       string `json:"port"`    // Port of the connected endpoint, it changes when a pool fails over
+	TLS        bool   `json:"tls"`     // True when the connection is wss or https
+	Threads    int    `json:"threads"` // Worker threads the session was started with
+	MaxHashes  int    `json:"maxHashes"`
+}
+
+// Dashboard returns everything a front-end needs to display EPOCH in one call if active. The connection
+// settings and session are read together under one lock so the result is a consistent snapshot of the session
+func (e *EPOCH) Dashboard(ctx context.Context) (result Dashboard_Result, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
+		return
+	}
+
+	result.Connection.Active = true
+	result.Job = e.JobStatus()
+	result.Health = e.Health()
+
+	e.RLock()
+	result.Session = e.sessionSnapshot()
+	result.Connection.Processing = e.processing
+	result.Connection.Address = e.address
+	result.Connection.MaxHashes = e.maxHashes
+	e.RUnlock()
+
+	// Port and TLS are those of the running connection, not the settings used by the next StartGetWork
+	_, result.Connection.Port, _ = net.SplitHostPort(e.currentEndpoint())
+	e.conn.Lock()
+	result.Connection.TLS = e.conn.scheme == "wss" || e.conn.scheme == "https"
+	e.conn.Unlock()
+
+	result.Connection.Threads = result.Session.Threads
+	result.Hashrate = result.Session.CurrentHashrate
+	result.Healthy = result.Health.Status == HEALTH_HEALTHY
+
+	return
 }
@@ -0,0 +1,126 @@
+package epoch
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	ORPHAN_WINDOW    = 100 // Number of the most recently reported blocks the session's OrphanRate is calculated over
+	ORPHAN_ALERT_MIN = 10  // Minimum reported blocks in the window before the OnOrphanAlert callback can be called
+)
+
+// Rolling record of the most recent blocks the node has reported as accepted or rejected
+type orphanRate struct {
+	records   [ORPHAN_WINDOW]bool // records is a ring buffer of reported blocks, true when the block was rejected
+	next      int                 // next is the index of records that will be written next
+	count     int                 // count is the number of records written, up to ORPHAN_WINDOW
+	rejected  int                 // rejected is the number of rejected blocks in records
+	threshold float64             // threshold is the orphan rate percentage the alert is called above, 0 is disabled
+	alerted   bool                // alerted is set once the alert has been called until the rate returns to threshold or below
+}
+
+// OrphanAlertEvent is passed to the OnOrphanAlert callback when the session's orphan rate has exceeded its threshold
+type OrphanAlertEvent struct {
+	Rate      float64   `json:"rate"`      // Percentage of the reported blocks in the window that were rejected
+	Threshold float64   `json:"threshold"` // Threshold set by SetOrphanAlert
+	Blocks    int       `json:"blocks"`    // Reported blocks in the window, up to ORPHAN_WINDOW
+	Time      time.Time `json:"time"`
+}
+
+// Add reported blocks to the ring buffer, only the last ORPHAN_WINDOW are kept
+func (o *orphanRate) add(accepted, rejected uint64) {
+	for _, r := range []struct {
+		n        uint64
+		rejected bool
+	}{{accepted, false}, {rejected, true}} {
+		for i := uint64(0); i < min(r.n, ORPHAN_WINDOW); i++ {
+			if o.count == ORPHAN_WINDOW && o.records[o.next] {
+				o.rejected--
+			}
+
+			o.records[o.next] = r.rejected
+			if r.rejected {
+				o.rejected++
+			}
+
+			o.next = (o.next + 1) % ORPHAN_WINDOW
+			if o.count < ORPHAN_WINDOW {
+				o.count++
+			}
+		}
+	}
+}
+
+// Get the percentage of the reported blocks in the window that were rejected
+func (o *orphanRate) rate() float64 {
+	if o.count == 0 {
+		return 0
+	}
+
+	return math.Round(float64(o.rejected)/float64(o.count)*10000) / 100
+}
+
+// Check the rate against the alert threshold, returns the event to be passed to OnOrphanAlert when
+// the rate has exceeded the threshold since the last alert
+func (o *orphanRate) alert() *OrphanAlertEvent {
+	if o.threshold <= 0 || o.count < ORPHAN_ALERT_MIN {
+		return nil
+	}
+
+	rate := o.rate()
+	if rate <= o.threshold {
+		o.alerted = false
+		return nil
+	}
+
+	if o.alerted {
+		return nil
+	}
+	o.alerted = true
+
+	return &OrphanAlertEvent{Rate: rate, Threshold: o.threshold, Blocks: o.count}
+}
+
+// Reset the ring buffer keeping the alert threshold
+func (o *orphanRate) reset() {
+	*o = orphanRate{threshold: o.threshold}
+}
+
+// SetOrphanAlert sets the callback that is called when the percentage of the last ORPHAN_WINDOW blocks reported by the node
+// as rejected exceeds threshold, it is called from the GetWork read loop so it should not block. The callback is called once
+// each time the rate rises above threshold. Setting nil will remove the callback
+func (e *EPOCH) SetOrphanAlert(threshold float64, fn func(OrphanAlertEvent)) (err error) {
+	if fn != nil && (threshold <= 0 || threshold >= 100) {
+		err = fmt.Errorf("orphan alert threshold must be greater than 0 and less than 100")
+		return
+	}
+
+	e.events.Lock()
+	e.events.orphanAlert = fn
+	e.events.Unlock()
+
+	e.Lock()
+	e.orphans.threshold = threshold
+	if fn == nil {
+		e.orphans.threshold = 0
+	}
+	e.orphans.alerted = false
+	e.Unlock()
+
+	return
+}
+
+// Call the OnOrphanAlert callback if set
+func (e *EPOCH) orphanAlert(event OrphanAlertEvent) {
+	e.events.RLock()
+	fn := e.events.orphanAlert
+	e.events.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	fn(event)
+}
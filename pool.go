@@ -0,0 +1,194 @@
+package epoch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/civilware/tela/logger"
+)
+
+// PoolStrategy defines how StartGetWorkPool chooses among its endpoints
+type PoolStrategy int
+
+const (
+	POOL_FIRST_AVAILABLE PoolStrategy = iota // Endpoints are tried in the order they were given (default)
+	POOL_ROUND_ROBIN                         // Each selection starts at the endpoint after the last one connected to
+	POOL_LOWEST_LATENCY                      // Each selection probes all endpoints and tries them fastest first
+)
+
+const POOL_PROBE_TIMEOUT = time.Second * 2 // Maximum time a POOL_LOWEST_LATENCY probe will wait for an endpoint
+
+// GetWork endpoints of the running pool and sync
+type pool struct {
+	endpoints []string     // endpoints is the host:port of each GetWork server, nil when not connected with StartGetWorkPool
+	next      int          // next is the index after the last endpoint connected to
+	strategy  PoolStrategy // strategy is used each time an endpoint is selected
+	sync.Mutex
+}
+
+// Set the PoolStrategy used by StartGetWorkPool. Endpoints are selected when StartGetWorkPool connects and each time
+// the connection is reconnected after an error as per the ReconnectPolicy, a selection is an order to try the endpoints
+// in and each failed attempt moves on to the next endpoint in that order. The strategy is used by the next selection
+func (e *EPOCH) SetPoolStrategy(strategy PoolStrategy) (err error) {
+	if strategy < POOL_FIRST_AVAILABLE || strategy > POOL_LOWEST_LATENCY {
+		err = fmt.Errorf("invalid pool strategy %d", strategy)
+		return
+	}
+
+	e.pool.Lock()
+	e.pool.strategy = strategy
+	e.pool.Unlock()
+
+	return
+}
+
+// Get the EPOCH PoolStrategy
+func (e *EPOCH) GetPoolStrategy() PoolStrategy {
+	e.pool.Lock()
+	defer e.pool.Unlock()
+
+	return e.pool.strategy
+}
+
+// StartGetWorkPool is StartGetWork connecting to the first available of endpoints as per the PoolStrategy, each endpoint
+// is the host:port of a GetWork server. When reconnecting, the endpoints are selected again so the connection can fail
+// over to another endpoint. If no endpoint can be connected to, the error of the last endpoint tried is returned
+func (e *EPOCH) StartGetWorkPool(address string, endpoints []string) (err error) {
+	if len(endpoints) == 0 {
+		err = fmt.Errorf("no pool endpoints")
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if _, _, err = net.SplitHostPort(endpoint); err != nil {
+			err = fmt.Errorf("invalid pool endpoint %q: %s", endpoint, err)
+			return
+		}
+	}
+
+	endpoints = append([]string(nil), endpoints...)
+
+	for _, endpoint := range e.poolOrder(endpoints, 0) {
+		_, port, _ := net.SplitHostPort(endpoint)
+		err = e.startGetWork(address, endpoint, ":"+port, endpoints)
+		if err == nil || err == ErrAlreadyRunning {
+			return
+		}
+
+		logger.Warnf("[EPOCH] Pool endpoint %s failed: %s\n", endpoint, err)
+	}
+
+	return
+}
+
+// Get the order to try endpoints in as per the PoolStrategy, round robin starts at next
+func (e *EPOCH) poolOrder(endpoints []string, next int) (order []string) {
+	switch e.GetPoolStrategy() {
+	case POOL_ROUND_ROBIN:
+		start := next % len(endpoints)
+		order = append(order, endpoints[start:]...)
+		order = append(order, endpoints[:start]...)
+	case POOL_LOWEST_LATENCY:
+		order = e.byLatency(endpoints)
+	default:
+		order = append(order, endpoints...)
+	}
+
+	return
+}
+
+// Get the order to try the running pool's endpoints in when reconnecting, nil if not connected with StartGetWorkPool
+func (e *EPOCH) poolReconnectOrder() []string {
+	e.pool.Lock()
+	endpoints := e.pool.endpoints
+	next := e.pool.next
+	e.pool.Unlock()
+	if endpoints == nil {
+		return nil
+	}
+
+	return e.poolOrder(endpoints, next)
+}
+
+// Set the running pool's endpoints and the endpoint connected to, endpoints is nil when not connected with StartGetWorkPool
+func (e *EPOCH) poolConnected(endpoints []string, endpoint string) {
+	e.pool.Lock()
+	e.pool.endpoints = endpoints
+	e.pool.Unlock()
+
+	e.poolSelected(endpoint)
+}
+
+// Set the running pool's next endpoint to the one after endpoint
+func (e *EPOCH) poolSelected(endpoint string) {
+	e.pool.Lock()
+	defer e.pool.Unlock()
+
+	for i, pooled := range e.pool.endpoints {
+		if pooled == endpoint {
+			e.pool.next = i + 1
+			break
+		}
+	}
+}
+
+// Sort endpoints by the time taken to receive an HTTP response from each, endpoints that do not respond
+// within POOL_PROBE_TIMEOUT keep their order after those that did. All endpoints are probed concurrently
+// and certificates are verified the same as the GetWork connection as per SetTLSConfig
+func (e *EPOCH) byLatency(endpoints []string) []string {
+	latency := make([]time.Duration, len(endpoints))
+
+	client := &http.Client{
+		Timeout:   POOL_PROBE_TIMEOUT,
+		Transport: &http.Transport{TLSClientConfig: e.clientTLSConfig()},
+	}
+	defer client.CloseIdleConnections()
+
+	scheme := "https://"
+	if !e.GetTLSEnabled() {
+		scheme = "http://"
+	}
+
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+
+			start := time.Now()
+			res, err := client.Get(scheme + endpoint)
+			if err != nil {
+				latency[i] = -1
+				return
+			}
+			res.Body.Close()
+			latency[i] = time.Since(start)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	order := make([]int, len(endpoints))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		la, lb := latency[order[a]], latency[order[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+
+		return la < lb
+	})
+
+	sorted := make([]string, len(endpoints))
+	for i, o := range order {
+		sorted[i] = endpoints[o]
+	}
+
+	return sorted
+}
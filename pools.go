@@ -0,0 +1,66 @@
+package epoch
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// Transient per-batch and per-hash structures are pooled so continuous batches do not allocate them each time,
+// nothing returned to the caller is pooled
+
+// State shared by the workers of an AttemptHashes batch
+type batchState struct {
+	wg        sync.WaitGroup
+	workErr   workError
+	used      nonces
+	submitted atomic.Int64 // submitted is the count of valid hashes the workers have submitted
+}
+
+// Scratch values for checking a POW hash against its difficulty
+type powCheck struct {
+	hash   big.Int
+	target big.Int
+}
+
+var (
+	batchStates = sync.Pool{New: func() any { return new(batchState) }}
+	powChecks   = sync.Pool{New: func() any { return new(powCheck) }}
+
+	oneLsh256 = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+// Get a batchState with no error, submissions or used nonces
+func getBatchState() *batchState {
+	b := batchStates.Get().(*batchState)
+	b.workErr.err = nil
+	b.submitted.Store(0)
+	clear(b.used.used)
+
+	return b
+}
+
+// Return b to the pool once all of its workers are done
+func putBatchState(b *batchState) {
+	batchStates.Put(b)
+}
+
+// Check if powhash meets diff, this is blockchain.CheckPowHashBig using pooled scratch values
+func checkPowHash(powhash [32]byte, diff *big.Int) bool {
+	if diff.Sign() < 1 {
+		return false
+	}
+
+	// Hash is little-endian and big.Int wants big-endian
+	for i := 0; i < len(powhash)/2; i++ {
+		powhash[i], powhash[len(powhash)-1-i] = powhash[len(powhash)-1-i], powhash[i]
+	}
+
+	c := powChecks.Get().(*powCheck)
+	defer powChecks.Put(c)
+
+	c.hash.SetBytes(powhash[:])
+	c.target.Quo(oneLsh256, diff)
+
+	return c.hash.Cmp(&c.target) <= 0
+}
@@ -0,0 +1,81 @@
+package epoch
+
+import (
+	"sync"
+	"time"
+)
+
+// Per hash timing totals and sync
+type profile struct {
+	enabled  bool          // enabled when hashes should be timed
+	hashes   uint64        // hashes is the count of AstroBWTv3 calls timed
+	hashFunc time.Duration // hashFunc is the total time spent in AstroBWTv3
+	workers  uint64        // workers is the count of hash workers timed
+	work     time.Duration // work is the total time hash workers ran for, including hashFunc
+	sync.Mutex
+}
+
+// Check if profiling is enabled
+func (p *profile) on() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.enabled
+}
+
+// Add the time of a AstroBWTv3 call
+func (p *profile) addHashFunc(d time.Duration) {
+	p.Lock()
+	p.hashes++
+	p.hashFunc += d
+	p.Unlock()
+}
+
+// Add the time a hash worker ran for
+func (p *profile) addWork(d time.Duration) {
+	p.Lock()
+	p.workers++
+	p.work += d
+	p.Unlock()
+}
+
+// Get the average ns spent in AstroBWTv3 and the average ns of overhead around it for each hash
+func (p *profile) averages() (hashFuncNs, overheadNs int64) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.hashes == 0 || p.workers == 0 {
+		return
+	}
+
+	hashFuncNs = p.hashFunc.Nanoseconds() / int64(p.hashes)
+	overheadNs = p.work.Nanoseconds()/int64(p.workers) - hashFuncNs
+	if overheadNs < 0 {
+		overheadNs = 0
+	}
+
+	return
+}
+
+// Reset the timing totals
+func (p *profile) reset() {
+	p.Lock()
+	p.hashes, p.hashFunc = 0, 0
+	p.workers, p.work = 0, 0
+	p.Unlock()
+}
+
+// Set if EPOCH should time each hash, reporting the average time spent in AstroBWTv3 and the overhead of the
+// surrounding worker, nonce and submit logic in the session. Timing adds cost to each hash so default is false
+func (e *EPOCH) SetProfiling(b bool) {
+	e.profile.Lock()
+	e.profile.enabled = b
+	e.profile.Unlock()
+
+	e.profile.reset()
+}
+
+// Get if EPOCH profiling is enabled
+func (e *EPOCH) GetProfiling() bool {
+	return e.profile.on()
+}
@@ -0,0 +1,70 @@
+package epoch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/deroproject/derohe/block"
+)
+
+const RECENT_SUBMIT_WINDOW = time.Minute // Time an identical miniblock will not be submitted again for
+
+// Work submitted at time
+type recentSubmit struct {
+	work [block.MINIBLOCK_SIZE]byte
+	time time.Time
+}
+
+// Miniblock work submitted within RECENT_SUBMIT_WINDOW across all batches and sync
+type recentSubmits struct {
+	seen  map[[block.MINIBLOCK_SIZE]byte]time.Time // seen is when each recent work was added
+	order []recentSubmit                           // order is the recent work oldest first so it can be expired
+	sync.Mutex
+}
+
+// Add work submitted at now, returning false if it was already submitted within RECENT_SUBMIT_WINDOW
+func (r *recentSubmits) add(work [block.MINIBLOCK_SIZE]byte, now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.seen == nil {
+		r.seen = map[[block.MINIBLOCK_SIZE]byte]time.Time{}
+	}
+
+	// Expire the oldest work, an entry is only deleted if the work was not removed and added again since
+	expired := 0
+	for _, s := range r.order {
+		if now.Sub(s.time) < RECENT_SUBMIT_WINDOW {
+			break
+		}
+
+		if r.seen[s.work] == s.time {
+			delete(r.seen, s.work)
+		}
+		expired++
+	}
+	r.order = r.order[expired:]
+
+	if _, ok := r.seen[work]; ok {
+		return false
+	}
+
+	r.seen[work] = now
+	r.order = append(r.order, recentSubmit{work: work, time: now})
+
+	return true
+}
+
+// Remove work that was not submitted so it can be submitted again
+func (r *recentSubmits) remove(work [block.MINIBLOCK_SIZE]byte) {
+	r.Lock()
+	delete(r.seen, work)
+	r.Unlock()
+}
+
+// Add a suppressed duplicate submission to the session
+func (e *EPOCH) addDeduped() {
+	e.Lock()
+	e.session.Deduped++
+	e.Unlock()
+}
@@ -0,0 +1,260 @@
+package epoch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/civilware/tela/logger"
+	"github.com/gorilla/websocket"
+)
+
+// ReconnectPolicy defines which GetWork connection errors EPOCH will reconnect on
+type ReconnectPolicy int
+
+const (
+	RECONNECT_NEVER     ReconnectPolicy = iota // Any connection error will stop EPOCH (default)
+	RECONNECT_TRANSIENT                        // Reconnect on network errors, a normal or going away close from the node will stop EPOCH
+	RECONNECT_ALWAYS                           // Reconnect on any connection error
+)
+
+const (
+	RECONNECT_DELAY     = time.Second      // Default initial delay before a reconnect attempt, doubled after each failed attempt
+	RECONNECT_MAX_DELAY = time.Second * 30 // Default maximum delay between reconnect attempts
+)
+
+// Set the ReconnectPolicy used when the GetWork connection has an error
+func (e *EPOCH) SetReconnectPolicy(policy ReconnectPolicy) (err error) {
+	if policy < RECONNECT_NEVER || policy > RECONNECT_ALWAYS {
+		err = fmt.Errorf("invalid reconnect policy %d", policy)
+		return
+	}
+
+	e.Lock()
+	e.policy = policy
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH ReconnectPolicy
+func (e *EPOCH) GetReconnectPolicy() ReconnectPolicy {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.policy
+}
+
+// Set if EPOCH should reconnect when the GetWork connection has an error, giving up and stopping after maxAttempts failed
+// attempts in a row or retrying until StopGetWork if maxAttempts is 0. Enabling reconnects on any error if the ReconnectPolicy
+// was RECONNECT_NEVER, otherwise the policy is kept. Disabling sets RECONNECT_NEVER. A StopGetWork never triggers a reconnect
+func (e *EPOCH) SetReconnect(enabled bool, maxAttempts int) (err error) {
+	if maxAttempts < 0 {
+		err = fmt.Errorf("reconnect attempts must be 0 or greater")
+		return
+	}
+
+	e.Lock()
+	switch {
+	case !enabled:
+		e.policy = RECONNECT_NEVER
+	case e.policy == RECONNECT_NEVER:
+		e.policy = RECONNECT_ALWAYS
+	}
+	e.redials = maxAttempts
+	e.Unlock()
+
+	return
+}
+
+// Get if EPOCH reconnects and the maximum reconnect attempts
+func (e *EPOCH) GetReconnect() (enabled bool, maxAttempts int) {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.policy != RECONNECT_NEVER, e.redials
+}
+
+// Set the delay before the first reconnect attempt, doubled after each failed attempt up to max. Both must be greater
+// than 0 and base cannot exceed max, defaults are RECONNECT_DELAY and RECONNECT_MAX_DELAY
+func (e *EPOCH) SetReconnectBackoff(base, max time.Duration) (err error) {
+	if base <= 0 || max < base {
+		err = fmt.Errorf("reconnect backoff %s must be greater than 0 and not exceed %s", base, max)
+		return
+	}
+
+	e.Lock()
+	e.redial = [2]time.Duration{base, max}
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH reconnect backoff base and max delays
+func (e *EPOCH) GetReconnectBackoff() (base, max time.Duration) {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.redial[0], e.redial[1]
+}
+
+// Set the reconnect jitter as a fraction of each reconnect delay, each delay is randomized by up to ±fraction so instances
+// that lost the same node do not all reconnect at the same time. Fraction must be from 0 to 1, default is 0 for no jitter
+func (e *EPOCH) SetReconnectJitter(fraction float64) (err error) {
+	if fraction < 0 || fraction > 1 {
+		err = fmt.Errorf("reconnect jitter must be from 0 to 1")
+		return
+	}
+
+	e.Lock()
+	e.jitter = fraction
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH reconnect jitter
+func (e *EPOCH) GetReconnectJitter() float64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.jitter
+}
+
+// Randomize delay by up to ±fraction
+func jitterDelay(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return delay
+	}
+
+	return delay + time.Duration(float64(delay)*fraction*(2*rand.Float64()-1))
+}
+
+// Set how many times StartGetWork will retry its initial connect before returning the last error, waiting backoff before
+// the first retry and doubling it after each failed retry up to RECONNECT_MAX_DELAY. This is separate from the ReconnectPolicy
+// which is used once connected. StopGetWork ends any remaining retries. Default is 0 retries
+func (e *EPOCH) SetConnectRetries(n int, backoff time.Duration) (err error) {
+	if n < 0 {
+		err = fmt.Errorf("connect retries must be 0 or greater")
+		return
+	}
+
+	if n > 0 && backoff <= 0 {
+		err = fmt.Errorf("connect backoff must be greater than 0")
+		return
+	}
+
+	e.Lock()
+	e.retries = n
+	e.backoff = backoff
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH connect retries and backoff
+func (e *EPOCH) GetConnectRetries() (n int, backoff time.Duration) {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.retries, e.backoff
+}
+
+// Dial url for the initial connection, retrying as per SetConnectRetries until ctx is cancelled by StopGetWork. A dial that
+// is in progress is not cancelled, as a StartGetWork that is connecting is not affected by StopGetWork, only the retries are ended
+func (e *EPOCH) connect(ctx context.Context, url string) (ws workConn, err error) {
+	retries, delay := e.GetConnectRetries()
+	for attempt := 0; ; attempt++ {
+		ws, err = e.dialWork(context.Background(), url)
+		if err == nil || attempt >= retries {
+			return
+		}
+
+		logger.Warnf("[EPOCH] Connect attempt %d failed: %s, retrying in %s\n", attempt+1, err, delay)
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("connect was stopped: %w", ctx.Err())
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > RECONNECT_MAX_DELAY {
+			delay = RECONNECT_MAX_DELAY
+		}
+	}
+}
+
+// Check if a connection read error should be reconnected on as per policy
+func shouldReconnect(policy ReconnectPolicy, err error) bool {
+	switch policy {
+	case RECONNECT_ALWAYS:
+		return true
+	case RECONNECT_TRANSIENT:
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			// The node has intentionally closed the connection
+			return closeErr.Code != websocket.CloseNormalClosure && closeErr.Code != websocket.CloseGoingAway
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+// Close the errored connection and redial with an increasing delay as per SetReconnectBackoff, randomized as per SetReconnectJitter, until connected
+// or ctx is cancelled, returning the endpoint connected to. If started by StartGetWorkPool each attempt moves on to the next endpoint of a new selection,
+// otherwise endpoint is redialed. While reconnecting IsActive will return false. Returns nil if StopGetWork is called before reconnecting or the
+// maximum attempts of SetReconnect have failed
+func (e *EPOCH) reconnect(ctx context.Context, ws workConn, endpoint string, target func(string) string) (workConn, string) {
+	e.conn.Lock()
+	ws.Close()
+	if e.conn.ws == ws {
+		e.conn.ws = nil
+	}
+	e.conn.Unlock()
+
+	endpoints := e.poolReconnectOrder()
+	if endpoints == nil {
+		endpoints = []string{endpoint}
+	}
+
+	delay, maxDelay := e.GetReconnectBackoff()
+	_, maxAttempts := e.GetReconnect()
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil, endpoint
+		case <-time.After(jitterDelay(delay, e.GetReconnectJitter())):
+		}
+
+		endpoint = endpoints[attempt%len(endpoints)]
+		url := target(endpoint)
+		ws, err := e.dialWork(ctx, url)
+		if err == nil {
+			e.conn.Lock()
+			defer e.conn.Unlock()
+			if ctx.Err() != nil {
+				ws.Close()
+				return nil, endpoint
+			}
+
+			e.conn.ws = ws
+			logger.Printf("[EPOCH] Reconnected to %s\n", url)
+
+			return ws, endpoint
+		}
+
+		logger.Errorf("[EPOCH] Reconnect failed: %s\n", err)
+		if maxAttempts > 0 && attempt+1 >= maxAttempts {
+			return nil, endpoint
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package epoch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StartAndRun starts GetWork, waits up to 10 seconds or until ctx is done for the first job and then continually performs
+// AttemptHashesContext in batches of hashesPerBatch until ctx is cancelled, returning the aggregated totals of all batches
+// including the one cancelled. While there is no job to hash it waits between batches rather than retrying straight away.
+// If a ReconnectPolicy is set it will wait for EPOCH to reconnect when the connection drops, otherwise it returns ErrNotActive with the totals
+func (e *EPOCH) StartAndRun(ctx context.Context, address, endpoint string, hashesPerBatch int) (result EPOCH_Result, err error) {
+	if hashesPerBatch > e.GetMaxHashes() {
+		err = fmt.Errorf("hashes exceeds maxHashes %d/%d", hashesPerBatch, e.GetMaxHashes())
+		return
+	}
+
+	if err = e.StartGetWork(address, endpoint); err != nil {
+		return
+	}
+	defer e.StopGetWork()
+
+	wait, cancel := context.WithTimeout(ctx, time.Second*10)
+	err = e.JobIsReadyContext(wait)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	var duration time.Duration
+	defer func() {
+		result.Duration = durationMs(duration)
+		result.HashPerSec = hashesPerSecond(result.Hashes, duration)
+	}()
+
+	for ctx.Err() == nil {
+		if !e.IsActive() {
+			if !e.isRunning() {
+				err = ErrNotActive
+				return
+			}
+
+			// Reconnecting
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Millisecond * 100):
+			}
+
+			continue
+		}
+
+		now := time.Now()
+		res, attemptErr := e.AttemptHashesContext(ctx, hashesPerBatch)
+		if attemptErr != nil && attemptErr != ErrNotActive && ctx.Err() == nil {
+			err = attemptErr
+			return
+		}
+
+		duration += time.Since(now)
+		result.Hashes += res.Hashes
+		result.Submitted += res.Submitted
+
+		if errors.Is(res.Error, ErrJobNotReady) || res.Hashes == 0 {
+			// Waiting for a job
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Millisecond * 100):
+			}
+		}
+	}
+
+	return
+}
+
+// HashCurrentJob waits up to JOB_WAIT_TIMEOUT for a job with work and then performs AttemptHashesContext on it with ctx,
+// returning the result with the height and difficulty of the job. It is intended for one-shot scripts where GetWork has been started
+func (e *EPOCH) HashCurrentJob(ctx context.Context, hashes int) (result EPOCH_Result, height uint64, difficulty string, err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
+		return
+	}
+
+	// Only waiting for the job is limited by JOB_WAIT_TIMEOUT, the attempt uses ctx
+	wait, cancel := context.WithTimeout(ctx, JOB_WAIT_TIMEOUT)
+	defer cancel()
+
+	for {
+		job, jobErr := e.getWorkJob()
+		if jobErr == nil {
+			height = job.Height
+			difficulty = job.Difficulty
+			break
+		}
+
+		select {
+		case <-wait.Done():
+			err = fmt.Errorf("could not get EPOCH job: %w", wait.Err())
+			return
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+
+	result, err = e.AttemptHashesContext(ctx, hashes)
+
+	return
+}
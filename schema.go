@@ -0,0 +1,135 @@
+package epoch
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const JSON_SCHEMA = "https://json-schema.org/draft/2020-12/schema" // JSON Schema dialect of MethodSchemas
+
+// JSON Schema definition
+type Schema map[string]any
+
+// JSON Schema definitions of an EPOCH method
+type MethodSchema struct {
+	Params Schema `json:"params,omitempty"` // Params is nil when the method takes no params
+	Result Schema `json:"result"`
+}
+
+var (
+	bigIntType    = reflect.TypeOf(big.Int{})
+	timeType      = reflect.TypeOf(time.Time{})
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// MethodSchemas returns the JSON Schema of the params and result of each method in GetHandler, generated from the
+// method's Go types so tooling can validate requests and build typed clients in other languages
+func MethodSchemas() map[string]MethodSchema {
+	schemas := map[string]MethodSchema{}
+	for n, fn := range epoch.methods() {
+		t := reflect.TypeOf(fn)
+
+		var schema MethodSchema
+		if t.NumIn() > 1 {
+			schema.Params = rootSchema(t.In(1))
+		}
+		schema.Result = rootSchema(t.Out(0))
+
+		schemas[n] = schema
+	}
+
+	return schemas
+}
+
+// Create the JSON Schema of t with the dialect and its Go type name as the title
+func rootSchema(t reflect.Type) Schema {
+	schema := typeSchema(t, map[reflect.Type]bool{})
+	schema["$schema"] = JSON_SCHEMA
+	if name := t.Name(); name != "" {
+		schema["title"] = name
+	}
+
+	return schema
+}
+
+// Create the JSON Schema of how t is encoded by encoding/json, seen guards recursive types
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == bigIntType:
+		return Schema{"type": "integer"}
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == errorType:
+		return Schema{} // Errors can be any value
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return Schema{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Array:
+		return Schema{"type": "array", "items": typeSchema(t.Elem(), seen), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] || t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+			return Schema{}
+		}
+
+		seen[t] = true
+		defer delete(seen, t)
+
+		return structSchema(t, seen)
+	}
+
+	return Schema{}
+}
+
+// Create the JSON Schema of struct t's exported fields using their json tags
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) Schema {
+	properties := Schema{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = typeSchema(f.Type, seen)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return Schema{"type": "object", "properties": properties, "required": required}
+}
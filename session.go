@@ -0,0 +1,81 @@
+package epoch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const SESSION_EXPORT_VERSION = 1 // Version of the ExportSession format
+
+// Session totals that are carried across process restarts by ExportSession and ImportSession
+type sessionExport struct {
+	Version    int       `json:"version"`
+	Hashes     uint64    `json:"hashes"`
+	MiniBlocks int       `json:"minis"`
+	Accepted   uint64    `json:"accepted"`
+	Rejected   uint64    `json:"rejected"`
+	Started    time.Time `json:"started"`
+}
+
+// ExportSession serializes the current session totals and start time so they can be restored with ImportSession,
+// such as when migrating a long-running session across a process restart
+func (e *EPOCH) ExportSession() (data []byte, err error) {
+	e.RLock()
+	export := sessionExport{
+		Version:    SESSION_EXPORT_VERSION,
+		Hashes:     e.session.Hashes,
+		MiniBlocks: e.session.MiniBlocks,
+		Accepted:   e.session.Accepted,
+		Rejected:   e.session.Rejected,
+		Started:    e.session.Started,
+	}
+	e.RUnlock()
+
+	return json.Marshal(export)
+}
+
+// ImportSession restores session totals exported by ExportSession, they are added to the session when StartGetWork
+// next connects instead of starting it from 0. It can only be called while no GetWork connection is running, otherwise ErrAlreadyRunning
+func (e *EPOCH) ImportSession(data []byte) (err error) {
+	var export sessionExport
+	if err = json.Unmarshal(data, &export); err != nil {
+		err = fmt.Errorf("invalid session export: %s", err)
+		return
+	}
+
+	if export.Version != SESSION_EXPORT_VERSION {
+		err = fmt.Errorf("unknown session export version %d", export.Version)
+		return
+	}
+
+	e.conn.Lock()
+	defer e.conn.Unlock()
+
+	if e.conn.starting || e.conn.cancel != nil {
+		err = ErrAlreadyRunning
+		return
+	}
+
+	e.Lock()
+	e.imported = &export
+	e.Unlock()
+
+	return
+}
+
+// Restore the imported session if there is one, e must be locked by the caller after the session has been reset
+func (e *EPOCH) restoreSession() {
+	if e.imported == nil {
+		return
+	}
+
+	e.session.Hashes = e.imported.Hashes
+	e.session.MiniBlocks = e.imported.MiniBlocks
+	e.session.Accepted = e.imported.Accepted
+	e.session.Rejected = e.imported.Rejected
+	if !e.imported.Started.IsZero() {
+		e.session.Started = e.imported.Started
+	}
+	e.imported = nil
+}
@@ -0,0 +1,109 @@
+package epoch
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/deroproject/derohe/config"
+)
+
+const (
+	DEFAULT_BLOCK_TIME = time.Second * time.Duration(config.BLOCK_TIME) // Default target block time used to estimate network share
+	BLOB_HIGHDIFF      = byte(0x10)                                     // Bit of a hashing blob's version byte set when the job's difficulty is multiplied by MINIBLOCK_HIGHDIFF
+)
+
+// NetworkShare_Result is the estimated share of the network hash rate this EPOCH session represents
+type NetworkShare_Result struct {
+	Hashrate         float64       `json:"hashrate"`         // Session's moving average hash rate in H/s
+	NetworkHashrate  float64       `json:"networkHashrate"`  // Estimated network hash rate in H/s, the network difficulty scaled from config.BLOCK_TIME to the block time
+	Share            float64       `json:"share"`            // Fraction of the network hash rate, Hashrate / NetworkHashrate
+	MiniBlocksPerDay float64       `json:"miniBlocksPerDay"` // Expected miniblocks found per day at Hashrate and the job difficulty
+	Difficulty       string        `json:"difficulty"`       // Difficulty of the job the estimate is based on
+	BlockTime        time.Duration `json:"blockTime"`        // Target block time the estimate is based on, see SetBlockTime
+}
+
+// Set the target block time used by NetworkShareEstimate, default is DEFAULT_BLOCK_TIME
+func (e *EPOCH) SetBlockTime(d time.Duration) (err error) {
+	if d <= 0 {
+		err = fmt.Errorf("block time must be greater than 0")
+		return
+	}
+
+	e.Lock()
+	e.blockTime = d
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH target block time
+func (e *EPOCH) GetBlockTime() time.Duration {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.blockTime
+}
+
+// NetworkShareEstimate estimates the fraction of the network hash rate the session's CurrentHashrate represents
+// and the miniblocks it can expect to find per day, using the difficulty of the last job with work and the block time.
+// ErrJobNotReady is returned if there is no job to estimate from, a session that has not hashed yet will have a share of 0
+func (e *EPOCH) NetworkShareEstimate() (result NetworkShare_Result, err error) {
+	job := e.jobs.load().last
+	if job.Blockhashing_blob == "" {
+		err = ErrJobNotReady
+		return
+	}
+
+	session, err := e.GetSession(time.Second)
+	if err != nil {
+		return
+	}
+
+	return networkShare(job.Difficulty, blobHighDiff(job.Blockhashing_blob), session.CurrentHashrate, e.GetBlockTime())
+}
+
+// Check if a job's hashing blob is a HighDiff miniblock
+func blobHighDiff(blob string) bool {
+	if len(blob) < 2 {
+		return false
+	}
+
+	b, err := hex.DecodeString(blob[:2])
+	if err != nil {
+		return false
+	}
+
+	return b[0]&BLOB_HIGHDIFF != 0
+}
+
+// Estimate the network share of hashrate at difficulty and blockTime. The node's network hash rate is its difficulty
+// at config.BLOCK_TIME, a highDiff job's difficulty is the network difficulty multiplied by config.MINIBLOCK_HIGHDIFF
+func networkShare(difficulty string, highDiff bool, hashrate float64, blockTime time.Duration) (result NetworkShare_Result, err error) {
+	diff, ok := new(big.Float).SetString(difficulty)
+	if !ok || diff.Sign() < 1 {
+		err = fmt.Errorf("invalid job difficulty %q", difficulty)
+		return
+	}
+
+	if blockTime <= 0 {
+		err = fmt.Errorf("block time must be greater than 0")
+		return
+	}
+
+	d, _ := diff.Float64()
+	network := d
+	if highDiff {
+		network /= config.MINIBLOCK_HIGHDIFF
+	}
+
+	result.Hashrate = hashrate
+	result.Difficulty = difficulty
+	result.BlockTime = blockTime
+	result.NetworkHashrate = network * DEFAULT_BLOCK_TIME.Seconds() / blockTime.Seconds()
+	result.Share = hashrate / result.NetworkHashrate
+	result.MiniBlocksPerDay = hashrate * (time.Hour * 24).Seconds() / d
+
+	return
+}
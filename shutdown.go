@@ -0,0 +1,162 @@
+package epoch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/civilware/tela/logger"
+)
+
+// AttemptHashes batches that StopHashing will signal and sync
+type hashingStop struct {
+	done    chan struct{}  // done is closed by StopHashing
+	batches sync.WaitGroup // batches is the running batches that joined before done was closed
+}
+
+// Shutdown stops EPOCH like Close, but first flushes any valid blocks that are still in the pipeline so a win found
+// right as shutdown begins is not dropped. Running batches stop dispatching new workers, submissions buffered in the
+// SubmitChannel are submitted and workers already hashing are waited on to submit their result. The flush is bounded
+// by ctx, once ctx is done the connection is closed and any remaining blocks are dropped, returning ctx.Err()
+func (e *EPOCH) Shutdown(ctx context.Context) (err error) {
+	semaphore := e.getSemaphore()
+	if semaphore == nil {
+		return e.Close()
+	}
+
+	e.setDraining(true)
+
+	err = e.flushSubmissions(ctx)
+
+	// Workers hold their semaphore slot until their hash has been submitted,
+	// so once every slot is held there are no blocks left in the pipeline
+	held := 0
+	for err == nil && held < cap(semaphore) {
+		select {
+		case semaphore <- struct{}{}:
+			held++
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err != nil {
+		logger.Warnf("[EPOCH] Shutdown flush: %s\n", err)
+	}
+
+	if cErr := e.Close(); err == nil {
+		err = cErr
+	}
+
+	// Batches waiting on a slot will see they are draining and stop
+	for ; held > 0; held-- {
+		<-semaphore
+	}
+
+	return
+}
+
+// Stop the SubmitChannel consumer, waiting for any submission it is already submitting, and then submit everything buffered
+func (e *EPOCH) flushSubmissions(ctx context.Context) (err error) {
+	e.RLock()
+	ch := e.submitCh
+	stop := e.stopStream
+	streamed := e.streamed
+	e.RUnlock()
+	if ch == nil || stop == nil {
+		return
+	}
+
+	stop()
+
+	select {
+	case <-streamed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		select {
+		case p := <-ch:
+			e.submitStreamed(p)
+		default:
+			return
+		}
+	}
+}
+
+// Set draining, stopping running batches from dispatching new workers
+func (e *EPOCH) setDraining(b bool) {
+	e.Lock()
+	e.draining = b
+	e.Unlock()
+}
+
+// Check if Shutdown has begun
+func (e *EPOCH) isDraining() bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.draining
+}
+
+// StopHashing signals all running AttemptHashes batches to wind down without disconnecting, they stop dispatching new workers
+// and return their partial results once the workers already hashing have finished. Batches started after StopHashing are not
+// affected. It returns once every signaled batch has returned, or errors if they have not returned before timeout. Hosts that
+// can not cancel work with a context can use it to cancel the current work
+func (e *EPOCH) StopHashing(timeout time.Duration) (err error) {
+	e.Lock()
+	stop := e.stopping
+	e.stopping = nil
+	e.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop.done)
+
+	quiesced := make(chan struct{})
+	go func() {
+		stop.batches.Wait()
+		close(quiesced)
+	}()
+
+	select {
+	case <-quiesced:
+	case <-time.After(timeout):
+		err = fmt.Errorf("batches did not stop hashing after %s", timeout)
+	}
+
+	return
+}
+
+// Join a batch to the current hashingStop, the batch must call stop.batches.Done when it returns
+func (e *EPOCH) joinHashing() (stop *hashingStop) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.stopping == nil {
+		e.stopping = &hashingStop{done: make(chan struct{})}
+	}
+
+	stop = e.stopping
+	stop.batches.Add(1)
+
+	return
+}
+
+// Check if StopHashing has been called for the batch
+func (h *hashingStop) stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
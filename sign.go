@@ -0,0 +1,81 @@
+package epoch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Set the shared key remote workers sign their submissions with, when set SubmitHashes will reject any submission without
+// a valid Signature from Submit_Params.Sign. The key is copied, setting an empty key removes it and submissions are not
+// checked (default). Submissions from the SubmitChannel are checked the same as SubmitHashes
+func (e *EPOCH) SetWorkerKey(key []byte) {
+	e.Lock()
+	e.workerKey = append([]byte(nil), key...)
+	e.Unlock()
+}
+
+// Get the worker key, nil if not set
+func (e *EPOCH) getWorkerKey() []byte {
+	e.RLock()
+	defer e.RUnlock()
+
+	if len(e.workerKey) == 0 {
+		return nil
+	}
+
+	return e.workerKey
+}
+
+// Sign sets the submission's Signature to the hex HMAC-SHA256 of its JobID, work, POW hash and difficulty using
+// the host's worker key, so a host with SetWorkerKey can verify the submission came from a worker with the key
+func (p *Submit_Params) Sign(key []byte) {
+	p.Signature = hex.EncodeToString(p.mac(key))
+}
+
+// HMAC-SHA256 of the submitted values with key
+func (p *Submit_Params) mac(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(p.Job.JobID))
+	mac.Write([]byte{0})
+	mac.Write(p.EpochWork[:])
+	mac.Write(p.PowHash[:])
+	mac.Write([]byte(p.Difficulty.String()))
+
+	return mac.Sum(nil)
+}
+
+// Check the submission's Signature if a worker key is set
+func verifySubmission(p *Submit_Params, key []byte) (err error) {
+	if key == nil {
+		return
+	}
+
+	if p.Signature == "" {
+		err = fmt.Errorf("submission for job %q is not signed", p.Job.JobID)
+		return
+	}
+
+	signature, err := hex.DecodeString(p.Signature)
+	if err != nil || !hmac.Equal(signature, p.mac(key)) {
+		err = fmt.Errorf("invalid submission signature for job %q", p.Job.JobID)
+		return
+	}
+
+	return
+}
+
+// Check the submission's Signature if a worker key is set and that its difficulty can be submitted
+func checkSubmission(p *Submit_Params, key []byte) (err error) {
+	if err = verifySubmission(p, key); err != nil {
+		return
+	}
+
+	if p.Difficulty.Sign() < 1 {
+		err = fmt.Errorf("invalid submission difficulty %s", p.Difficulty.String())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,159 @@
+package epoch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/civilware/tela/logger"
+)
+
+const (
+	SUBMIT_CHANNEL_SIZE  = 100 // Buffer size of the SubmitChannel
+	RESULTS_CHANNEL_SIZE = 10  // Buffer size of the ResultsChannel
+)
+
+// SubmitChannel returns the channel for streaming pre computed hashes to EPOCH, each one received is checked and
+// submitted as a miniblock if valid, increasing the session miniblock total. The channel is created by StartGetWork
+// and buffers SUBMIT_CHANNEL_SIZE submissions, when it is full a send will block so hosts that should not wait can send
+// using a select with a default case to drop the submission. It is nil when EPOCH is not active and it is not closed
+// by StopGetWork, submissions sent after the connection has stopped will not be submitted. If a worker key is set with
+// SetWorkerKey, submissions without a valid Signature are logged and dropped the same as SubmitHashes rejects them
+func (e *EPOCH) SubmitChannel() chan<- Submit_Params {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.submitCh
+}
+
+// Submit hashes received on ch until ctx is cancelled, closing done when stopped
+func (e *EPOCH) consumeSubmissions(ctx context.Context, ch chan Submit_Params, done chan struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-ch:
+			e.submitStreamed(p)
+		}
+	}
+}
+
+// Submit a hash received from the SubmitChannel, it is checked the same as SubmitHashes
+func (e *EPOCH) submitStreamed(p Submit_Params) {
+	if err := checkSubmission(&p, e.getWorkerKey()); err != nil {
+		logger.Errorf("[EPOCH] Submit channel: %s\n", err)
+		return
+	}
+
+	valid, err := e.submitBlock(0, p.Job, p.PowHash, p.EpochWork, p.Difficulty)
+	if err != nil {
+		logger.Errorf("[EPOCH] Submit channel: %s\n", err)
+		return
+	}
+
+	if valid {
+		e.addSession(0, 0, 0, 1)
+	}
+}
+
+// RunLoop continually performs AttemptHashesContext with ctx in batches of batchSize, sending each batch's result to the ResultsChannel.
+// The loop waits while EPOCH is reconnecting or there is no job and the channel is closed when ctx is cancelled, the connection is stopped
+// or an attempt errors. Sends wait for the consumer, so a slow consumer paces the loop. Only one RunLoop can run at a time
+func (e *EPOCH) RunLoop(ctx context.Context, batchSize int) (err error) {
+	if !e.IsActive() {
+		err = ErrNotActive
+		return
+	}
+
+	if batchSize > e.GetMaxHashes() {
+		err = fmt.Errorf("hashes exceeds maxHashes %d/%d", batchSize, e.GetMaxHashes())
+		return
+	}
+
+	e.conn.Lock()
+	done := e.conn.done
+	e.conn.Unlock()
+
+	e.Lock()
+	if e.looping {
+		e.Unlock()
+		err = fmt.Errorf("run loop is already running")
+		return
+	}
+	results := make(chan EPOCH_Result, RESULTS_CHANNEL_SIZE)
+	e.results = results
+	e.looping = true
+	e.Unlock()
+
+	go e.runLoop(ctx, done, results, batchSize)
+
+	return
+}
+
+// ResultsChannel returns the channel receiving the results of the last RunLoop started, it is closed when that
+// RunLoop stops so consumers can range over it. It is nil if RunLoop has not been called
+func (e *EPOCH) ResultsChannel() <-chan EPOCH_Result {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.results
+}
+
+// Perform AttemptHashesContext and send results until ctx or done is closed, closing results when stopped
+func (e *EPOCH) runLoop(ctx context.Context, done <-chan struct{}, results chan EPOCH_Result, batchSize int) {
+	defer func() {
+		e.Lock()
+		e.looping = false
+		e.Unlock()
+		close(results)
+	}()
+
+	for ctx.Err() == nil {
+		res, err := e.AttemptHashesContext(ctx, batchSize)
+		if ctx.Err() != nil {
+			// Cancelled, the partial batch is not sent
+			return
+		}
+
+		if err != nil {
+			if err == ErrNotActive && e.isRunning() {
+				// Reconnecting
+				select {
+				case <-ctx.Done():
+				case <-done:
+				case <-time.After(time.Millisecond * 100):
+				}
+
+				continue
+			}
+
+			if err != ErrNotActive {
+				logger.Errorf("[EPOCH] Run loop: %s\n", err)
+			}
+
+			return
+		}
+
+		if errors.Is(res.Error, ErrJobNotReady) || res.Hashes == 0 {
+			// Waiting for a job
+			select {
+			case <-ctx.Done():
+			case <-done:
+			case <-time.After(time.Millisecond * 100):
+			}
+
+			continue
+		}
+
+		select {
+		case results <- res:
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		}
+	}
+}
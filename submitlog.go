@@ -0,0 +1,194 @@
+package epoch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/civilware/tela/logger"
+	"github.com/deroproject/derohe/block"
+	"github.com/deroproject/derohe/rpc"
+)
+
+const (
+	SUBMIT_OUTCOME_SUBMITTED = "submitted" // Submission was written to the node
+	SUBMIT_OUTCOME_DROPPED   = "dropped"   // Submission was dropped for exceeding the submit rate
+	SUBMIT_OUTCOME_ERROR     = "error"     // Submission errored before or while writing to the node
+)
+
+// SubmissionLogEntry is one line of the submission log, it is written for each valid POW hash EPOCH attempts to submit
+type SubmissionLogEntry struct {
+	Time       time.Time `json:"time"`
+	Batch      uint64    `json:"batch"` // BatchID of the submission, 0 if not part of a batch
+	JobID      string    `json:"jobid"`
+	Height     uint64    `json:"height"`
+	Difficulty string    `json:"difficulty"`
+	Work       string    `json:"work"`    // Hex of the miniblock work
+	Outcome    string    `json:"outcome"` // SUBMIT_OUTCOME_SUBMITTED, SUBMIT_OUTCOME_DROPPED or SUBMIT_OUTCOME_ERROR
+	Error      string    `json:"error,omitempty"`
+}
+
+// Append only newline delimited JSON log of submissions and sync
+type submissionLog struct {
+	file    *os.File // file is the open log, nil when the log is disabled
+	path    string   // path of the log file
+	size    int64    // size is the current size of file
+	maxSize int64    // maxSize is the size file is rotated at, 0 is unlimited
+	sync.Mutex
+}
+
+// SetSubmissionLogFile enables a durable log of every submission attempt for post-mortem analysis, each attempt is appended to the
+// file at path as a line of JSON SubmissionLogEntry. The file is created if it does not exist. Setting "" will close and disable the log (default)
+func (e *EPOCH) SetSubmissionLogFile(path string) (err error) {
+	var file *os.File
+	var size int64
+	if path != "" {
+		file, size, err = openSubmissionLog(path)
+		if err != nil {
+			return
+		}
+	}
+
+	l := &e.submitLog
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file != nil {
+		l.file.Close()
+	}
+
+	l.file = file
+	l.path = path
+	l.size = size
+
+	return
+}
+
+// Get the EPOCH submission log file path, "" when disabled
+func (e *EPOCH) GetSubmissionLogFile() string {
+	e.submitLog.Lock()
+	defer e.submitLog.Unlock()
+
+	return e.submitLog.path
+}
+
+// Set the size in bytes the submission log is rotated at, it is renamed with a ".1" suffix replacing any previous
+// rotated log and a new log is started. If rotating fails the error is logged and entries are still appended to the
+// current log until a later rotation succeeds. A size of 0 is unlimited (default)
+func (e *EPOCH) SetSubmissionLogMaxSize(bytes int64) (err error) {
+	if bytes < 0 {
+		err = fmt.Errorf("submission log max size must be 0 or greater")
+		return
+	}
+
+	e.submitLog.Lock()
+	e.submitLog.maxSize = bytes
+	e.submitLog.Unlock()
+
+	return
+}
+
+// Get the EPOCH submission log max size
+func (e *EPOCH) GetSubmissionLogMaxSize() int64 {
+	e.submitLog.Lock()
+	defer e.submitLog.Unlock()
+
+	return e.submitLog.maxSize
+}
+
+// Open the submission log at path for appending and get its current size
+func openSubmissionLog(path string) (file *os.File, size int64, err error) {
+	file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		err = fmt.Errorf("could not open submission log: %s", err)
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		file = nil
+		err = fmt.Errorf("could not open submission log: %s", err)
+		return
+	}
+
+	size = info.Size()
+
+	return
+}
+
+// Append a submission attempt to the submission log if enabled, the outcome is taken from the submitBlock results
+func (e *EPOCH) logSubmission(batch uint64, job rpc.GetBlockTemplate_Result, work [block.MINIBLOCK_SIZE]byte, valid bool, err error) {
+	l := &e.submitLog
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	entry := SubmissionLogEntry{
+		Time:       time.Now(),
+		Batch:      batch,
+		JobID:      job.JobID,
+		Height:     job.Height,
+		Difficulty: job.Difficulty,
+		Work:       fmt.Sprintf("%x", work[:]),
+		Outcome:    SUBMIT_OUTCOME_DROPPED,
+	}
+
+	if valid {
+		entry.Outcome = SUBMIT_OUTCOME_SUBMITTED
+	} else if err != nil {
+		entry.Outcome = SUBMIT_OUTCOME_ERROR
+		entry.Error = err.Error()
+	}
+
+	line, mErr := json.Marshal(entry)
+	if mErr != nil {
+		logger.Errorf("[EPOCH] Submission log: %s\n", mErr)
+		return
+	}
+	line = append(line, '\n')
+
+	// A failed rotation keeps writing to the current log so no entries are lost, it is retried on the next entry
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if rErr := l.rotate(); rErr != nil {
+			logger.Errorf("[EPOCH] Submission log: %s\n", rErr)
+		}
+	}
+
+	n, wErr := l.file.Write(line)
+	l.size += int64(n)
+	if wErr != nil {
+		logger.Errorf("[EPOCH] Submission log: %s\n", wErr)
+	}
+}
+
+// Rename the log with a ".1" suffix and open a new log at path, l must be locked by the caller. If the rotation
+// fails the log is left as it was so the current file can still be written
+func (l *submissionLog) rotate() (err error) {
+	rotated := l.path + ".1"
+	if err = os.Rename(l.path, rotated); err != nil {
+		err = fmt.Errorf("could not rotate submission log: %s", err)
+		return
+	}
+
+	file, size, err := openSubmissionLog(l.path)
+	if err != nil {
+		// Put the current file back at path so the next rotation can retry it
+		if rErr := os.Rename(rotated, l.path); rErr != nil {
+			logger.Errorf("[EPOCH] Submission log: could not restore %s: %s\n", l.path, rErr)
+		}
+		err = fmt.Errorf("could not rotate submission log: %s", err)
+		return
+	}
+
+	l.file.Close()
+	l.file = file
+	l.size = size
+
+	return
+}
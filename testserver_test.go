@@ -0,0 +1,555 @@
+package epoch
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deroproject/derohe/globals"
+	"github.com/deroproject/derohe/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// Simulator address used with the in-memory GetWork server
+const testAddress = "deto1qyre7td6x9r88y4cavdgpv6k7lvx6j39lfsx420hpvh3ydpcrtxrxqg8v8e3z"
+
+// Job template where every hash is a valid miniblock
+var testJob = rpc.GetBlockTemplate_Result{
+	JobID:             "1722895096807.0.notified",
+	Blockhashing_blob: "41dc0600000002062bb9d17900000000a12fda3f33403ee25f490fe665a93a3e0000000056790bb6dd9f5bdad5a18d87",
+	Difficulty:        "1",
+	Difficultyuint64:  1,
+	Height:            518,
+}
+
+// In-memory GetWork server implementing the DERO GetWork websocket protocol for tests
+type testServer struct {
+	*httptest.Server
+	job         rpc.GetBlockTemplate_Result // Job pushed to each connection
+	conns       map[*websocket.Conn]string  // Connections and the address from their path
+	addresses   []string                    // Address of each accepted connection
+	submissions []rpc.SubmitBlock_Params    // Submissions received from all connections
+	received    []time.Time                 // Time each submission was received
+	drop        bool                        // drop closes each connection as soon as it is accepted
+	delay       time.Duration               // delay is waited before each request is handled
+	compress    bool                        // compress negotiates permessage-deflate when it is offered
+	deflate     []bool                      // If each accepted connection negotiated permessage-deflate
+	prefix      string                      // prefix is the path GetWork is served under, the address follows it
+	paths       []string                    // Request path of each accepted connection
+	silent      bool                        // silent ignores pings without a pong so connections appear dead
+	pings       int                         // pings is the count of pings received from all connections
+	sync.Mutex
+}
+
+// NewTestServer starts an in-memory GetWork server that pushes job to each connection and records submissions,
+// EPOCH's port is set to the server's port and the server is stopped with EPOCH when the test is done
+func NewTestServer(t testing.TB, job rpc.GetBlockTemplate_Result) (s *testServer) {
+	s = newTestServer(t, job)
+	s.StartTLS()
+
+	if err := SetPort(s.Port()); err != nil {
+		t.Fatalf("Failed to set test server port: %s", err)
+	}
+
+	return
+}
+
+// NewPlainTestServer is NewTestServer without TLS, for connecting over ws
+func NewPlainTestServer(t testing.TB, job rpc.GetBlockTemplate_Result) (s *testServer) {
+	s = newTestServer(t, job)
+	s.Start()
+
+	if err := SetPort(s.Port()); err != nil {
+		t.Fatalf("Failed to set test server port: %s", err)
+	}
+
+	return
+}
+
+// NewDelayedTestServer is NewTestServer where the server only starts listening after delay
+func NewDelayedTestServer(t testing.TB, job rpc.GetBlockTemplate_Result, delay time.Duration) (s *testServer) {
+	s = newTestServer(t, job)
+
+	// Reserve a port for the server to listen on once started
+	s.Listener.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve test server port: %s", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	s.Listener = l
+
+	if err := SetPort(s.Port()); err != nil {
+		t.Fatalf("Failed to set test server port: %s", err)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(delay)
+
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("Failed to listen on test server port: %s", err)
+			return
+		}
+
+		s.Listener = l
+		s.StartTLS()
+	}()
+
+	t.Cleanup(func() { <-started })
+
+	return
+}
+
+// Create an unstarted in-memory GetWork server
+func newTestServer(t testing.TB, job rpc.GetBlockTemplate_Result) (s *testServer) {
+	globals.Arguments["--testnet"] = true
+	globals.Arguments["--simulator"] = true
+	globals.InitNetwork()
+
+	s = &testServer{job: job, conns: map[*websocket.Conn]string{}, prefix: "/ws/"}
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		delay := s.delay
+		upgrader := websocket.Upgrader{EnableCompression: s.compress}
+		prefix := s.prefix
+		s.Unlock()
+		time.Sleep(delay)
+
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		address := strings.TrimPrefix(r.URL.Path, prefix)
+
+		s.Lock()
+		if s.drop {
+			s.Unlock()
+			ws.Close()
+			return
+		}
+		s.conns[ws] = address
+		ws.SetPingHandler(func(data string) error {
+			s.Lock()
+			s.pings++
+			silent := s.silent
+			s.Unlock()
+			if silent {
+				return nil
+			}
+
+			return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		s.addresses = append(s.addresses, address)
+		s.paths = append(s.paths, r.URL.Path)
+		deflate := upgrader.EnableCompression && strings.Contains(r.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+		s.deflate = append(s.deflate, deflate)
+		ws.EnableWriteCompression(deflate)
+		err = ws.WriteJSON(s.job)
+		s.Unlock()
+		if err != nil {
+			return
+		}
+
+		for {
+			var p rpc.SubmitBlock_Params
+			if err := ws.ReadJSON(&p); err != nil {
+				break
+			}
+
+			s.Lock()
+			s.submissions = append(s.submissions, p)
+			s.received = append(s.received, time.Now())
+			s.Unlock()
+		}
+
+		s.Lock()
+		delete(s.conns, ws)
+		s.Unlock()
+	}))
+
+	t.Cleanup(func() {
+		StopGetWork()
+		s.Close()
+		SetPort(DEFAULT_WORK_PORT)
+	})
+
+	return
+}
+
+// Compress sets if the test server negotiates permessage-deflate for new connections that offer it
+func (s *testServer) Compress(b bool) {
+	s.Lock()
+	s.compress = b
+	s.Unlock()
+}
+
+// Deflate returns if each accepted connection negotiated permessage-deflate
+func (s *testServer) Deflate() []bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]bool{}, s.deflate...)
+}
+
+// DropConnections sets if the test server closes each connection as soon as it is accepted
+func (s *testServer) DropConnections(b bool) {
+	s.Lock()
+	s.drop = b
+	s.Unlock()
+}
+
+// SetPrefix sets the path GetWork is served under, requests to any other path are not found
+func (s *testServer) SetPrefix(prefix string) {
+	s.Lock()
+	s.prefix = prefix
+	s.Unlock()
+}
+
+// IgnorePings sets if the test server ignores pings so its connections appear dead to keepalive
+func (s *testServer) IgnorePings(b bool) {
+	s.Lock()
+	s.silent = b
+	s.Unlock()
+}
+
+// Pings returns the count of pings received from all connections
+func (s *testServer) Pings() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.pings
+}
+
+// SetDelay sets how long the test server waits before handling each request
+func (s *testServer) SetDelay(delay time.Duration) {
+	s.Lock()
+	s.delay = delay
+	s.Unlock()
+}
+
+// Port the test server is listening on
+func (s *testServer) Port() (port int) {
+	_, p, _ := net.SplitHostPort(s.Listener.Addr().String())
+	port, _ = strconv.Atoi(p)
+
+	return
+}
+
+// Endpoint to pass to StartGetWork for the test server
+func (s *testServer) Endpoint() string {
+	return s.Listener.Addr().String()
+}
+
+// SendJob sets the job template and pushes it to all connections
+func (s *testServer) SendJob(job rpc.GetBlockTemplate_Result) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.job = job
+	for ws := range s.conns {
+		ws.WriteJSON(job)
+	}
+}
+
+// SendRaw pushes a raw text frame to all connections
+func (s *testServer) SendRaw(frame []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	for ws := range s.conns {
+		ws.WriteMessage(websocket.TextMessage, frame)
+	}
+}
+
+// CloseConnections drops all connections to the test server without a close message
+func (s *testServer) CloseConnections() {
+	s.Lock()
+	defer s.Unlock()
+
+	for ws := range s.conns {
+		ws.Close()
+	}
+}
+
+// Connections returns the amount of open connections to the test server
+func (s *testServer) Connections() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.conns)
+}
+
+// Submissions returns a copy of all the submissions received
+func (s *testServer) Submissions() []rpc.SubmitBlock_Params {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]rpc.SubmitBlock_Params{}, s.submissions...)
+}
+
+// SubmissionTimes returns a copy of the time each submission was received
+func (s *testServer) SubmissionTimes() []time.Time {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]time.Time{}, s.received...)
+}
+
+// Addresses returns the address of each connection accepted
+func (s *testServer) Addresses() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string{}, s.addresses...)
+}
+
+// Paths returns the request path of each connection accepted
+func (s *testServer) Paths() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string{}, s.paths...)
+}
+
+// WaitSubmissions waits for at least n submissions to be received, returning false if they are not received before timeout
+func (s *testServer) WaitSubmissions(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if len(s.Submissions()) >= n {
+			return true
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return false
+}
+
+// In-memory GetWork long-poll server for tests, a GET is answered with the job once its JobID differs from the
+// jobid query or with 204 No Content after hold, and a POST is a submission
+type pollServer struct {
+	*httptest.Server
+	job         rpc.GetBlockTemplate_Result // Job returned to polls
+	changed     chan struct{}               // changed is closed and replaced when the job is changed
+	hold        time.Duration               // hold is how long a poll waits for a new job
+	delay       time.Duration               // delay is how long a submission waits before it is answered
+	polls       int                         // polls is the count of GET requests received
+	submissions []rpc.SubmitBlock_Params    // Submissions received
+	sync.Mutex
+}
+
+// NewPollServer starts an in-memory GetWork long-poll server serving job under /ws/, EPOCH's port
+// is set to the server's port and the server is stopped with EPOCH when the test is done
+func NewPollServer(t testing.TB, job rpc.GetBlockTemplate_Result) (s *pollServer) {
+	globals.Arguments["--testnet"] = true
+	globals.Arguments["--simulator"] = true
+	globals.InitNetwork()
+
+	s = &pollServer{job: job, changed: make(chan struct{}), hold: time.Millisecond * 200}
+
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/ws/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			s.Lock()
+			s.polls++
+			job, changed := s.job, s.changed
+			s.Unlock()
+
+			if job.JobID == r.URL.Query().Get("jobid") {
+				select {
+				case <-changed:
+				case <-r.Context().Done():
+					return
+				case <-time.After(s.hold):
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				s.Lock()
+				job = s.job
+				s.Unlock()
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+		case http.MethodPost:
+			var p rpc.SubmitBlock_Params
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			s.Lock()
+			s.submissions = append(s.submissions, p)
+			delay := s.delay
+			s.Unlock()
+
+			select {
+			case <-r.Context().Done():
+			case <-time.After(delay):
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	if err := SetPort(s.Port()); err != nil {
+		t.Fatalf("Failed to set poll server port: %s", err)
+	}
+
+	t.Cleanup(func() {
+		StopGetWork()
+		s.Close()
+		SetPort(DEFAULT_WORK_PORT)
+	})
+
+	return
+}
+
+// Port the poll server is listening on
+func (s *pollServer) Port() (port int) {
+	_, p, _ := net.SplitHostPort(s.Listener.Addr().String())
+	port, _ = strconv.Atoi(p)
+
+	return
+}
+
+// Endpoint to pass to StartGetWork for the poll server
+func (s *pollServer) Endpoint() string {
+	return s.Listener.Addr().String()
+}
+
+// SendJob changes the job, answering any waiting polls
+func (s *pollServer) SendJob(job rpc.GetBlockTemplate_Result) {
+	s.Lock()
+	s.job = job
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.Unlock()
+}
+
+// SetSubmitDelay sets how long the poll server waits before answering each submission
+func (s *pollServer) SetSubmitDelay(delay time.Duration) {
+	s.Lock()
+	s.delay = delay
+	s.Unlock()
+}
+
+// Polls returns the count of polls received
+func (s *pollServer) Polls() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.polls
+}
+
+// Submissions returns the submissions received
+func (s *pollServer) Submissions() []rpc.SubmitBlock_Params {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]rpc.SubmitBlock_Params{}, s.submissions...)
+}
+
+// GetWork connection whose submissions block until released or the connection is closed, used to simulate a node
+// that has stopped reading
+type stalledConn struct {
+	workConn
+	deadline time.Time     // deadline is the last write deadline set
+	writing  chan struct{} // writing is closed when the first write is blocked
+	release  chan struct{} // release is closed by Close
+	once     sync.Once
+	sync.Mutex
+}
+
+// Stall the writes of EPOCH's current connection
+func stallConn(e *EPOCH) (c *stalledConn) {
+	e.conn.Lock()
+	defer e.conn.Unlock()
+
+	c = &stalledConn{workConn: e.conn.ws, writing: make(chan struct{}), release: make(chan struct{})}
+	e.conn.ws = c
+
+	return
+}
+
+func (c *stalledConn) SetWriteDeadline(t time.Time) error {
+	c.Lock()
+	c.deadline = t
+	c.Unlock()
+
+	return c.workConn.SetWriteDeadline(t)
+}
+
+// Deadline returns the last write deadline set
+func (c *stalledConn) Deadline() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.deadline
+}
+
+func (c *stalledConn) WriteJSON(v interface{}) error {
+	select {
+	case <-c.writing:
+	default:
+		close(c.writing)
+	}
+	<-c.release
+
+	return websocket.ErrCloseSent
+}
+
+func (c *stalledConn) Close() error {
+	c.once.Do(func() { close(c.release) })
+
+	return c.workConn.Close()
+}
+
+// Test the in-memory GetWork server with EPOCH
+func TestGetWorkServer(t *testing.T) {
+	s := NewTestServer(t, testJob)
+
+	err := StartGetWork(testAddress, s.Endpoint())
+	if err != nil {
+		t.Fatalf("StartGetWork should not error: %s", err)
+	}
+
+	err = JobIsReady(time.Second * 5)
+	assert.NoError(t, err, "Finding job should not error: %s", err)
+
+	res, err := AttemptHashes(10)
+	assert.NoError(t, err, "AttemptHashes should not error: %s", err)
+	assert.NoError(t, res.Error, "AttemptHashes result should not error")
+	assert.NotZero(t, res.Submitted, "Hashes should be submitted at difficulty 1")
+
+	assert.True(t, s.WaitSubmissions(res.Submitted, time.Second*5), "Test server should receive all submissions")
+	for _, p := range s.Submissions() {
+		assert.Equal(t, testJob.JobID, p.JobID, "Submission JobID should be equal")
+	}
+
+	assert.Equal(t, []string{testAddress}, s.Addresses(), "Test server should have one connection from address")
+}
@@ -0,0 +1,240 @@
+package epoch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport defines how EPOCH receives jobs from and submits work to the GetWork server
+type Transport int
+
+const (
+	TRANSPORT_WEBSOCKET Transport = iota // Jobs are pushed over a GetWork websocket (default)
+	TRANSPORT_LONGPOLL                   // Jobs are long-polled over HTTP and submissions are posted, for networks that block websockets
+)
+
+const (
+	LONGPOLL_TIMEOUT   = time.Minute      // Maximum time a long-poll waits for a new job, the server should answer sooner with 204 No Content
+	LONGPOLL_SUBMIT    = time.Second * 10 // Maximum time a long-poll submission waits for the server
+	LONGPOLL_MAX_FRAME = 1 << 20          // Maximum size of a job read from a long-poll response
+)
+
+// Connection to the GetWork server that jobs are read from and submissions are written to, it is implemented
+// by *websocket.Conn and pollConn. ReadMessage is only called by the connection's read loop
+type workConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteJSON(v interface{}) error
+	SetWriteDeadline(t time.Time) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	Close() error
+}
+
+// Set the Transport used to connect to the GetWork server, default is TRANSPORT_WEBSOCKET. TRANSPORT_LONGPOLL uses
+// http or https as per SetScheme with the same host and work path. DERO nodes only serve GetWork over a websocket, so
+// long-polling needs a bridge in front of the node that serves this contract at the work url:
+//   - GET url?jobid=<JobID of the last job read, empty at first> is held until the server has a job with another JobID
+//     and answered 200 with the job as GetBlockTemplate_Result JSON of at most LONGPOLL_MAX_FRAME bytes, or answered
+//     204 No Content when there is no new job within LONGPOLL_TIMEOUT. Any other status drops the connection
+//   - POST url with a SubmitBlock_Params JSON body is a submission and is answered with any 2xx status within LONGPOLL_SUBMIT,
+//     submissions are posted one at a time and the node's accepted and rejected counts are read from the following jobs
+//
+// A new job is only received once the previous poll has returned and the next has been sent, so jobs and submit results
+// have higher latency than a websocket. Keepalive and compression are not used. The setting is used by the next StartGetWork
+func (e *EPOCH) SetTransport(transport Transport) (err error) {
+	if transport < TRANSPORT_WEBSOCKET || transport > TRANSPORT_LONGPOLL {
+		err = fmt.Errorf("invalid transport %d", transport)
+		return
+	}
+
+	e.Lock()
+	e.transport = transport
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH Transport
+func (e *EPOCH) GetTransport() Transport {
+	e.RLock()
+	defer e.RUnlock()
+
+	return e.transport
+}
+
+// Get the url scheme of the GetWork connection for the Transport
+func (e *EPOCH) transportScheme() string {
+	if e.GetTransport() == TRANSPORT_LONGPOLL {
+		if !e.GetTLSEnabled() {
+			return "http"
+		}
+
+		return "https"
+	}
+
+	return e.GetScheme()
+}
+
+// Get the TLS config of wss and https connections
+func (e *EPOCH) clientTLSConfig() *tls.Config {
+	if config := e.GetTLSConfig(); config != nil {
+		return config
+	}
+
+	return &tls.Config{InsecureSkipVerify: true}
+}
+
+// Dial the GetWork server at target with the transport of its scheme
+func (e *EPOCH) dialWork(ctx context.Context, target string) (workConn, error) {
+	if strings.HasPrefix(target, "http") {
+		return e.dialPoll(ctx, target)
+	}
+
+	// A nil *websocket.Conn is not returned as a non-nil workConn
+	ws, err := e.dial(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, nil
+}
+
+// GetWork connection over HTTP long-polling
+type pollConn struct {
+	url     string             // url jobs are polled from and submissions are posted to
+	client  *http.Client       // client is closed with the connection
+	jobID   string             // jobID is the JobID of the last job read, sent with each poll so only a new job is returned
+	pending []byte             // pending is the job received when dialing to be returned by the first ReadMessage
+	ctx     context.Context    // ctx ends any request in progress when the connection is closed
+	cancel  context.CancelFunc // cancel closes the connection
+}
+
+// Dial the long-poll GetWork server at target, the current job is polled so the server is known to be serving jobs
+func (e *EPOCH) dialPoll(ctx context.Context, target string) (p *pollConn, err error) {
+	p = &pollConn{
+		url: target,
+		client: &http.Client{
+			Timeout:   LONGPOLL_TIMEOUT,
+			Transport: &http.Transport{TLSClientConfig: e.clientTLSConfig()},
+		},
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	for p.pending == nil {
+		if p.pending, err = p.poll(ctx); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// Poll for a job with a JobID other than the last read, returning nil if the server had no new job before it answered
+func (p *pollConn) poll(ctx context.Context) (message []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?jobid="+url.QueryEscape(p.jobID), nil)
+	if err != nil {
+		return
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return
+	default:
+		err = fmt.Errorf("long-poll of %s returned %s", p.url, res.Status)
+		return
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, LONGPOLL_MAX_FRAME))
+}
+
+// Read the next job with a new JobID, polling until one is received or there is an error
+func (p *pollConn) ReadMessage() (messageType int, message []byte, err error) {
+	message, p.pending = p.pending, nil
+	for {
+		if message != nil {
+			// A job that can not be decoded is returned for the read loop to log
+			var job struct {
+				JobID string `json:"jobid"`
+			}
+			if json.Unmarshal(message, &job) != nil {
+				return websocket.TextMessage, message, nil
+			}
+
+			if job.JobID != p.jobID {
+				p.jobID = job.JobID
+				return websocket.TextMessage, message, nil
+			}
+		}
+
+		if message, err = p.poll(p.ctx); err != nil {
+			return
+		}
+	}
+}
+
+// Post v to the server as JSON
+func (p *pollConn) WriteJSON(v interface{}) (err error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, LONGPOLL_SUBMIT)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err = fmt.Errorf("long-poll submit to %s returned %s", p.url, res.Status)
+	}
+
+	return
+}
+
+// Long-poll submissions are bounded by LONGPOLL_SUBMIT, the deadline is not used
+func (p *pollConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// Long-polling has no control frames, nothing is sent
+func (p *pollConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return nil
+}
+
+// Close the connection, ending any request in progress
+func (p *pollConn) Close() error {
+	p.cancel()
+	p.client.CloseIdleConnections()
+
+	return nil
+}
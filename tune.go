@@ -0,0 +1,94 @@
+package epoch
+
+import (
+	"context"
+	"crypto/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/deroproject/derohe/astrobwt/astrobwtv3"
+	"github.com/deroproject/derohe/block"
+)
+
+const AUTOTUNE_HASHES = 16 // Hashes each worker will run per thread count when Autotune is called
+
+// Autotune measures the hash rate of short batches at increasing thread counts up to runtime.NumCPU and sets maxThreads
+// to the thread count with the highest hash rate, returning the chosen value. It should be called prior to StartGetWork
+// as the connection's workers are sized when it is started. If ctx is cancelled maxThreads is not changed
+func (e *EPOCH) Autotune(ctx context.Context) (threads int, err error) {
+	var best float64
+	for t := 1; t <= runtime.NumCPU(); t++ {
+		var rate float64
+		rate, err = hashRate(ctx, t, t*AUTOTUNE_HASHES)
+		if err != nil {
+			return
+		}
+
+		if rate > best {
+			best = rate
+			threads = t
+		}
+	}
+
+	e.SetMaxThreads(threads)
+
+	return
+}
+
+// Measure the hash rate of hashes spread across threads workers, no connection or job is required
+func hashRate(ctx context.Context, threads, hashes int) (hashPerSecond float64, err error) {
+	var wg sync.WaitGroup
+	work := make(chan struct{}, hashes)
+	for i := 0; i < hashes; i++ {
+		work <- struct{}{}
+	}
+	close(work)
+
+	now := time.Now()
+
+	for t := 0; t < threads; t++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var buf [block.MINIBLOCK_SIZE]byte
+			for range work {
+				if ctx.Err() != nil {
+					return
+				}
+
+				rand.Read(buf[:])
+				buf[0] = 1 // valid version
+				astrobwtv3.AstroBWTv3(buf[:])
+			}
+		}()
+	}
+
+	wg.Wait()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	hashPerSecond = float64(hashes) / time.Since(now).Seconds()
+
+	return
+}
+
+// SingleThreadBenchmark hashes a fixed work blob on a single goroutine for duration and returns the hash rate,
+// it requires no connection and gives a baseline AstroBWTv3 rate to compare against other miners and Autotune
+func SingleThreadBenchmark(duration time.Duration) (hashPerSecond float64) {
+	var work [block.MINIBLOCK_SIZE]byte
+	work[0] = 1 // valid version
+
+	hashes := 0
+	now := time.Now()
+	for hashes == 0 || time.Since(now) < duration {
+		astrobwtv3.AstroBWTv3(work[:])
+		hashes++
+	}
+
+	hashPerSecond = float64(hashes) / time.Since(now).Seconds()
+
+	return
+}
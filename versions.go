@@ -0,0 +1,88 @@
+package epoch
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+const MAX_BLOB_VERSION = 0xf // Blob versions are the low 4 bits of the first work byte
+
+// Set of the job blob versions that have been seen
+type versionSet [MAX_BLOB_VERSION + 1]bool
+
+// Set the job blob versions that will be hashed on, default is version 1. Additional versions can be accepted during a
+// network upgrade window so mining is not stopped until the package is updated, jobs with any other version will error.
+// Versions that have been seen in jobs are reported in the session's BlobVersions
+func (e *EPOCH) SetAcceptedVersions(versions []byte) (err error) {
+	if len(versions) == 0 {
+		err = fmt.Errorf("at least one blob version must be accepted")
+		return
+	}
+
+	for _, v := range versions {
+		if v > MAX_BLOB_VERSION {
+			err = fmt.Errorf("invalid blob version %d", v)
+			return
+		}
+	}
+
+	e.Lock()
+	e.versions = append([]byte(nil), versions...)
+	e.Unlock()
+
+	return
+}
+
+// Get the EPOCH accepted blob versions
+func (e *EPOCH) GetAcceptedVersions() []byte {
+	e.RLock()
+	defer e.RUnlock()
+
+	return append([]byte(nil), e.versions...)
+}
+
+// Check if version is accepted
+func (e *EPOCH) acceptsVersion(version byte) bool {
+	e.RLock()
+	defer e.RUnlock()
+
+	return slices.Contains(e.versions, version)
+}
+
+// Get the version of a job's hashing blob, ok is false if the blob can not be decoded
+func blobVersion(blob string) (version byte, ok bool) {
+	if len(blob) < 2 {
+		return
+	}
+
+	b, err := hex.DecodeString(blob[:2])
+	if err != nil {
+		return
+	}
+
+	return b[0] & MAX_BLOB_VERSION, true
+}
+
+// Record the blob version of a job in the session
+func (e *EPOCH) seenVersion(blob string) {
+	version, ok := blobVersion(blob)
+	if !ok {
+		return
+	}
+
+	e.Lock()
+	e.seen[version] = true
+	e.Unlock()
+}
+
+// Get the blob versions seen during the session, e must be locked by the caller
+func (e *EPOCH) seenVersions() (versions []int) {
+	for v, seen := range e.seen {
+		if seen {
+			versions = append(versions, v)
+		}
+	}
+
+	return
+}